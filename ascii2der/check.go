@@ -0,0 +1,219 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ascii2der
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/der-ascii/lib"
+)
+
+// A DERError is a violation of DER found by CheckDER.
+type DERError struct {
+	Offset int // byte offset of the violation
+	Err    error
+}
+
+func (e *DERError) Error() string {
+	return fmt.Sprintf("offset %d: %s", e.Offset, e.Err)
+}
+
+// CheckDER checks that der is a series of DER-encoded elements. It returns a
+// *DERError describing the first violation, if any. It checks lengths and tags
+// are minimally encoded and that INTEGER, ENUMERATED, OBJECT IDENTIFIER,
+// RELATIVE-OID, UTCTime, and GeneralizedTime contents are canonical. It does not
+// check other types.
+func CheckDER(der []byte) error {
+	return checkDER(der, 0)
+}
+
+// checkDER implements CheckDER. offset is the offset of der in the original
+// input, for errors.
+func checkDER(der []byte, offset int) error {
+	for len(der) != 0 {
+		start := offset
+
+		// Parse the tag.
+		b := der[0]
+		constructed := b&0x20 != 0
+		number := uint32(b & 0x1f)
+		der, offset = der[1:], offset+1
+		if number == 0x1f {
+			if len(der) == 0 {
+				return &DERError{offset, errors.New("truncated tag")}
+			}
+			if der[0] == 0x80 {
+				return &DERError{offset, errors.New("tag number has a leading zero byte")}
+			}
+			number = 0
+			for {
+				if len(der) == 0 {
+					return &DERError{offset, errors.New("truncated tag")}
+				}
+				if number > 0xffffffff>>7 {
+					return &DERError{offset, errors.New("tag number does not fit in 32 bits")}
+				}
+				c := der[0]
+				number = number<<7 | uint32(c&0x7f)
+				der, offset = der[1:], offset+1
+				if c&0x80 == 0 {
+					break
+				}
+			}
+			if number < 0x1f {
+				return &DERError{start + 1, fmt.Errorf("tag number %d should use the low-tag-number form", number)}
+			}
+		}
+
+		// Parse the length.
+		if len(der) == 0 {
+			return &DERError{offset, errors.New("truncated length")}
+		}
+		lengthOffset := offset
+		length := int(der[0])
+		der, offset = der[1:], offset+1
+		switch {
+		case length == 0x80:
+			return &DERError{lengthOffset, errors.New("indefinite length is not allowed in DER")}
+		case length == 0xff:
+			return &DERError{lengthOffset, errors.New("invalid length byte 0xff")}
+		case length > 0x80:
+			n := length & 0x7f
+			if len(der) < n {
+				return &DERError{offset, errors.New("truncated length")}
+			}
+			if der[0] == 0 {
+				return &DERError{lengthOffset, errors.New("length has a leading zero byte")}
+			}
+			length = 0
+			for i := 0; i < n; i++ {
+				if length > (int(^uint(0)>>1))>>8 {
+					return &DERError{lengthOffset, errors.New("length too large")}
+				}
+				length = length<<8 | int(der[i])
+			}
+			if length < 0x80 {
+				return &DERError{lengthOffset, fmt.Errorf("length %d should use the short form", length)}
+			}
+			der, offset = der[n:], offset+n
+		}
+		if len(der) < length {
+			return &DERError{lengthOffset, fmt.Errorf("length %d exceeds the %d remaining bytes", length, len(der))}
+		}
+		body := der[:length]
+
+		// Check the contents.
+		if constructed {
+			if err := checkDER(body, offset); err != nil {
+				return err
+			}
+		} else if b&0xc0 == 0 {
+			if err := checkPrimitive(number, body, offset); err != nil {
+				return err
+			}
+		}
+		der, offset = der[length:], offset+length
+	}
+	return nil
+}
+
+// checkPrimitive checks the contents, body, of a primitive element with
+// universal tag number. offset is the offset of body in the original input.
+func checkPrimitive(number uint32, body []byte, offset int) error {
+	switch number {
+	case 2, 10: // INTEGER, ENUMERATED
+		if len(body) == 0 {
+			return &DERError{offset, errors.New("empty integer")}
+		}
+		if len(body) > 1 && ((body[0] == 0 && body[1]&0x80 == 0) || (body[0] == 0xff && body[1]&0x80 != 0)) {
+			return &DERError{offset, errors.New("integer is not minimally encoded")}
+		}
+	case 6, 13: // OBJECT IDENTIFIER, RELATIVE-OID
+		if len(body) == 0 {
+			return &DERError{offset, errors.New("empty OID")}
+		}
+		arcStart := true
+		for i, c := range body {
+			if arcStart && c == 0x80 {
+				return &DERError{offset + i, errors.New("OID arc has a leading zero byte")}
+			}
+			arcStart = c&0x80 == 0
+		}
+		if !arcStart {
+			return &DERError{offset + len(body) - 1, errors.New("truncated OID arc")}
+		}
+	case 23: // UTCTime
+		if _, err := lib.ParseUTCTime(string(body)); err != nil {
+			return &DERError{offset, err}
+		}
+	case 24: // GeneralizedTime
+		if _, err := lib.ParseGeneralizedTime(string(body)); err != nil {
+			return &DERError{offset, err}
+		}
+	}
+	return nil
+}
+
+// tagLength returns the length of the tag at the start of der, which must be
+// non-empty. If the tag is truncated, the result exceeds len(der).
+func tagLength(der []byte) int {
+	n := 1
+	if der[0]&0x1f == 0x1f {
+		for n < len(der) && der[n]&0x80 != 0 {
+			n++
+		}
+		n++
+	}
+	return n
+}
+
+// splitElements splits der into a series of definite-length elements. It
+// returns false if der is not a series of elements. Unlike CheckDER, it does not
+// require DER.
+func splitElements(der []byte) ([][]byte, bool) {
+	var elems [][]byte
+	for len(der) != 0 {
+		// Skip the tag.
+		n := tagLength(der)
+		if n >= len(der) {
+			return nil, false
+		}
+
+		// Parse the length.
+		length := int(der[n])
+		n++
+		if length == 0x80 {
+			return nil, false
+		}
+		if length > 0x80 {
+			lenLen := length & 0x7f
+			if lenLen > len(der)-n || lenLen > 4 {
+				return nil, false
+			}
+			length = 0
+			for _, b := range der[n : n+lenLen] {
+				length = length<<8 | int(b)
+			}
+			n += lenLen
+		}
+		if length > len(der)-n {
+			return nil, false
+		}
+		elems = append(elems, der[:n+length])
+		der = der[n+length:]
+	}
+	return elems, true
+}
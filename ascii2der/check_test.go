@@ -0,0 +1,124 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ascii2der
+
+import (
+	"reflect"
+	"testing"
+)
+
+var checkDERTests = []struct {
+	in     []byte
+	ok     bool
+	offset int
+}{
+	{[]byte{}, true, 0},
+	{[]byte{0x30, 0x06, 0x02, 0x01, 0x01, 0x06, 0x01, 0x2a}, true, 0},
+	{[]byte{0x02, 0x01, 0x00, 0x02, 0x02, 0x00, 0x80, 0x02, 0x02, 0xff, 0x7f}, true, 0},
+	{[]byte{0x9f, 0x1f, 0x00, 0xbf, 0x81, 0x00, 0x00}, true, 0},
+	{append([]byte{0x04, 0x81, 0x80}, make([]byte, 128)...), true, 0},
+	// Other universal types are not checked.
+	{[]byte{0x01, 0x01, 0x01}, true, 0},
+	// Tags.
+	{[]byte{0x9f}, false, 1},
+	{[]byte{0x9f, 0x1e, 0x00}, false, 1},
+	{[]byte{0x9f, 0x80, 0x01, 0x00}, false, 1},
+	{[]byte{0x9f, 0x81}, false, 2},
+	{[]byte{0x9f, 0x90, 0x80, 0x80, 0x80, 0x80, 0x00, 0x00}, false, 5},
+	// Lengths.
+	{[]byte{0x30}, false, 1},
+	{[]byte{0x30, 0x80, 0x00, 0x00}, false, 1},
+	{[]byte{0x30, 0xff}, false, 1},
+	{[]byte{0x30, 0x81}, false, 2},
+	{[]byte{0x30, 0x81, 0x01, 0x00}, false, 1},
+	{[]byte{0x30, 0x82, 0x00, 0x80}, false, 1},
+	{[]byte{0x30, 0x02, 0x00}, false, 1},
+	{[]byte{0x30, 0x03, 0x02, 0x81, 0x00}, false, 3},
+	// Integers.
+	{[]byte{0x02, 0x00}, false, 2},
+	{[]byte{0x02, 0x02, 0x00, 0x01}, false, 2},
+	{[]byte{0x0a, 0x02, 0xff, 0x80}, false, 2},
+	{[]byte{0x30, 0x05, 0x02, 0x01, 0x01, 0x02, 0x00}, false, 7},
+	// OIDs.
+	{[]byte{0x06, 0x00}, false, 2},
+	{[]byte{0x06, 0x03, 0x2a, 0x80, 0x01}, false, 3},
+	{[]byte{0x06, 0x02, 0x2a, 0x81}, false, 3},
+	{[]byte{0x0d, 0x02, 0x80, 0x01}, false, 2},
+	// Times.
+	{append([]byte{0x17, 0x0d}, "210101000000Z"...), true, 0},
+	{append([]byte{0x17, 0x0b}, "2101010000Z"...), false, 2},
+	{append([]byte{0x18, 0x11}, "20210101000000.5Z"...), true, 0},
+	{append([]byte{0x18, 0x11}, "20210101000000.0Z"...), false, 2},
+	{append([]byte{0x18, 0x11}, "20210101000000,5Z"...), false, 2},
+	{append([]byte{0x18, 0x13}, "20210101000000+0100"...), false, 2},
+	// Implicitly-tagged contents are not checked.
+	{[]byte{0x80, 0x02, 0x00, 0x01}, true, 0},
+}
+
+func TestCheckDER(t *testing.T) {
+	for i, tt := range checkDERTests {
+		err := CheckDER(tt.in)
+		if tt.ok {
+			if err != nil {
+				t.Errorf("%d. CheckDER(%x) unexpectedly failed: %s.", i, tt.in, err)
+			}
+			continue
+		}
+		derErr, ok := err.(*DERError)
+		if !ok {
+			t.Errorf("%d. CheckDER(%x) gave error %v, wanted a *DERError.", i, tt.in, err)
+		} else if derErr.Offset != tt.offset {
+			t.Errorf("%d. CheckDER(%x) gave error at offset %d, wanted %d: %s.", i, tt.in, derErr.Offset, tt.offset, err)
+		}
+	}
+}
+
+func TestConvertCheckDER(t *testing.T) {
+	const in = "SEQUENCE { INTEGER { `0001` } }"
+	if _, err := Convert(in); err != nil {
+		t.Errorf("Convert failed: %s.", err)
+	}
+	_, err := Options{CheckDER: true}.Convert(in)
+	if want := "offset 4: integer is not minimally encoded"; err == nil || err.Error() != want {
+		t.Errorf("Convert with CheckDER failed with %v, wanted %q.", err, want)
+	}
+}
+
+var splitElementsTests = []struct {
+	in    []byte
+	elems [][]byte
+	ok    bool
+}{
+	{[]byte{}, nil, true},
+	{[]byte{0x02, 0x01, 0x01, 0x30, 0x00}, [][]byte{{0x02, 0x01, 0x01}, {0x30, 0x00}}, true},
+	// Non-minimal encodings are allowed.
+	{[]byte{0x9f, 0x01, 0x81, 0x01, 0xaa}, [][]byte{{0x9f, 0x01, 0x81, 0x01, 0xaa}}, true},
+	{[]byte{0x02}, nil, false},
+	{[]byte{0x02, 0x02, 0x01}, nil, false},
+	{[]byte{0x9f, 0x81}, nil, false},
+	{[]byte{0x30, 0x81}, nil, false},
+	{[]byte{0x30, 0x80, 0x00, 0x00}, nil, false},
+}
+
+func TestSplitElements(t *testing.T) {
+	for i, tt := range splitElementsTests {
+		elems, ok := splitElements(tt.in)
+		if ok != tt.ok {
+			t.Errorf("%d. splitElements(%x) returned ok=%v, wanted %v.", i, tt.in, ok, tt.ok)
+		} else if ok && !reflect.DeepEqual(elems, tt.elems) {
+			t.Errorf("%d. splitElements(%x) = %x, wanted %x.", i, tt.in, elems, tt.elems)
+		}
+	}
+}
@@ -12,96 +12,111 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package ascii2der
 
-import "github.com/google/der-ascii/lib"
+import (
+	"fmt"
+	"unicode/utf16"
+	"unicode/utf8"
 
-func appendBase128(dst []byte, value uint32) []byte {
-	// Special-case: zero is encoded with one, not zero bytes.
-	if value == 0 {
-		return append(dst, 0)
-	}
-	// Count how many bytes are needed.
-	var l int
-	for n := value; n != 0; n >>= 7 {
-		l++
+	"github.com/google/der-ascii/lib"
+)
+
+// appendLongFormLength marshals the given length in the long form, using
+// exactly width bytes, and appends the result to dst, returning the updated
+// slice. This may not be a valid DER encoding. If the length does not fit, it
+// returns false and leaves dst unchanged.
+func appendLongFormLength(dst []byte, length, width int) ([]byte, bool) {
+	if width < 8 && length>>uint(8*width) != 0 {
+		return dst, false
 	}
-	for ; l > 0; l-- {
-		b := byte(value>>uint(7*(l-1))) & 0x7f
-		if l > 1 {
-			b |= 0x80
+	dst = append(dst, 0x80|byte(width))
+	for ; width > 0; width-- {
+		if width > 8 {
+			dst = append(dst, 0)
+		} else {
+			dst = append(dst, byte(length>>uint(8*(width-1))))
 		}
-		dst = append(dst, b)
 	}
-	return dst
+	return dst, true
 }
 
-// appendTag marshals the given tag and appends the result to dst, returning the
-// updated slice.
-func appendTag(dst []byte, tag lib.Tag) []byte {
-	b := byte(tag.Class)
-	if tag.Constructed {
-		b |= 0x20
-	}
-	if tag.Number < 0x1f {
-		// Low-tag-number form.
-		b |= byte(tag.Number)
-		return append(dst, b)
-	}
-
-	// High-tag-number form.
-	b |= 0x1f
-	dst = append(dst, b)
-	return appendBase128(dst, tag.Number)
+// algorithms maps the names of algorithms supported by the algorithm function
+// to whether their AlgorithmIdentifier has NULL parameters, rather than omitting
+// them. Algorithms with other parameters, such as id-RSASSA-PSS and
+// id-ecPublicKey, are not included.
+var algorithms = map[string]bool{
+	// RSA algorithms use NULL parameters (RFC 8017, RFC 4055).
+	"rsaEncryption":           true,
+	"md5WithRSAEncryption":    true,
+	"sha1WithRSAEncryption":   true,
+	"sha224WithRSAEncryption": true,
+	"sha256WithRSAEncryption": true,
+	"sha384WithRSAEncryption": true,
+	"sha512WithRSAEncryption": true,
+	// ECDSA, EdDSA, and DSA signatures omit parameters (RFC 5758, RFC 8410).
+	"ecdsa-with-SHA1":    false,
+	"ecdsa-with-SHA256":  false,
+	"ecdsa-with-SHA384":  false,
+	"ecdsa-with-SHA512":  false,
+	"id-Ed25519":         false,
+	"id-Ed448":           false,
+	"id-X25519":          false,
+	"id-X448":            false,
+	"id-dsa-with-sha1":   false,
+	"id-dsa-with-sha256": false,
+	// Hash functions omit parameters (RFC 5754), except MD5, whose
+	// parameters are NULL (RFC 1321).
+	"id-md5":    true,
+	"id-sha1":   false,
+	"id-sha224": false,
+	"id-sha256": false,
+	"id-sha384": false,
+	"id-sha512": false,
 }
 
-// appendLength marshals the given length in DER and appends the result to dst,
-// returning the updated slice.
-func appendLength(dst []byte, length int) []byte {
-	if length < 0x80 {
-		// Short-form length.
-		return append(dst, byte(length))
+// appendAlgorithmIdentifier marshals the AlgorithmIdentifier for the algorithm
+// with the given name, which must be in algorithms, as a complete SEQUENCE
+// element and appends the result to dst. It returns an error if the algorithm
+// is unknown. In that case, dst is unmodified.
+func appendAlgorithmIdentifier(dst []byte, name string) ([]byte, error) {
+	null, ok := algorithms[name]
+	if !ok {
+		return dst, fmt.Errorf("unknown algorithm '%s'", name)
 	}
-
-	// Long-form length. Count how many bytes are needed.
-	var l byte
-	for n := length; n != 0; n >>= 8 {
-		l++
+	oid, ok := lib.OIDByName(name)
+	if !ok {
+		return dst, fmt.Errorf("no OID for algorithm '%s'", name)
 	}
-	dst = append(dst, 0x80|l)
-	for ; l > 0; l-- {
-		dst = append(dst, byte(length>>uint(8*(l-1))))
+	contents, err := lib.AppendObjectIdentifier(nil, oid)
+	if err != nil {
+		return dst, err
 	}
-	return dst
-}
-
-// appendInteger marshals the given value as the contents of a DER INTEGER and
-// appends the result to dst, returning the updated slice.
-func appendInteger(dst []byte, value int64) []byte {
-	// Count how many bytes are needed.
-	l := 1
-	for n := value; n > 0x7f || n < (0x80-0x100); n >>= 8 {
-		l++
+	var body []byte
+	body = lib.AppendTag(body, lib.Tag{Class: lib.ClassUniversal, Number: 6})
+	body = lib.AppendLength(body, len(contents))
+	body = append(body, contents...)
+	if null {
+		body = append(body, 0x05, 0x00)
 	}
-
-	for ; l > 0; l-- {
-		dst = append(dst, byte(value>>uint(8*(l-1))))
-	}
-	return dst
+	dst = lib.AppendTag(dst, lib.Tag{Class: lib.ClassUniversal, Number: 16, Constructed: true})
+	dst = lib.AppendLength(dst, len(body))
+	return append(dst, body...), nil
 }
 
-func appendObjectIdentifier(dst []byte, value []uint32) ([]byte, bool) {
-	// Validate the input before anything is written.
-	if len(value) < 2 || value[0] > 2 || (value[0] < 2 && value[1] > 39) {
-		return dst, false
-	}
-	if value[0]*40+value[1] < value[1] {
-		return dst, false
-	}
-
-	dst = appendBase128(dst, value[0]*40+value[1])
-	for _, v := range value[2:] {
-		dst = appendBase128(dst, v)
+// appendRune marshals r in the given encoding and appends the result to dst,
+// returning the updated slice. UTF-16 and UTF-32 are encoded big-endian, as in
+// BMPString and UniversalString.
+func appendRune(dst []byte, r rune, enc stringEncoding) []byte {
+	switch enc {
+	case encodingUTF16:
+		if r1, r2 := utf16.EncodeRune(r); r1 != utf8.RuneError {
+			return append(dst, byte(r1>>8), byte(r1), byte(r2>>8), byte(r2))
+		}
+		return append(dst, byte(r>>8), byte(r))
+	case encodingUTF32:
+		return append(dst, byte(r>>24), byte(r>>16), byte(r>>8), byte(r))
+	default:
+		return utf8.AppendRune(dst, r)
 	}
-	return dst, true
 }
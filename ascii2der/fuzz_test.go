@@ -0,0 +1,61 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ascii2der
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// FuzzConvert checks that assembling arbitrary input returns an error rather
+// than panicking, and that Convert and ConvertReader agree. Run it with:
+//
+//	go test -fuzz=FuzzConvert ./ascii2der
+func FuzzConvert(f *testing.F) {
+	for _, tt := range scannerTests {
+		f.Add(tt.in)
+	}
+	if language, err := ioutil.ReadFile("../language.txt"); err == nil {
+		f.Add(string(language))
+	}
+
+	// Bound the output size, so the fuzzer does not run out of memory.
+	opts := Options{MaxRepeatSize: 1 << 16}
+	f.Fuzz(func(t *testing.T, in string) {
+		out, err := opts.Convert(in)
+		outReader, errReader := opts.ConvertReader(strings.NewReader(in))
+		if (err == nil) != (errReader == nil) {
+			t.Fatalf("Convert and ConvertReader disagree on %q: %v vs %v.", in, err, errReader)
+		}
+		if err == nil && !bytes.Equal(out, outReader) {
+			t.Fatalf("Convert(%q) = %x, but ConvertReader returned %x.", in, out, outReader)
+		}
+		if err != nil {
+			return
+		}
+
+		// Any output which passes the DER check must also assemble with
+		// CheckDER set.
+		if CheckDER(out) == nil {
+			checked := opts
+			checked.CheckDER = true
+			if _, err := checked.Convert(in); err != nil {
+				t.Fatalf("Convert(%q) with CheckDER failed: %s.", in, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,133 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ascii2der
+
+import (
+	"sort"
+
+	"github.com/google/der-ascii/lib"
+)
+
+// maxLengthSize is the size of the widest DER length, a long-form length of an
+// int.
+const maxLengthSize = 9
+
+// A gap is a range of bytes, reserved for a length, which the length did not
+// use.
+type gap struct {
+	offset, length int
+	// total is the sum of the lengths of this and every earlier gap.
+	total int
+}
+
+// A gapList collects gaps while assembling. Each block's length is not known
+// until its contents are assembled, so maxLengthSize bytes are reserved for it
+// and the unused bytes are removed in a single pass by compact, rather than
+// moving the contents once per level of nesting. As with sourceMap, blocks
+// assembled into their own buffer record gaps relative to that buffer.
+type gapList struct {
+	gaps []gap
+}
+
+// mark returns a marker for the gaps recorded from this point, for use with the
+// other methods.
+func (g *gapList) mark() int {
+	return len(g.gaps)
+}
+
+// add records that length bytes at offset are unused.
+func (g *gapList) add(offset, length int) {
+	if length == 0 {
+		return
+	}
+	g.gaps = append(g.gaps, gap{offset, length, g.since(0) + length})
+}
+
+// since returns the number of unused bytes recorded since mark.
+func (g *gapList) since(mark int) int {
+	if len(g.gaps) == 0 {
+		return 0
+	}
+	total := g.gaps[len(g.gaps)-1].total
+	if mark > 0 {
+		total -= g.gaps[mark-1].total
+	}
+	return total
+}
+
+// reset discards the gaps recorded since mark.
+func (g *gapList) reset(mark int) {
+	g.gaps = g.gaps[:mark]
+}
+
+// reserve appends maxLengthSize bytes to dst, to be filled in with a length by
+// fill, and returns the updated slice.
+func reserve(dst []byte) []byte {
+	var buf [maxLengthSize]byte
+	return append(dst, buf[:]...)
+}
+
+// fill writes the DER encoding of length into the bytes reserved at dst[offset]
+// and records the bytes it did not use. It returns the size of the encoding.
+func (g *gapList) fill(dst []byte, offset, length int) int {
+	n := len(lib.AppendLength(dst[offset:offset], length))
+	g.add(offset+n, maxLengthSize-n)
+	return n
+}
+
+// compact removes the gaps recorded since mark from buf, which must contain
+// them, and returns the updated slice. It adjusts the mappings in sm recorded
+// since smMark to match, and discards the gaps.
+func (g *gapList) compact(buf []byte, mark int, sm *sourceMap, smMark int) []byte {
+	if mark == len(g.gaps) {
+		return buf
+	}
+	// Gaps are recorded as blocks end, so inner gaps come before the outer
+	// gaps which precede them.
+	gaps := append([]gap{}, g.gaps[mark:]...)
+	g.reset(mark)
+	sort.Slice(gaps, func(i, j int) bool { return gaps[i].offset < gaps[j].offset })
+	// removed[i] is the number of bytes removed before the end of gaps[i].
+	removed := make([]int, len(gaps))
+	for i, gp := range gaps {
+		removed[i] = gp.length
+		if i > 0 {
+			removed[i] += removed[i-1]
+		}
+	}
+	if sm != nil {
+		// Mappings never begin or end within a gap.
+		move := func(offset int) int {
+			i := sort.Search(len(gaps), func(i int) bool { return gaps[i].offset >= offset })
+			if i == 0 {
+				return offset
+			}
+			return offset - removed[i-1]
+		}
+		for i := smMark; i < len(sm.mappings); i++ {
+			sm.mappings[i].Start = move(sm.mappings[i].Start)
+			sm.mappings[i].End = move(sm.mappings[i].End)
+		}
+	}
+	out := gaps[0].offset
+	for i, gp := range gaps {
+		end := len(buf)
+		if i+1 < len(gaps) {
+			end = gaps[i+1].offset
+		}
+		out += copy(buf[out:], buf[gp.offset+gp.length:end])
+	}
+	return buf[:out]
+}
@@ -12,78 +12,287 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+// Package ascii2der implements the DER ASCII assembler, which converts DER ASCII
+// to a byte string. See language.txt for the language specification.
+package ascii2der
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/google/der-ascii/lib"
 )
 
-// A position describes a location in the input stream.
-type position struct {
+// A Position describes a location in the input stream.
+type Position struct {
 	Offset int // offset, starting at 0
 	Line   int // line number, starting at 1
 	Column int // column number, starting at 1 (byte count)
 }
 
-// A tokenKind is a kind of token.
-type tokenKind int
+// A TokenKind is a kind of token.
+type TokenKind int
 
 const (
-	tokenBytes tokenKind = iota
-	tokenLeftCurly
-	tokenRightCurly
-	tokenEOF
+	TokenBytes      TokenKind = iota // a literal, tag, or function emitting bytes
+	TokenLeftCurly                   // {
+	TokenRightCurly                  // }
+	TokenIndefinite                  // indefinite
+	TokenSetOf                       // set-of
+	TokenLongForm                    // long-form(N)
+	TokenRepeat                      // repeat(N)
+	TokenBitsUnused                  // bits-unused(N)
+	TokenDefine                      // define NAME
+	TokenUse                         // use NAME
+	TokenInclude                     // include "PATH"
+	TokenImplicit                    // implicit
+	TokenExplicit                    // explicit
+	TokenRaw                         // raw
+	TokenConcat                      // concat(...)
+	TokenExpectLen                   // expect-len(N)
+	TokenTruncate                    // truncate(N)
+	TokenEOF                         // the end of the input
 )
 
-// A parseError is an error during parsing DER ASCII.
-type parseError struct {
-	Pos position
+// A ParseError is an error during parsing DER ASCII.
+type ParseError struct {
+	Pos Position
 	Err error
 }
 
-func (t *parseError) Error() string {
-	return fmt.Sprintf("line %d: %s", t.Pos.Line, t.Err)
+func (t *ParseError) Error() string {
+	return fmt.Sprintf("line %d column %d: %s", t.Pos.Line, t.Pos.Column, t.Err)
 }
 
-// A token is a token in a DER ASCII file.
-type token struct {
+// A Token is a token in a DER ASCII file.
+type Token struct {
 	// Kind is the kind of the token.
-	Kind tokenKind
-	// Value, for a tokenBytes token, is the decoded value of the token in
+	Kind TokenKind
+	// Value, for a TokenBytes token, is the decoded value of the token in
 	// bytes.
 	Value []byte
+	// Tag, for a TokenBytes token which encodes a tag, is the decoded tag.
+	// Otherwise it is nil.
+	Tag *lib.Tag
+	// Arg, for a token which modifies the following block, is the integer
+	// argument to the modifier, if any.
+	Arg int
+	// Name, for a TokenDefine or TokenUse token, is the name of the macro.
+	// For a TokenInclude token, it is the path to include.
+	Name string
 	// Pos is the position of the first byte of the token.
-	Pos position
+	Pos Position
+	// End is the position immediately after the last byte of the token,
+	// including any name, path, or arguments consumed with it.
+	End Position
+	// args, for a TokenConcat token, scans the arguments.
+	args *Scanner
 }
 
+// A stringEncoding is the encoding used to emit the contents of a quoted
+// string.
+type stringEncoding int
+
+const (
+	encodingUTF8 stringEncoding = iota
+	encodingUTF16
+	encodingUTF32
+)
+
+// maxIntegerWidth is the largest width, in bytes, accepted by int-width.
+const maxIntegerWidth = 1024
+
 var (
-	regexpInteger = regexp.MustCompile(`^-?[0-9]+$`)
-	regexpOID     = regexp.MustCompile(`^[0-9]+(\.[0-9]+)+$`)
+	regexpInteger     = regexp.MustCompile(`^-?[0-9]+(_[0-9]+)*$`)
+	regexpOID         = regexp.MustCompile(`^[0-9]+(_[0-9]+)*(\.[0-9]+(_[0-9]+)*)+$`)
+	regexpRelativeOID = regexp.MustCompile(`^[0-9]+(_[0-9]+)*(\.[0-9]+(_[0-9]+)*)*$`)
+	// regexpNegativeOID matches OIDs where some arc is negative.
+	regexpNegativeOID   = regexp.MustCompile(`^-?[0-9_]+(\.-?[0-9_]+)+$`)
+	regexpHexInteger    = regexp.MustCompile(`^-?0x[0-9a-fA-F]+$`)
+	regexpBinaryInteger = regexp.MustCompile(`^-?0b[01]+$`)
+	// regexpNumeric matches tokens which resemble integers or OIDs, but
+	// possibly with misplaced digit separators.
+	regexpNumeric = regexp.MustCompile(`^-?[0-9_.]*[0-9][0-9_.]*$`)
 )
 
-type scanner struct {
-	text string
-	pos  position
+// A Scanner splits DER ASCII input into tokens. It is exposed for tools, such
+// as editors, which need the position of each token. Most callers should use
+// Convert instead.
+type Scanner struct {
+	// text is the buffered input, starting at offset base. If r is non-nil,
+	// further input is read from it as needed.
+	text    string
+	base    int
+	pos     Position
+	r       io.Reader
+	readErr error
+	// config contains settings from Options which affect scanning.
+	config scannerConfig
+	// strType, if non-nil, is the string type of the enclosing element. It
+	// restricts and checks the encoding of quoted strings.
+	strType *stringType
+	// failed is whether the last call to Next returned a syntax error, and
+	// inString is whether the error was within a quoted string. Both are
+	// cleared by resync.
+	failed, inString bool
+}
+
+// A scannerConfig contains the settings from Options which affect scanning.
+type scannerConfig struct {
+	// allowLeadingZeros, if true, allows leading zeros in decimal integers
+	// and OID arcs.
+	allowLeadingZeros bool
+	// loose, if true, emits unrecognized symbols as their ASCII bytes.
+	loose bool
+	// tags, if non-nil, names tags in addition to the built-in names.
+	tags *lib.TagTable
+	// ignoreTagCase, if true, matches tag names case-insensitively.
+	ignoreTagCase bool
+	// allowInvalidStrings, if true, allows quoted strings to contain
+	// characters their string type does not permit.
+	allowInvalidStrings bool
+	// warn, if non-nil, is called with advisories about the input.
+	warn func(*ParseError)
+}
+
+// A stringType describes the contents of some universal string type.
+type stringType struct {
+	name string
+	// enc is the encoding of the type's characters.
+	enc stringEncoding
+	// valid, if non-nil, returns whether the type permits r.
+	valid func(r rune) bool
+}
+
+// stringTypes maps universal tag numbers to the string types they represent.
+var stringTypes = map[uint32]*stringType{
+	12: {"UTF8String", encodingUTF8, nil},
+	18: {"NumericString", encodingUTF8, func(r rune) bool { return r == ' ' || ('0' <= r && r <= '9') }},
+	19: {"PrintableString", encodingUTF8, func(r rune) bool {
+		return ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') || strings.ContainsRune(" '()+,-./:=?", r)
+	}},
+	20: {"T61String", encodingUTF8, nil},
+	21: {"VideotexString", encodingUTF8, nil},
+	22: {"IA5String", encodingUTF8, nil},
+	25: {"GraphicString", encodingUTF8, nil},
+	26: {"VisibleString", encodingUTF8, nil},
+	27: {"GeneralString", encodingUTF8, nil},
+	28: {"UniversalString", encodingUTF32, nil},
+	30: {"BMPString", encodingUTF16, nil},
+}
+
+// NewScanner returns a Scanner which reads its input from text.
+func NewScanner(text string) *Scanner {
+	return &Scanner{text: text, pos: Position{Line: 1, Column: 1}}
+}
+
+// NewReaderScanner returns a Scanner which incrementally reads its input from
+// r. Only the input for the current token is buffered.
+func NewReaderScanner(r io.Reader) *Scanner {
+	return &Scanner{r: r, pos: Position{Line: 1, Column: 1}}
+}
+
+// Next returns the next token. Syntax errors are returned as a *ParseError. If
+// reading the input fails, it returns the read error. Once the input is
+// exhausted, Next returns TokenEOF tokens.
+func (s *Scanner) Next() (Token, error) {
+	tok, err := s.next()
+	if s.readErr != nil {
+		return Token{}, s.readErr
+	}
+	if err != nil {
+		s.failed = true
+		return Token{}, err
+	}
+	s.inString = false
+	tok.End = s.pos
+	return tok, nil
+}
+
+// resync, after Next returns a syntax error, skips the rest of the offending
+// token. It stops at the next whitespace or curly brace, after first skipping
+// to the end of the quoted string if the error was within one.
+func (s *Scanner) resync() {
+	if !s.failed {
+		return
+	}
+	if s.inString {
+		for !s.isEOF() && s.cur() != '"' {
+			if s.cur() == '\\' {
+				s.advance()
+				if s.isEOF() {
+					break
+				}
+			}
+			s.advance()
+		}
+		if !s.isEOF() {
+			s.advance()
+		}
+	}
+	s.failed, s.inString = false, false
+	for !s.isEOF() {
+		switch s.cur() {
+		case ' ', '\t', '\n', '\r', '{', '}':
+			return
+		}
+		s.advance()
+	}
 }
 
-func newScanner(text string) *scanner {
-	return &scanner{text: text, pos: position{Line: 1}}
+// skipBlock skips tokens up to and including the right curly brace which
+// matches an already-consumed left curly brace, or to EOF if there is none.
+// Syntax errors within the block are ignored.
+func (s *Scanner) skipBlock() {
+	for depth := 1; depth > 0; {
+		start := s.pos
+		token, err := s.Next()
+		if s.readErr != nil {
+			return
+		}
+		if err != nil {
+			s.resync()
+			if s.pos.Offset == start.Offset && !s.isEOF() {
+				s.advance()
+			}
+			continue
+		}
+		switch token.Kind {
+		case TokenLeftCurly:
+			depth++
+		case TokenRightCurly:
+			depth--
+		case TokenEOF:
+			return
+		}
+	}
 }
 
-func (s *scanner) Next() (token, error) {
+func (s *Scanner) next() (Token, error) {
 again:
+	if s.r != nil {
+		s.discard()
+	}
 	if s.isEOF() {
-		return token{Kind: tokenEOF, Pos: s.pos}, nil
+		return Token{Kind: TokenEOF, Pos: s.pos}, nil
 	}
 
-	switch s.text[s.pos.Offset] {
+	start := s.pos
+	switch s.cur() {
 	case ' ', '\t', '\n', '\r':
 		// Skip whitespace.
 		s.advance()
@@ -92,197 +301,1938 @@ again:
 		// Skip to the end of the comment.
 		s.advance()
 		for !s.isEOF() {
-			wasNewline := s.text[s.pos.Offset] == '\n'
+			wasNewline := s.cur() == '\n' || s.cur() == '\r'
 			s.advance()
 			if wasNewline {
 				break
 			}
 		}
 		goto again
+	case '/':
+		if s.isBlockComment() {
+			if !s.skipBlockComment() {
+				return Token{}, &ParseError{start, errors.New("unterminated /* comment")}
+			}
+			goto again
+		}
 	case '{':
 		s.advance()
-		return token{Kind: tokenLeftCurly, Pos: s.pos}, nil
+		return Token{Kind: TokenLeftCurly, Pos: start}, nil
 	case '}':
 		s.advance()
-		return token{Kind: tokenRightCurly, Pos: s.pos}, nil
+		return Token{Kind: TokenRightCurly, Pos: start}, nil
 	case '"':
-		s.advance()
-		start := s.pos
-		var bytes []byte
-		for {
-			if s.isEOF() {
-				return token{}, &parseError{start, errors.New("unmatched \"")}
-			}
-			switch c := s.text[s.pos.Offset]; c {
-			case '"':
-				s.advance()
-				return token{Kind: tokenBytes, Value: bytes, Pos: start}, nil
-			case '\\':
-				s.advance()
-				if s.isEOF() {
-					return token{}, &parseError{s.pos, errors.New("expected escape character")}
-				}
-				switch c2 := s.text[s.pos.Offset]; c2 {
-				case 'n':
-					bytes = append(bytes, '\n')
-				case '"', '\\':
-					bytes = append(bytes, c2)
-				case 'x':
-					s.advance()
-					if s.pos.Offset+2 > len(s.text) {
-						return token{}, &parseError{s.pos, errors.New("unfinished escape sequence")}
-					}
-					b, err := hex.DecodeString(s.text[s.pos.Offset : s.pos.Offset+2])
-					if err != nil {
-						return token{}, &parseError{s.pos, err}
-					}
-					bytes = append(bytes, b[0])
-					s.advance()
-				default:
-					return token{}, &parseError{s.pos, fmt.Errorf("unknown escape sequence \\%c", c2)}
-				}
-			default:
-				bytes = append(bytes, c)
-			}
-			s.advance()
-		}
+		return s.parseStringLiteral(start, encodingUTF8)
 	case '`':
 		s.advance()
+		hexPos := s.pos
 		hexStr, ok := s.consumeUpTo('`')
 		if !ok {
-			return token{}, &parseError{s.pos, errors.New("unmatched `")}
+			return Token{}, &ParseError{start, errors.New("unmatched `")}
 		}
-		bytes, err := hex.DecodeString(hexStr)
+		bytes, err := decodeHex(hexStr, hexPos)
 		if err != nil {
-			return token{}, &parseError{s.pos, err}
+			return Token{}, err
 		}
-		return token{Kind: tokenBytes, Value: bytes, Pos: s.pos}, nil
+		return Token{Kind: TokenBytes, Value: bytes, Pos: start}, nil
+	case '|':
+		s.advance()
+		b64Pos := s.pos
+		b64Str, ok := s.consumeUpTo('|')
+		if !ok {
+			return Token{}, &ParseError{start, errors.New("unmatched |")}
+		}
+		bytes, err := decodeBase64(b64Str, b64Pos)
+		if err != nil {
+			return Token{}, err
+		}
+		return Token{Kind: TokenBytes, Value: bytes, Pos: start}, nil
 	case '[':
 		s.advance()
 		tagStr, ok := s.consumeUpTo(']')
 		if !ok {
-			return token{}, &parseError{s.pos, errors.New("unmatched [")}
+			return Token{}, &ParseError{start, errors.New("unmatched [")}
 		}
-		tag, err := decodeTagString(tagStr)
+		tag, err := decodeTagString(tagStr, s.config.tags)
 		if err != nil {
-			return token{}, &parseError{s.pos, err}
+			return Token{}, &ParseError{start, err}
 		}
-		return token{Kind: tokenBytes, Value: appendTag(nil, tag), Pos: s.pos}, nil
+		return Token{Kind: TokenBytes, Value: lib.AppendTag(nil, tag), Tag: &tag, Pos: start}, nil
 	}
 
 	// Normal token. Consume up to the next whitespace character, symbol, or
 	// EOF.
-	start := s.pos
 	s.advance()
 loop:
 	for !s.isEOF() {
-		switch s.text[s.pos.Offset] {
-		case ' ', '\t', '\n', '\r', '{', '}', '[', ']', '`', '"', '#':
+		switch s.cur() {
+		case ' ', '\t', '\n', '\r', '{', '}', '[', ']', '(', ')', '`', '|', '"', '#':
 			break loop
+		case '/':
+			if s.isBlockComment() {
+				break loop
+			}
+			s.advance()
 		default:
 			s.advance()
 		}
 	}
 
-	symbol := s.text[start.Offset:s.pos.Offset]
+	symbol := s.textFrom(start)
+
+	// See if it is a function.
+	if !s.isEOF() && s.cur() == '(' {
+		return s.parseFunction(symbol, start)
+	}
+
+	// See if it is a prefixed string.
+	if !s.isEOF() && s.cur() == '"' {
+		switch symbol {
+		case "u16":
+			return s.parseStringLiteral(start, encodingUTF16)
+		case "u32":
+			return s.parseStringLiteral(start, encodingUTF32)
+		}
+	}
+
+	switch symbol {
+	case "indefinite":
+		return Token{Kind: TokenIndefinite, Pos: start}, nil
+	case "set-of":
+		return Token{Kind: TokenSetOf, Pos: start}, nil
+	case "implicit":
+		return Token{Kind: TokenImplicit, Pos: start}, nil
+	case "explicit":
+		return Token{Kind: TokenExplicit, Pos: start}, nil
+	case "raw":
+		return Token{Kind: TokenRaw, Pos: start}, nil
+	}
+
+	// See if it is a macro keyword, which is followed by a name.
+	switch symbol {
+	case "define", "use":
+		s.skipWhitespace()
+		nameStart := s.pos
+		for !s.isEOF() && isMacroNameChar(s.cur()) {
+			s.advance()
+		}
+		name := s.textFrom(nameStart)
+		if len(name) == 0 {
+			return Token{}, &ParseError{nameStart, fmt.Errorf("expected macro name after '%s'", symbol)}
+		}
+		kind := TokenDefine
+		if symbol == "use" {
+			kind = TokenUse
+		}
+		return Token{Kind: kind, Name: name, Pos: start}, nil
+	}
+
+	if symbol == "include" {
+		s.skipWhitespace()
+		pathStart := s.pos
+		if s.isEOF() || s.cur() != '"' {
+			return Token{}, &ParseError{pathStart, errors.New("expected quoted path after 'include'")}
+		}
+		path, err := s.parseQuotedString(pathStart, encodingUTF8)
+		if err != nil {
+			return Token{}, err
+		}
+		return Token{Kind: TokenInclude, Name: string(path.Value), Pos: start}, nil
+	}
+
+	// A bare NULL, not followed by a length prefix, is shorthand for a
+	// complete NULL element.
+	if symbol == "NULL" && !s.peekLengthPrefix() {
+		return Token{Kind: TokenBytes, Value: []byte{0x05, 0x00}, Pos: start}, nil
+	}
 
-	// See if it is a tag.
+	// See if it is a tag, either built in or from the tag table.
 	tag, ok := lib.TagByName(symbol)
+	if !ok {
+		tag, ok = s.config.tags.TagByName(symbol)
+	}
 	if ok {
-		return token{Kind: tokenBytes, Value: appendTag(nil, tag), Pos: start}, nil
+		return Token{Kind: TokenBytes, Value: lib.AppendTag(nil, tag), Tag: &tag, Pos: start}, nil
+	}
+
+	// See if it is a named OID.
+	if oid, ok := lib.OIDByName(symbol); ok {
+		der, err := lib.AppendObjectIdentifier(nil, oid)
+		if err != nil {
+			return Token{}, &ParseError{start, fmt.Errorf("invalid OID '%s': %s", symbol, err)}
+		}
+		return Token{Kind: TokenBytes, Value: der, Pos: start}, nil
+	}
+
+	// See if it is an end-of-contents marker.
+	if symbol == "eoc" {
+		return Token{Kind: TokenBytes, Value: []byte{0x00, 0x00}, Pos: start}, nil
+	}
+
+	// See if it is a BOOLEAN value.
+	switch symbol {
+	case "TRUE":
+		return Token{Kind: TokenBytes, Value: []byte{0xff}, Pos: start}, nil
+	case "FALSE":
+		return Token{Kind: TokenBytes, Value: []byte{0x00}, Pos: start}, nil
 	}
 
 	if regexpInteger.MatchString(symbol) {
-		value, err := strconv.ParseInt(symbol, 10, 64)
+		if !s.config.allowLeadingZeros && hasLeadingZero(symbol) {
+			return Token{}, &ParseError{start, fmt.Errorf("integer '%s' has a leading zero", symbol)}
+		}
+		digits := stripDigitSeparators(symbol)
+		value, err := strconv.ParseInt(digits, 10, 64)
+		if err == nil {
+			return Token{Kind: TokenBytes, Value: lib.AppendInteger(nil, value), Pos: start}, nil
+		}
+		if numErr, ok := err.(*strconv.NumError); !ok || numErr.Err != strconv.ErrRange {
+			return Token{}, &ParseError{start, err}
+		}
+		// The value does not fit in an int64, so fall back to math/big.
+		bigValue, ok := new(big.Int).SetString(digits, 10)
+		if !ok {
+			return Token{}, &ParseError{start, fmt.Errorf("invalid integer '%s'", symbol)}
+		}
+		return Token{Kind: TokenBytes, Value: lib.AppendBigInteger(nil, bigValue), Pos: start}, nil
+	}
+
+	if regexpHexInteger.MatchString(symbol) || regexpBinaryInteger.MatchString(symbol) {
+		value, err := parsePrefixedInteger(symbol)
 		if err != nil {
-			return token{}, &parseError{start, err}
+			return Token{}, &ParseError{start, err}
 		}
-		return token{Kind: tokenBytes, Value: appendInteger(nil, value), Pos: s.pos}, nil
+		return Token{Kind: TokenBytes, Value: lib.AppendInteger(nil, value), Pos: start}, nil
 	}
 
 	if regexpOID.MatchString(symbol) {
-		oidStr := strings.Split(symbol, ".")
-		var oid []uint32
-		for _, s := range oidStr {
-			u, err := strconv.ParseUint(s, 10, 32)
-			if err != nil {
-				return token{}, &parseError{start, err}
+		if err := s.checkArcs(symbol); err != nil {
+			return Token{}, &ParseError{start, err}
+		}
+		oid, err := parseArcs(symbol)
+		if err != nil {
+			return Token{}, &ParseError{start, err}
+		}
+		der, err := lib.AppendObjectIdentifier(nil, oid)
+		if err != nil {
+			return Token{}, &ParseError{start, fmt.Errorf("invalid OID '%s': %s", symbol, err)}
+		}
+		return Token{Kind: TokenBytes, Value: der, Pos: start}, nil
+	}
+
+	// Tag names in another case are only matched once every other kind of
+	// symbol has been ruled out.
+	if s.config.ignoreTagCase {
+		if tag, canonical, ok := s.config.tags.TagByNameIgnoreCase(symbol); ok {
+			if s.config.warn != nil {
+				s.config.warn(&ParseError{start, fmt.Errorf("tag name '%s' should be spelled '%s'", symbol, canonical)})
 			}
-			oid = append(oid, uint32(u))
+			if canonical == "NULL" && !s.peekLengthPrefix() {
+				return Token{Kind: TokenBytes, Value: []byte{0x05, 0x00}, Pos: start}, nil
+			}
+			return Token{Kind: TokenBytes, Value: lib.AppendTag(nil, tag), Tag: &tag, Pos: start}, nil
 		}
-		der, ok := appendObjectIdentifier(nil, oid)
-		if !ok {
-			return token{}, errors.New("invalid OID")
+	}
+
+	if strings.Contains(symbol, "-") && regexpNegativeOID.MatchString(symbol) {
+		return Token{}, &ParseError{start, fmt.Errorf("invalid OID '%s': arcs may not be negative", symbol)}
+	}
+
+	if strings.Contains(symbol, "_") && regexpNumeric.MatchString(symbol) {
+		return Token{}, &ParseError{start, fmt.Errorf("misplaced digit separator in '%s'", symbol)}
+	}
+
+	if s.config.loose {
+		return Token{Kind: TokenBytes, Value: []byte(symbol), Pos: start}, nil
+	}
+	return Token{}, &ParseError{start, fmt.Errorf("unrecognized symbol '%s'", symbol)}
+}
+
+// parsePrefixedInteger parses symbol, which must match regexpHexInteger or
+// regexpBinaryInteger, as an int64.
+func parsePrefixedInteger(symbol string) (int64, error) {
+	digits, base := splitPrefixedInteger(symbol)
+	value, err := strconv.ParseInt(digits, base, 64)
+	if err != nil {
+		if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+			return 0, fmt.Errorf("integer '%s' does not fit in 64 bits", symbol)
+		}
+		return 0, err
+	}
+	return value, nil
+}
+
+// splitPrefixedInteger returns the signed digits of symbol, which must match
+// regexpHexInteger or regexpBinaryInteger, and their base.
+func splitPrefixedInteger(symbol string) (string, int) {
+	var sign string
+	if symbol[0] == '-' {
+		sign = "-"
+		symbol = symbol[1:]
+	}
+	base := 16
+	if symbol[1] == 'b' {
+		base = 2
+	}
+	return sign + symbol[2:], base
+}
+
+// decodeHex decodes the contents of a hex literal, str, which starts at pos.
+// Whitespace is ignored. Errors are reported at the offending byte.
+func decodeHex(str string, pos Position) ([]byte, error) {
+	start := pos
+	digits := make([]byte, 0, len(str))
+	for i := 0; i < len(str); i++ {
+		switch c := str[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+		case isHexDigit(c):
+			digits = append(digits, c)
+		default:
+			return nil, &ParseError{pos, fmt.Errorf("invalid hex digit %q", c)}
+		}
+		pos.advance(str[i], byteAfter(str, i))
+	}
+	if len(digits)%2 != 0 {
+		return nil, &ParseError{start, errors.New("odd number of hex digits")}
+	}
+	bytes := make([]byte, len(digits)/2)
+	if _, err := hex.Decode(bytes, digits); err != nil {
+		return nil, &ParseError{start, err}
+	}
+	return bytes, nil
+}
+
+// decodeBase64 decodes str, the contents of a base64 literal beginning at pos.
+// Whitespace is ignored.
+func decodeBase64(str string, pos Position) ([]byte, error) {
+	start := pos
+	// Record the position of each non-whitespace character so errors may be
+	// reported against the input.
+	chars := make([]byte, 0, len(str))
+	var positions []Position
+	for i := 0; i < len(str); i++ {
+		switch c := str[i]; c {
+		case ' ', '\t', '\n', '\r':
+		default:
+			chars = append(chars, c)
+			positions = append(positions, pos)
+		}
+		pos.advance(str[i], byteAfter(str, i))
+	}
+	if len(chars)%4 != 0 {
+		return nil, &ParseError{start, errors.New("base64 length is not a multiple of four")}
+	}
+	bytes, err := base64.StdEncoding.DecodeString(string(chars))
+	if err != nil {
+		off, ok := err.(base64.CorruptInputError)
+		if !ok || int(off) >= len(chars) {
+			return nil, &ParseError{start, err}
 		}
-		return token{Kind: tokenBytes, Value: der, Pos: s.pos}, nil
+		return nil, &ParseError{positions[off], fmt.Errorf("invalid base64 character %q", chars[off])}
 	}
+	return bytes, nil
+}
 
-	return token{}, fmt.Errorf("unrecognized symbol '%s'", symbol)
+// IsMacroName returns whether name is a valid argument to define and use, and
+// thus a valid name in Options.Macros.
+func IsMacroName(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isMacroNameChar(name[i]) {
+			return false
+		}
+	}
+	return true
 }
 
-func (s *scanner) isEOF() bool {
-	return s.pos.Offset >= len(s.text)
+func isMacroNameChar(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || c == '_' || c == '-'
 }
 
-func (s *scanner) advance() {
-	if !s.isEOF() {
-		if s.text[s.pos.Offset] == '\n' {
-			s.pos.Line++
-			s.pos.Column = 0
-		} else {
-			s.pos.Column++
+func isHexDigit(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+// parseArcs parses a dotted sequence of OID arcs, which may contain digit
+// separators.
+func parseArcs(str string) ([]uint32, error) {
+	var arcs []uint32
+	for i, s := range strings.Split(stripDigitSeparators(str), ".") {
+		u, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("arc %d (%s) does not fit in 32 bits", i+1, s)
 		}
-		s.pos.Offset++
+		arcs = append(arcs, uint32(u))
 	}
+	return arcs, nil
 }
 
-func (s *scanner) consumeUpTo(b byte) (string, bool) {
-	start := s.pos.Offset
-	for !s.isEOF() {
-		if s.text[s.pos.Offset] == b {
-			ret := s.text[start:s.pos.Offset]
-			s.advance()
-			return ret, true
+// hasLeadingZero returns whether str, a decimal integer or OID arc which may
+// have a sign and digit separators, has a leading zero. A lone zero is allowed.
+func hasLeadingZero(str string) bool {
+	digits := strings.TrimPrefix(stripDigitSeparators(str), "-")
+	return len(digits) > 1 && digits[0] == '0'
+}
+
+// checkArcs returns an error if some arc of oid, a dotted sequence of arcs, has
+// a leading zero and s does not allow them.
+func (s *Scanner) checkArcs(oid string) error {
+	if s.config.allowLeadingZeros {
+		return nil
+	}
+	for _, arc := range strings.Split(oid, ".") {
+		if hasLeadingZero(arc) {
+			return fmt.Errorf("OID arc '%s' has a leading zero", arc)
 		}
-		s.advance()
 	}
-	return "", false
+	return nil
+}
+
+// stripDigitSeparators removes the underscores from an integer or OID token.
+func stripDigitSeparators(symbol string) string {
+	return strings.Replace(symbol, "_", "", -1)
 }
 
-func asciiToDERImpl(scanner *scanner, leftCurly *token) ([]byte, error) {
-	var out []byte
+// parseQuotedString parses a quoted string starting at the current position,
+// which must be a double quote, and encodes it with enc. start is the position
+// reported for the resulting token.
+func (s *Scanner) parseQuotedString(start Position, enc stringEncoding) (Token, error) {
+	quote := s.pos
+	s.advance()
+	s.inString = true
+	var bytes []byte
 	for {
-		token, err := scanner.Next()
-		if err != nil {
-			return nil, err
+		if s.isEOF() {
+			return Token{}, &ParseError{quote, errors.New("unmatched \"")}
 		}
-		switch token.Kind {
-		case tokenBytes:
-			out = append(out, token.Value...)
-		case tokenLeftCurly:
-			child, err := asciiToDERImpl(scanner, &token)
-			if err != nil {
-				return nil, err
-			}
-			out = appendLength(out, len(child))
-			out = append(out, child...)
-		case tokenRightCurly:
-			if leftCurly != nil {
-				return out, nil
+		switch c := s.cur(); c {
+		case '"':
+			s.advance()
+			return Token{Kind: TokenBytes, Value: bytes, Pos: start}, nil
+		case '\\':
+			escape := s.pos
+			s.advance()
+			if s.isEOF() {
+				return Token{}, &ParseError{escape, errors.New("expected escape character")}
 			}
-			return nil, &parseError{token.Pos, errors.New("unmatched '}'")}
-		case tokenEOF:
-			if leftCurly == nil {
-				return out, nil
+			switch c2 := s.cur(); c2 {
+			case 'n', 't', 'r', '0', '"', '\\':
+				r := simpleEscapes[c2]
+				if err := s.checkChar(r, escape); err != nil {
+					return Token{}, err
+				}
+				bytes = appendRune(bytes, r, enc)
+			case 'x':
+				if enc != encodingUTF8 {
+					return Token{}, &ParseError{escape, errors.New("\\x escapes are not allowed in u16 and u32 strings")}
+				}
+				s.advance()
+				if !s.fill(2) {
+					return Token{}, &ParseError{escape, errors.New("unfinished escape sequence")}
+				}
+				b, err := hex.DecodeString(s.rest()[:2])
+				if err != nil {
+					return Token{}, &ParseError{s.pos, err}
+				}
+				if err := s.checkChar(rune(b[0]), escape); err != nil {
+					return Token{}, err
+				}
+				bytes = append(bytes, b[0])
+				s.advance()
+			case 'u', 'U':
+				// \u consumes four hex digits and \U consumes eight.
+				digits := 4
+				if c2 == 'U' {
+					digits = 8
+				}
+				s.advance()
+				if !s.fill(digits) {
+					return Token{}, &ParseError{escape, errors.New("unfinished escape sequence")}
+				}
+				r, err := strconv.ParseUint(s.rest()[:digits], 16, 32)
+				if err != nil {
+					return Token{}, &ParseError{s.pos, err}
+				}
+				if !utf8.ValidRune(rune(r)) {
+					return Token{}, &ParseError{escape, fmt.Errorf("invalid code point U+%04X", r)}
+				}
+				if err := s.checkChar(rune(r), escape); err != nil {
+					return Token{}, err
+				}
+				bytes = appendRune(bytes, rune(r), enc)
+				for i := 1; i < digits; i++ {
+					s.advance()
+				}
+			default:
+				return Token{}, &ParseError{escape, fmt.Errorf("unknown escape sequence \\%c", c2)}
 			}
-			return nil, &parseError{leftCurly.Pos, errors.New("unmatched '{'")}
 		default:
-			panic(token)
+			if enc == encodingUTF8 {
+				// UTF-8 strings are emitted byte-by-byte, so
+				// the input need not be valid UTF-8.
+				if !s.permits(rune(c)) {
+					s.fill(utf8.UTFMax)
+					r, _ := utf8.DecodeRuneInString(s.rest())
+					return Token{}, s.strType.errorAt(r, s.pos)
+				}
+				bytes = append(bytes, c)
+				break
+			}
+			s.fill(utf8.UTFMax)
+			r, n := utf8.DecodeRuneInString(s.rest())
+			if r == utf8.RuneError && n == 1 {
+				return Token{}, &ParseError{s.pos, errors.New("invalid UTF-8 in u16 or u32 string")}
+			}
+			if err := s.checkChar(r, s.pos); err != nil {
+				return Token{}, err
+			}
+			bytes = appendRune(bytes, r, enc)
+			for i := 1; i < n; i++ {
+				s.advance()
+			}
+		}
+		s.advance()
+	}
+}
+
+// simpleEscapes maps the single-character escape sequences in quoted strings to
+// the characters they emit.
+var simpleEscapes = map[byte]rune{'n': '\n', 't': '\t', 'r': '\r', '0': 0, '"': '"', '\\': '\\'}
+
+// permits returns whether quoted strings may contain r, given s.strType.
+func (s *Scanner) permits(r rune) bool {
+	return s.strType == nil || s.strType.valid == nil || s.config.allowInvalidStrings || s.strType.valid(r)
+}
+
+// checkChar returns an error, reported at pos, if s.strType does not permit r.
+func (s *Scanner) checkChar(r rune, pos Position) error {
+	if !s.permits(r) {
+		return s.strType.errorAt(r, pos)
+	}
+	return nil
+}
+
+// errorAt returns an error, reported at pos, for r, which t does not permit.
+func (t *stringType) errorAt(r rune, pos Position) error {
+	return &ParseError{pos, fmt.Errorf("invalid character %q in %s", r, t.name)}
+}
+
+// prefix returns the prefix which selects enc for a quoted string.
+func (enc stringEncoding) prefix() string {
+	switch enc {
+	case encodingUTF16:
+		return "u16"
+	case encodingUTF32:
+		return "u32"
+	default:
+		return ""
+	}
+}
+
+// parseStringLiteral behaves like parseQuotedString, but first warns if enc
+// does not match the encoding of s.strType.
+func (s *Scanner) parseStringLiteral(start Position, enc stringEncoding) (Token, error) {
+	if t := s.strType; t != nil && s.config.warn != nil && enc != t.enc {
+		literal := "unprefixed"
+		if enc != encodingUTF8 {
+			literal = enc.prefix()
+		}
+		advice := "which should not be prefixed"
+		if t.enc != encodingUTF8 {
+			advice = fmt.Sprintf("which should use %s\"...\"", t.enc.prefix())
+		}
+		s.config.warn(&ParseError{start, fmt.Errorf("%s string in %s, %s", literal, t.name, advice)})
+	}
+	return s.parseQuotedString(start, enc)
+}
+
+// parseFunction parses a function-like token. The current position must be the
+// opening parenthesis following name. start is the position of the name.
+func (s *Scanner) parseFunction(name string, start Position) (Token, error) {
+	args, err := s.consumeArguments()
+	if err != nil {
+		return Token{}, err
+	}
+
+	switch name {
+	case "utctime", "gentime":
+		str, pos, err := args.parseStringArgument()
+		if err != nil {
+			return Token{}, err
+		}
+		t, err := time.Parse(time.RFC3339, str)
+		if err != nil {
+			return Token{}, &ParseError{pos, err}
+		}
+		var value string
+		if name == "utctime" {
+			value, err = lib.FormatUTCTime(t)
+		} else {
+			value, err = lib.FormatGeneralizedTime(t)
+		}
+		if err != nil {
+			return Token{}, &ParseError{pos, err}
+		}
+		return Token{Kind: TokenBytes, Value: []byte(value), Pos: start}, nil
+	case "date", "date-time":
+		str, pos, err := args.parseStringArgument()
+		if err != nil {
+			return Token{}, err
+		}
+		// These are local times, so the input has no time zone.
+		layout := "2006-01-02"
+		if name == "date-time" {
+			layout = "2006-01-02T15:04:05"
+		}
+		t, err := time.Parse(layout, str)
+		if err != nil {
+			return Token{}, &ParseError{pos, err}
+		}
+		var value string
+		if name == "date" {
+			value, err = lib.FormatDate(t)
+		} else {
+			value, err = lib.FormatDateTime(t)
+		}
+		if err != nil {
+			return Token{}, &ParseError{pos, err}
+		}
+		return Token{Kind: TokenBytes, Value: []byte(value), Pos: start}, nil
+	case "bits":
+		str, pos, err := args.parseStringArgument()
+		if err != nil {
+			return Token{}, err
+		}
+		bits := make([]bool, len(str))
+		for i := range str {
+			switch str[i] {
+			case '0':
+			case '1':
+				bits[i] = true
+			default:
+				return Token{}, &ParseError{pos, fmt.Errorf("invalid bit '%c'", str[i])}
+			}
+		}
+		return Token{Kind: TokenBytes, Value: lib.AppendBitString(nil, bits), Pos: start}, nil
+	case "long-form":
+		n, err := args.parseIntegerArguments(1)
+		if err != nil {
+			return Token{}, err
+		}
+		// 0xff is reserved as a length prefix, so 126 bytes is the
+		// maximum.
+		if n[0] < 1 || n[0] > 126 {
+			return Token{}, &ParseError{args.pos, errors.New("long-form length must be between 1 and 126 bytes")}
+		}
+		return Token{Kind: TokenLongForm, Arg: int(n[0]), Pos: start}, nil
+	case "expect-len":
+		n, err := args.parseIntegerArguments(1)
+		if err != nil {
+			return Token{}, err
+		}
+		if n[0] < 0 {
+			return Token{}, &ParseError{args.pos, errors.New("expected length must be non-negative")}
+		}
+		return Token{Kind: TokenExpectLen, Arg: int(n[0]), Pos: start}, nil
+	case "truncate":
+		n, err := args.parseIntegerArguments(1)
+		if err != nil {
+			return Token{}, err
+		}
+		if n[0] < 0 || n[0] > math.MaxInt32 {
+			return Token{}, &ParseError{args.pos, fmt.Errorf("declared length must be between 0 and %d", math.MaxInt32)}
+		}
+		return Token{Kind: TokenTruncate, Arg: int(n[0]), Pos: start}, nil
+	case "bits-unused":
+		n, err := args.parseIntegerArguments(1)
+		if err != nil {
+			return Token{}, err
+		}
+		if n[0] < 0 || n[0] > 7 {
+			return Token{}, &ParseError{args.pos, errors.New("unused bit count must be between 0 and 7")}
+		}
+		return Token{Kind: TokenBitsUnused, Arg: int(n[0]), Pos: start}, nil
+	case "repeat":
+		n, err := args.parseIntegerArguments(1)
+		if err != nil {
+			return Token{}, err
+		}
+		if n[0] < 0 {
+			return Token{}, &ParseError{args.pos, errors.New("repeat count must be non-negative")}
+		}
+		return Token{Kind: TokenRepeat, Arg: int(n[0]), Pos: start}, nil
+	case "real", "real-decimal":
+		words, err := args.parseWordArguments()
+		if err != nil {
+			return Token{}, err
+		}
+		if len(words) != 1 {
+			return Token{}, &ParseError{args.pos, fmt.Errorf("expected 1 argument, got %d", len(words))}
+		}
+		f, err := strconv.ParseFloat(words[0].Text, 64)
+		if err != nil {
+			return Token{}, &ParseError{words[0].Pos, err}
+		}
+		var value []byte
+		if name == "real" {
+			value = lib.AppendReal(nil, f)
+		} else {
+			value = lib.AppendRealDecimal(nil, f)
+		}
+		return Token{Kind: TokenBytes, Value: value, Pos: start}, nil
+	case "key-usage":
+		words, err := args.parseWordArguments()
+		if err != nil {
+			return Token{}, err
+		}
+		var bits []bool
+		for _, word := range words {
+			i, ok := lib.KeyUsageBitByName(word.Text)
+			if !ok {
+				return Token{}, &ParseError{word.Pos, fmt.Errorf("unknown key usage '%s'", word.Text)}
+			}
+			if i < len(bits) && bits[i] {
+				return Token{}, &ParseError{word.Pos, fmt.Errorf("key usage '%s' repeated", word.Text)}
+			}
+			for len(bits) <= i {
+				bits = append(bits, false)
+			}
+			bits[i] = true
+		}
+		// bits ends at the last set bit, so there are no trailing zeros,
+		// as DER requires.
+		return Token{Kind: TokenBytes, Value: lib.AppendBitString(nil, bits), Pos: start}, nil
+	case "byte":
+		n, err := args.parseIntegerArguments(1)
+		if err != nil {
+			return Token{}, err
+		}
+		if n[0] < 0 || n[0] > 255 {
+			return Token{}, &ParseError{args.pos, errors.New("byte value must be between 0 and 255")}
+		}
+		return Token{Kind: TokenBytes, Value: []byte{byte(n[0])}, Pos: start}, nil
+	case "hex":
+		value, err := args.parseHexByteArguments()
+		if err != nil {
+			return Token{}, err
+		}
+		return Token{Kind: TokenBytes, Value: value, Pos: start}, nil
+	case "int-width":
+		n, err := args.parseIntegerArguments(2)
+		if err != nil {
+			return Token{}, err
+		}
+		if n[0] < 1 || n[0] > maxIntegerWidth {
+			return Token{}, &ParseError{args.pos, fmt.Errorf("integer width must be between 1 and %d bytes", maxIntegerWidth)}
+		}
+		value, ok := lib.AppendIntegerWidth(nil, n[1], int(n[0]))
+		if !ok {
+			return Token{}, &ParseError{args.pos, fmt.Errorf("integer %d does not fit in %d bytes", n[1], n[0])}
+		}
+		return Token{Kind: TokenBytes, Value: value, Pos: start}, nil
+	case "uint":
+		uargs, err := args.parseWordArguments()
+		if err != nil {
+			return Token{}, err
+		}
+		if len(uargs) != 1 {
+			return Token{}, &ParseError{args.pos, fmt.Errorf("expected 1 argument, got %d", len(uargs))}
+		}
+		arg := uargs[0]
+		if strings.HasPrefix(arg.Text, "-") {
+			return Token{}, &ParseError{arg.Pos, fmt.Errorf("unsigned integer '%s' is negative", arg.Text)}
+		}
+		digits, base, err := s.integerArgument(arg)
+		if err != nil {
+			return Token{}, err
+		}
+		value, err := strconv.ParseUint(digits, base, 64)
+		if err != nil {
+			return Token{}, &ParseError{arg.Pos, err}
+		}
+		return Token{Kind: TokenBytes, Value: lib.AppendUnsignedInteger(nil, value), Pos: start}, nil
+	case "relative-oid":
+		words, err := args.parseWordArguments()
+		if err != nil {
+			return Token{}, err
+		}
+		if len(words) != 1 {
+			return Token{}, &ParseError{args.pos, fmt.Errorf("expected 1 argument, got %d", len(words))}
+		}
+		if !regexpRelativeOID.MatchString(words[0].Text) {
+			return Token{}, &ParseError{words[0].Pos, fmt.Errorf("invalid relative OID '%s'", words[0].Text)}
+		}
+		if err := s.checkArcs(words[0].Text); err != nil {
+			return Token{}, &ParseError{words[0].Pos, err}
+		}
+		arcs, err := parseArcs(words[0].Text)
+		if err != nil {
+			return Token{}, &ParseError{words[0].Pos, err}
+		}
+		return Token{Kind: TokenBytes, Value: lib.AppendRelativeOID(nil, arcs), Pos: start}, nil
+	case "algorithm":
+		words, err := args.parseWordArguments()
+		if err != nil {
+			return Token{}, err
+		}
+		if len(words) != 1 {
+			return Token{}, &ParseError{args.pos, fmt.Errorf("expected 1 argument, got %d", len(words))}
+		}
+		value, err := appendAlgorithmIdentifier(nil, words[0].Text)
+		if err != nil {
+			return Token{}, &ParseError{words[0].Pos, err}
+		}
+		return Token{Kind: TokenBytes, Value: value, Pos: start}, nil
+	case "concat":
+		// The arguments are assembled later, so they may use macros. Copy
+		// them to a standalone scanner, which does not depend on how s
+		// buffers its input.
+		concat := &Scanner{text: args.rest(), base: args.pos.Offset, pos: args.pos, config: s.config}
+		return Token{Kind: TokenConcat, Pos: start, args: concat}, nil
+	}
+
+	return Token{}, &ParseError{start, fmt.Errorf("unrecognized function '%s'", name)}
+}
+
+// consumeArguments consumes a parenthesized argument list, starting at an
+// opening parenthesis. It returns a scanner over the text between the
+// parentheses. Parentheses within strings, hex literals, and comments do not
+// count towards nesting.
+func (s *Scanner) consumeArguments() (*Scanner, error) {
+	open := s.pos
+	s.advance()
+	args := &Scanner{base: s.base, pos: s.pos, config: s.config}
+	depth := 0
+	for !s.isEOF() {
+		switch s.cur() {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				args.text = s.text[:s.pos.Offset-s.base]
+				s.advance()
+				return args, nil
+			}
+			depth--
+		case '"':
+			// Skip over the string, including escaped quotes.
+			s.advance()
+			for !s.isEOF() && s.cur() != '"' {
+				if s.cur() == '\\' {
+					s.advance()
+				}
+				s.advance()
+			}
+		case '`':
+			s.advance()
+			s.consumeUpTo('`')
+			continue
+		case '#':
+			for !s.isEOF() && s.cur() != '\n' && s.cur() != '\r' {
+				s.advance()
+			}
+			continue
+		case '/':
+			if s.isBlockComment() {
+				comment := s.pos
+				if !s.skipBlockComment() {
+					return nil, &ParseError{comment, errors.New("unterminated /* comment")}
+				}
+				continue
+			}
+		}
+		s.advance()
+	}
+	return nil, &ParseError{open, errors.New("unmatched (")}
+}
+
+// skipWhitespace advances past any whitespace and comments.
+func (s *Scanner) skipWhitespace() {
+	for !s.isEOF() {
+		switch s.cur() {
+		case ' ', '\t', '\n', '\r':
+			s.advance()
+		case '#':
+			for !s.isEOF() && s.cur() != '\n' && s.cur() != '\r' {
+				s.advance()
+			}
+		case '/':
+			if !s.isBlockComment() {
+				return
+			}
+			s.skipBlockComment()
+		default:
+			return
+		}
+	}
+}
+
+// parseStringArgument parses the remaining input, which must be a single
+// quoted string, and returns the string and its position.
+func (s *Scanner) parseStringArgument() (string, Position, error) {
+	s.skipWhitespace()
+	pos := s.pos
+	if s.isEOF() || s.cur() != '"' {
+		return "", pos, &ParseError{pos, errors.New("expected quoted string")}
+	}
+	tok, err := s.parseQuotedString(pos, encodingUTF8)
+	if err != nil {
+		return "", pos, err
+	}
+	s.skipWhitespace()
+	if !s.isEOF() {
+		return "", pos, &ParseError{s.pos, errors.New("unexpected argument")}
+	}
+	return string(tok.Value), pos, nil
+}
+
+// An argument is a single argument to a function.
+type argument struct {
+	Text string
+	Pos  Position
+}
+
+// parseWordArguments parses the remaining input as a comma-separated list of
+// bare words, such as integers or names.
+func (s *Scanner) parseWordArguments() ([]argument, error) {
+	var args []argument
+	s.skipWhitespace()
+	if s.isEOF() {
+		return nil, nil
+	}
+	for {
+		pos := s.pos
+	loop:
+		for !s.isEOF() {
+			switch s.cur() {
+			case ' ', '\t', '\n', '\r', ',', '#':
+				break loop
+			default:
+				s.advance()
+			}
+		}
+		if pos.Offset == s.pos.Offset {
+			return nil, &ParseError{pos, errors.New("expected argument")}
+		}
+		args = append(args, argument{s.textFrom(pos), pos})
+		s.skipWhitespace()
+		if s.isEOF() {
+			return args, nil
+		}
+		if s.cur() != ',' {
+			return nil, &ParseError{s.pos, errors.New("expected ','")}
+		}
+		s.advance()
+		s.skipWhitespace()
+	}
+}
+
+// parseHexByteArguments parses the remaining input as a whitespace-separated
+// list of bytes, each written as two hex digits with an optional 0x prefix, and
+// returns the bytes. Comments may appear between bytes.
+func (s *Scanner) parseHexByteArguments() ([]byte, error) {
+	var ret []byte
+	for s.skipWhitespace(); !s.isEOF(); s.skipWhitespace() {
+		pos := s.pos
+	loop:
+		for !s.isEOF() {
+			switch s.cur() {
+			case ' ', '\t', '\n', '\r', '#':
+				break loop
+			default:
+				s.advance()
+			}
+		}
+		text := s.textFrom(pos)
+		digits, digitsPos := text, pos
+		if strings.HasPrefix(text, "0x") {
+			digits = text[2:]
+			digitsPos.Column += 2
+			digitsPos.Offset += 2
+		}
+		b, err := decodeHex(digits, digitsPos)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) != 1 || len(digits) != 2 {
+			return nil, &ParseError{pos, fmt.Errorf("byte '%s' must be two hex digits", text)}
+		}
+		ret = append(ret, b[0])
+	}
+	return ret, nil
+}
+
+// parseIntegerArguments parses the remaining input as a comma-separated list of
+// n integers, written as in integerArgument.
+func (s *Scanner) parseIntegerArguments(n int) ([]int64, error) {
+	start := s.pos
+	args, err := s.parseWordArguments()
+	if err != nil {
+		return nil, err
+	}
+	if len(args) != n {
+		return nil, &ParseError{start, fmt.Errorf("expected %d arguments, got %d", n, len(args))}
+	}
+	ret := make([]int64, 0, n)
+	for _, arg := range args {
+		digits, base, err := s.integerArgument(arg)
+		if err != nil {
+			return nil, err
+		}
+		v, err := strconv.ParseInt(digits, base, 64)
+		if err != nil {
+			return nil, &ParseError{arg.Pos, err}
+		}
+		ret = append(ret, v)
+	}
+	return ret, nil
+}
+
+// integerArgument returns the digits of arg, an integer argument to a function,
+// and the base in which to parse them. As with integer tokens, arg may be
+// decimal, or hexadecimal or binary with a 0x or 0b prefix, so a leading zero
+// never means octal.
+func (s *Scanner) integerArgument(arg argument) (string, int, error) {
+	text := arg.Text
+	if regexpInteger.MatchString(text) {
+		if !s.config.allowLeadingZeros && hasLeadingZero(text) {
+			return "", 0, &ParseError{arg.Pos, fmt.Errorf("integer '%s' has a leading zero", text)}
+		}
+		return stripDigitSeparators(text), 10, nil
+	}
+	if !regexpHexInteger.MatchString(text) && !regexpBinaryInteger.MatchString(text) {
+		return "", 0, &ParseError{arg.Pos, fmt.Errorf("invalid integer '%s'", text)}
+	}
+	digits, base := splitPrefixedInteger(text)
+	return digits, base, nil
+}
+
+// peekLengthPrefix returns whether the next token, after any whitespace and
+// comments, is a length prefix: a left curly brace, or a keyword or function
+// which encodes a length, such as indefinite or long-form. It does not advance
+// the scanner.
+func (s *Scanner) peekLengthPrefix() bool {
+	for i := 0; ; i++ {
+		c, ok := s.byteAt(i)
+		if !ok {
+			return false
+		}
+		switch c {
+		case ' ', '\t', '\n', '\r':
+		case '#':
+			for c != '\n' && c != '\r' {
+				i++
+				if c, ok = s.byteAt(i); !ok {
+					return false
+				}
+			}
+		case '/':
+			if c, _ := s.byteAt(i + 1); c != '*' {
+				return false
+			}
+			// Find the end of the comment.
+			for i += 2; ; i++ {
+				c, ok := s.byteAt(i)
+				if !ok {
+					return false
+				}
+				if c2, _ := s.byteAt(i + 1); c == '*' && c2 == '/' {
+					i++
+					break
+				}
+			}
+		case '{':
+			return true
+		default:
+			symbol, isFunction := s.symbolAt(i)
+			switch symbol {
+			case "indefinite", "set-of":
+				return !isFunction
+			case "long-form", "expect-len", "truncate":
+				return isFunction
+			}
+			return false
+		}
+	}
+}
+
+// symbolAt returns the symbol starting i bytes past the current position, and
+// whether it is followed by a left parenthesis, as a function name is. It does
+// not advance the scanner.
+func (s *Scanner) symbolAt(i int) (string, bool) {
+	for j := i; ; j++ {
+		c, ok := s.byteAt(j)
+		if !ok {
+			return s.rest()[i:j], false
+		}
+		switch c {
+		case ' ', '\t', '\n', '\r', '{', '}', '[', ']', '(', ')', '`', '|', '"', '#':
+			return s.rest()[i:j], c == '('
+		case '/':
+			if c, _ := s.byteAt(j + 1); c == '*' {
+				return s.rest()[i:j], false
+			}
+		}
+	}
+}
+
+// isBlockComment returns whether the scanner is at the start of a /* comment.
+func (s *Scanner) isBlockComment() bool {
+	return s.hasPrefix("/*")
+}
+
+// skipBlockComment advances past a /* comment, which must start at the
+// current position. Comments do not nest. It returns false if the comment is
+// unterminated.
+func (s *Scanner) skipBlockComment() bool {
+	s.advance()
+	s.advance()
+	for !s.isEOF() {
+		if s.hasPrefix("*/") {
+			s.advance()
+			s.advance()
+			return true
+		}
+		s.advance()
+	}
+	return false
+}
+
+func (s *Scanner) isEOF() bool {
+	return !s.fill(1)
+}
+
+// cur returns the byte at the current position. The caller must check isEOF
+// first.
+func (s *Scanner) cur() byte {
+	return s.text[s.pos.Offset-s.base]
+}
+
+// rest returns the buffered input from the current position.
+func (s *Scanner) rest() string {
+	return s.text[s.pos.Offset-s.base:]
+}
+
+// textFrom returns the input from start, which must be within the current
+// token, to the current position.
+func (s *Scanner) textFrom(start Position) string {
+	return s.text[start.Offset-s.base : s.pos.Offset-s.base]
+}
+
+// hasPrefix returns whether the input at the current position begins with
+// prefix.
+func (s *Scanner) hasPrefix(prefix string) bool {
+	s.fill(len(prefix))
+	return strings.HasPrefix(s.rest(), prefix)
+}
+
+// byteAt returns the byte i bytes past the current position, or false if the
+// input ends first. It does not advance the scanner.
+func (s *Scanner) byteAt(i int) (byte, bool) {
+	if !s.fill(i + 1) {
+		return 0, false
+	}
+	return s.rest()[i], true
+}
+
+// fill reads from the underlying reader, if any, until at least n bytes are
+// buffered past the current position. It returns false if the input ends
+// first.
+func (s *Scanner) fill(n int) bool {
+	for len(s.text)-(s.pos.Offset-s.base) < n {
+		if s.r == nil {
+			return false
+		}
+		// Read in chunks proportional to the buffer, so long tokens
+		// are not quadratic.
+		size := len(s.text)
+		if size < 4096 {
+			size = 4096
+		}
+		buf := make([]byte, size)
+		m, err := s.r.Read(buf)
+		s.text += string(buf[:m])
+		if err != nil {
+			if err != io.EOF {
+				s.readErr = err
+			}
+			s.r = nil
+		}
+	}
+	return true
+}
+
+// discard drops buffered input before the current position. It is called
+// between tokens, so earlier text is no longer referenced.
+func (s *Scanner) discard() {
+	if s.pos.Offset > s.base {
+		s.text = s.text[s.pos.Offset-s.base:]
+		s.base = s.pos.Offset
+	}
+}
+
+func (s *Scanner) advance() {
+	if !s.isEOF() {
+		next, _ := s.byteAt(1)
+		s.pos.advance(s.cur(), next)
+	}
+}
+
+// advance updates p to the position after c, where next is the byte following
+// c, or zero at the end of the input. \n, \r\n, and a lone \r each count as a
+// single line break.
+func (p *Position) advance(c, next byte) {
+	if c == '\n' || (c == '\r' && next != '\n') {
+		p.Line++
+		p.Column = 1
+	} else {
+		p.Column++
+	}
+	p.Offset++
+}
+
+// byteAfter returns the byte in str after index i, or zero if there is none.
+func byteAfter(str string, i int) byte {
+	if i+1 < len(str) {
+		return str[i+1]
+	}
+	return 0
+}
+
+func (s *Scanner) consumeUpTo(b byte) (string, bool) {
+	start := s.pos
+	for !s.isEOF() {
+		if s.cur() == b {
+			ret := s.textFrom(start)
+			s.advance()
+			return ret, true
+		}
+		s.advance()
+	}
+	return "", false
+}
+
+// A macro is the value of a name bound with define. Macros from
+// Options.Macros have a zero pos.
+type macro struct {
+	value []byte
+	pos   Position
+}
+
+// asciiToDERImpl behaves like assembleBlock, but first checks depth. If opts is
+// linting, it also records recoverable syntax errors and continues with the
+// rest of the block.
+func asciiToDERImpl(dst []byte, scanner *Scanner, opts *Options, macros map[string]macro, includes []string, open *Token, depth int) ([]byte, error) {
+	if depth > opts.maxDepth() {
+		if opts.lint != nil && open.Kind == TokenLeftCurly {
+			// Skip the block, so linting resumes after it rather than
+			// reporting each of its closing braces.
+			scanner.skipBlock()
+		}
+		return nil, &ParseError{open.Pos, fmt.Errorf("nesting too deep, exceeding maximum depth of %d", opts.maxDepth())}
+	}
+	strType := scanner.strType
+	gapMark := opts.gaps.mark()
+	for {
+		start := scanner.pos
+		scanner.strType = strType
+		opts.gaps.reset(gapMark)
+		out, err := assembleBlock(dst, scanner, opts, macros, includes, open, depth)
+		if err == nil || !opts.recover(scanner, start, err) {
+			return out, err
+		}
+	}
+}
+
+// recover, if opts is linting and err is a *ParseError, records err and
+// resynchronizes scanner. It returns whether assembly should continue. To
+// ensure progress, it does not continue if scanner has not advanced past start
+// or has reached EOF. In that case, the caller should return err.
+func (opts *Options) recover(scanner *Scanner, start Position, err error) bool {
+	parseErr, ok := err.(*ParseError)
+	if opts.lint == nil || !ok {
+		return false
+	}
+	scanner.resync()
+	if scanner.pos.Offset == start.Offset || scanner.isEOF() {
+		return false
+	}
+	*opts.lint = append(*opts.lint, parseErr)
+	return true
+}
+
+// assembleBlock assembles tokens from scanner, appends the result to dst, and
+// returns the updated slice. Blocks are assembled in place and their lengths
+// back-patched into reserved bytes, so large inputs are not copied once per
+// level of nesting. The unused reserved bytes are recorded in opts.gaps, and
+// the caller must compact them. If open is non-nil, it is the token which began
+// the current block, either a left curly brace or a concat. For a left curly
+// brace, it stops at the matching right curly brace. For a concat, scanner
+// reads the arguments and it stops at EOF. depth is the number of enclosing
+// blocks, including open. macros contains the macros defined so far and is
+// updated by define. includes is the chain of files, as absolute paths, being
+// assembled through include, ending with the file scanner reads. It is empty
+// for the top-level input.
+func assembleBlock(dst []byte, scanner *Scanner, opts *Options, macros map[string]macro, includes []string, open *Token, depth int) ([]byte, error) {
+	out := dst
+	// lastTag is the tag encoded by the previous token, if any, and
+	// lastTagPos is its position.
+	var lastTag *lib.Tag
+	var lastTagPos Position
+	// strType restricts quoted strings directly within this block. Nested
+	// blocks restrict them according to their own tag.
+	strType := scanner.strType
+	sm := opts.sourceMap
+	gaps := opts.gaps
+	for {
+		scanner.strType = strType
+		token, err := scanner.Next()
+		if err != nil {
+			return nil, err
+		}
+		scanner.strType = nil
+		tag := lastTag
+		lastTag = nil
+		if tag != nil && opts.StrictTags && !encodesLength(token.Kind) {
+			return nil, &ParseError{lastTagPos, errors.New("tag must be followed by '{' or a length modifier")}
+		}
+		// Mappings for a block assembled separately are relative to the
+		// block until it is placed in out.
+		mark := sm.mark()
+		gapMark := gaps.mark()
+		start := len(out)
+		switch token.Kind {
+		case TokenBytes:
+			out = append(out, token.Value...)
+			sm.add(start, len(out), token.Pos)
+			lastTag, lastTagPos = token.Tag, token.Pos
+		case TokenIndefinite:
+			if tag == nil || !tag.Constructed {
+				return nil, &ParseError{token.Pos, errors.New("indefinite length requires a constructed tag")}
+			}
+			out = append(out, 0x80)
+			sm.add(start, len(out), token.Pos)
+			out, err = asciiToDERBlock(out, scanner, opts, macros, includes, "indefinite", depth)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, 0x00, 0x00)
+			sm.add(len(out)-2, len(out), token.Pos)
+		case TokenSetOf:
+			leftCurly, err := scanner.nextLeftCurly("set-of")
+			if err != nil {
+				return nil, err
+			}
+			child, err := asciiToDERImpl(nil, scanner, opts, macros, includes, &leftCurly, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			child = gaps.compact(child, gapMark, sm, mark)
+			elems, ok := splitElements(child)
+			if !ok {
+				return nil, &ParseError{token.Pos, errors.New("set-of contents must be a series of definite-length elements")}
+			}
+			if err := opts.checkLength(leftCurly.Pos, len(child)); err != nil {
+				return nil, err
+			}
+			// DER sorts SET OF by encoding, with shorter elements first
+			// if one is a prefix of the other.
+			offsets := make([]int, len(elems))
+			order := make([]int, len(elems))
+			for i := range elems {
+				order[i] = i
+				if i > 0 {
+					offsets[i] = offsets[i-1] + len(elems[i-1])
+				}
+			}
+			sort.SliceStable(order, func(i, j int) bool { return bytes.Compare(elems[order[i]], elems[order[j]]) < 0 })
+			out = lib.AppendLength(out, len(child))
+			childStart := len(out)
+			spans := make([]span, 0, len(elems))
+			for _, i := range order {
+				spans = append(spans, span{offsets[i], len(out), len(elems[i])})
+				out = append(out, elems[i]...)
+			}
+			sm.relocate(mark, spans)
+			sm.add(start, childStart, token.Pos)
+		case TokenImplicit:
+			tagToken, err := scanner.Next()
+			if err != nil {
+				return nil, err
+			}
+			if tagToken.Kind != TokenBytes || tagToken.Tag == nil {
+				return nil, &ParseError{tagToken.Pos, errors.New("expected tag after 'implicit'")}
+			}
+			child, err := asciiToDERBlock(nil, scanner, opts, macros, includes, "implicit", depth)
+			if err != nil {
+				return nil, err
+			}
+			child = gaps.compact(child, gapMark, sm, mark)
+			if elems, ok := splitElements(child); !ok || len(elems) != 1 {
+				return nil, &ParseError{token.Pos, errors.New("implicit contents must be a single definite-length element")}
+			}
+			// IMPLICIT tagging replaces the class and number, but the
+			// constructed bit still reflects the original encoding.
+			newTag := *tagToken.Tag
+			newTag.Constructed = child[0]&0x20 != 0
+			out = lib.AppendTag(out, newTag)
+			sm.relocate(mark, []span{{tagLength(child), len(out), len(child) - tagLength(child)}})
+			sm.add(start, len(out), tagToken.Pos)
+			out = append(out, child[tagLength(child):]...)
+		case TokenExplicit:
+			tagToken, err := scanner.Next()
+			if err != nil {
+				return nil, err
+			}
+			if tagToken.Kind != TokenBytes || tagToken.Tag == nil {
+				return nil, &ParseError{tagToken.Pos, errors.New("expected tag after 'explicit'")}
+			}
+			// EXPLICIT tagging wraps the element in a constructed one.
+			if !tagToken.Tag.Constructed {
+				return nil, &ParseError{tagToken.Pos, errors.New("explicit tag must be constructed")}
+			}
+			out = lib.AppendTag(out, *tagToken.Tag)
+			lengthOffset := len(out)
+			out = reserve(out)
+			childStart := len(out)
+			leftCurly, err := scanner.nextLeftCurly("explicit")
+			if err != nil {
+				return nil, err
+			}
+			out, err = asciiToDERImpl(out, scanner, opts, macros, includes, &leftCurly, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			// The contents are checked, so they must be compacted first.
+			out = gaps.compact(out, gapMark, sm, mark)
+			if elems, ok := splitElements(out[childStart:]); !ok || len(elems) != 1 {
+				return nil, &ParseError{token.Pos, errors.New("explicit contents must be a single definite-length element")}
+			}
+			if err := opts.checkLength(leftCurly.Pos, len(out)-childStart); err != nil {
+				return nil, err
+			}
+			n := gaps.fill(out, lengthOffset, len(out)-childStart)
+			sm.add(start, lengthOffset+n, tagToken.Pos)
+		case TokenLongForm:
+			scanner.strType = stringTypeOf(tag)
+			// The length has a fixed width, so reserve it and fill it in
+			// once the contents are known.
+			out, _ = appendLongFormLength(out, 0, token.Arg)
+			childStart := len(out)
+			leftCurly, err := scanner.nextLeftCurly("long-form")
+			if err != nil {
+				return nil, err
+			}
+			out, err = asciiToDERImpl(out, scanner, opts, macros, includes, &leftCurly, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			length := len(out) - childStart - gaps.since(gapMark)
+			if err := opts.checkLength(leftCurly.Pos, length); err != nil {
+				return nil, err
+			}
+			if _, ok := appendLongFormLength(out[start:start], length, token.Arg); !ok {
+				return nil, &ParseError{token.Pos, fmt.Errorf("length %d does not fit in %d bytes", length, token.Arg)}
+			}
+			sm.add(start, childStart, token.Pos)
+		case TokenExpectLen:
+			scanner.strType = stringTypeOf(tag)
+			out = lib.AppendLength(out, token.Arg)
+			childStart := len(out)
+			leftCurly, err := scanner.nextLeftCurly("expect-len")
+			if err != nil {
+				return nil, err
+			}
+			out, err = asciiToDERImpl(out, scanner, opts, macros, includes, &leftCurly, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if length := len(out) - childStart - gaps.since(gapMark); length != token.Arg {
+				return nil, &ParseError{token.Pos, fmt.Errorf("contents are %d bytes, but expected %d", length, token.Arg)}
+			}
+			if err := opts.checkLength(leftCurly.Pos, token.Arg); err != nil {
+				return nil, err
+			}
+			sm.add(start, childStart, token.Pos)
+		case TokenTruncate:
+			scanner.strType = stringTypeOf(tag)
+			out = lib.AppendLength(out, token.Arg)
+			childStart := len(out)
+			leftCurly, err := scanner.nextLeftCurly("truncate")
+			if err != nil {
+				return nil, err
+			}
+			out, err = asciiToDERImpl(out, scanner, opts, macros, includes, &leftCurly, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			length := len(out) - childStart - gaps.since(gapMark)
+			if length > token.Arg {
+				return nil, &ParseError{token.Pos, fmt.Errorf("contents are %d bytes, more than the declared length %d", length, token.Arg)}
+			}
+			if err := opts.checkLength(leftCurly.Pos, length); err != nil {
+				return nil, err
+			}
+			sm.add(start, childStart, token.Pos)
+		case TokenRaw:
+			// The contents are spliced in with no length prefix, so
+			// quoted strings are checked against this block's type.
+			scanner.strType = strType
+			out, err = asciiToDERBlock(out, scanner, opts, macros, includes, "raw", depth)
+			if err != nil {
+				return nil, err
+			}
+		case TokenRepeat:
+			out, err = asciiToDERBlock(out, scanner, opts, macros, includes, "repeat", depth)
+			if err != nil {
+				return nil, err
+			}
+			// Compact the contents so the copies do not have gaps.
+			out = gaps.compact(out, gapMark, sm, mark)
+			length := len(out) - start
+			if length == 0 {
+				sm.reset(mark)
+				break
+			}
+			if token.Arg > opts.maxRepeatSize()/length {
+				return nil, &ParseError{token.Pos, fmt.Errorf("repeat would emit more than %d bytes", opts.maxRepeatSize())}
+			}
+			if token.Arg == 0 {
+				out = out[:start]
+			}
+			for i := 1; i < token.Arg; i++ {
+				out = append(out, out[start:start+length]...)
+			}
+			sm.reset(mark)
+			sm.add(start, len(out), token.Pos)
+		case TokenBitsUnused:
+			out = append(out, byte(token.Arg))
+			childStart := len(out)
+			out, err = asciiToDERBlock(out, scanner, opts, macros, includes, "bits-unused", depth)
+			if err != nil {
+				return nil, err
+			}
+			out = gaps.compact(out, gapMark, sm, mark)
+			if token.Arg != 0 {
+				if len(out) == childStart {
+					return nil, &ParseError{token.Pos, errors.New("unused bit count must be zero for an empty BIT STRING")}
+				}
+				if last := out[len(out)-1]; !opts.AllowNonzeroPadding && last&byte(1<<uint(token.Arg)-1) != 0 {
+					return nil, &ParseError{token.Pos, fmt.Errorf("unused bits in final byte %#02x are not zero", last)}
+				}
+			}
+			sm.add(start, childStart, token.Pos)
+		case TokenDefine:
+			if open != nil {
+				return nil, &ParseError{token.Pos, errors.New("define must be at the top level")}
+			}
+			if m, ok := macros[token.Name]; ok {
+				if m.pos.Line == 0 {
+					return nil, &ParseError{token.Pos, fmt.Errorf("macro '%s' already defined outside the input", token.Name)}
+				}
+				return nil, &ParseError{token.Pos, fmt.Errorf("macro '%s' already defined at line %d column %d", token.Name, m.pos.Line, m.pos.Column)}
+			}
+			value, err := asciiToDERBlock(nil, scanner, opts, macros, includes, "define", depth)
+			if err != nil {
+				return nil, err
+			}
+			macros[token.Name] = macro{gaps.compact(value, gapMark, sm, mark), token.Pos}
+			sm.reset(mark)
+		case TokenUse:
+			m, ok := macros[token.Name]
+			if !ok {
+				return nil, &ParseError{token.Pos, fmt.Errorf("undefined macro '%s'", token.Name)}
+			}
+			out = append(out, m.value...)
+			sm.add(start, len(out), token.Pos)
+		case TokenInclude:
+			child, err := opts.include(token, includes, depth)
+			if err != nil {
+				return nil, err
+			}
+			child = gaps.compact(child, gapMark, sm, mark)
+			out = append(out, child...)
+			// Positions within the included file would be ambiguous, so
+			// its bytes map to the include.
+			sm.reset(mark)
+			sm.add(start, len(out), token.Pos)
+		case TokenLeftCurly:
+			scanner.strType = stringTypeOf(tag)
+			out = reserve(out)
+			childStart := len(out)
+			out, err = asciiToDERImpl(out, scanner, opts, macros, includes, &token, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			length := len(out) - childStart - gaps.since(gapMark)
+			if err := opts.checkLength(token.Pos, length); err != nil {
+				return nil, err
+			}
+			n := gaps.fill(out, start, length)
+			sm.add(start, start+n, token.Pos)
+		case TokenConcat:
+			token.args.strType = strType
+			out, err = asciiToDERImpl(out, token.args, opts, macros, includes, &token, depth+1)
+			if err != nil {
+				return nil, err
+			}
+		case TokenRightCurly:
+			if open != nil && open.Kind == TokenLeftCurly {
+				return out, nil
+			}
+			return nil, &ParseError{token.Pos, errors.New("unmatched '}'")}
+		case TokenEOF:
+			if open == nil || open.Kind == TokenConcat {
+				return out, nil
+			}
+			return nil, &ParseError{open.Pos, errors.New("unmatched '{'")}
+		default:
+			return nil, &ParseError{token.Pos, fmt.Errorf("unexpected token kind %d", token.Kind)}
+		}
+	}
+}
+
+// stringTypeOf returns the string type of an element with tag, or nil if it is
+// not a primitive string type.
+func stringTypeOf(tag *lib.Tag) *stringType {
+	if tag == nil || tag.Class != lib.ClassUniversal || tag.Constructed {
+		return nil
+	}
+	return stringTypes[tag.Number]
+}
+
+// checkLength returns an error, reported at pos, if a block of length bytes
+// exceeds opts.MaxLength.
+func (opts *Options) checkLength(pos Position, length int) error {
+	if opts.MaxLength > 0 && length > opts.MaxLength {
+		return &ParseError{pos, fmt.Errorf("length %d exceeds maximum of %d", length, opts.MaxLength)}
+	}
+	return nil
+}
+
+// encodesLength returns whether a token of the given kind, following a tag,
+// encodes the length of that tag's element.
+func encodesLength(kind TokenKind) bool {
+	switch kind {
+	case TokenLeftCurly, TokenIndefinite, TokenSetOf, TokenLongForm, TokenExpectLen, TokenTruncate:
+		return true
+	}
+	return false
+}
+
+// asciiToDERBlock reads a left curly brace from scanner and assembles the
+// contents up to the matching right curly brace, appending them to dst. It is
+// used for keywords, named by keyword, which must be followed by a block. depth
+// is the nesting depth of the keyword.
+func asciiToDERBlock(dst []byte, scanner *Scanner, opts *Options, macros map[string]macro, includes []string, keyword string, depth int) ([]byte, error) {
+	leftCurly, err := scanner.nextLeftCurly(keyword)
+	if err != nil {
+		return nil, err
+	}
+	return asciiToDERImpl(dst, scanner, opts, macros, includes, &leftCurly, depth+1)
+}
+
+// nextLeftCurly reads a left curly brace from s, which must follow keyword, and
+// returns it. Keywords whose block has a length use it, rather than
+// asciiToDERBlock, so length errors are reported at the left curly brace.
+func (s *Scanner) nextLeftCurly(keyword string) (Token, error) {
+	leftCurly, err := s.Next()
+	if err != nil {
+		return Token{}, err
+	}
+	if leftCurly.Kind != TokenLeftCurly {
+		return Token{}, &ParseError{leftCurly.Pos, fmt.Errorf("expected '{' after '%s'", keyword)}
+	}
+	return leftCurly, nil
+}
+
+// include assembles the file named by token, a TokenInclude token, and returns
+// the result, which may contain gaps as in assembleBlock. includes and depth
+// are as in asciiToDERImpl. The file is assembled with its own macros.
+func (opts *Options) include(token Token, includes []string, depth int) ([]byte, error) {
+	if opts.IncludeDir == "" {
+		return nil, &ParseError{token.Pos, errors.New("include is not enabled")}
+	}
+	path, err := opts.resolveInclude(token.Name, includes)
+	if err != nil {
+		return nil, &ParseError{token.Pos, &includeError{err: err}}
+	}
+	for i, prev := range includes {
+		if prev == path {
+			cycle := append(append([]string{}, includes[i:]...), path)
+			return nil, &ParseError{token.Pos, &includeError{err: fmt.Errorf("include cycle: %s", strings.Join(cycle, " -> "))}}
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, &ParseError{token.Pos, &includeError{err: err}}
+	}
+	defer f.Close()
+	// Copy includes so sibling includes do not share a backing array.
+	includes = append(includes[:len(includes):len(includes)], path)
+	// Errors within the file are reported at the include, so the file is
+	// not linted separately. Warnings are reported at the include in the
+	// same way.
+	fileOpts := *opts
+	fileOpts.lint = nil
+	if opts.Warn != nil {
+		fileOpts.Warn = func(warning *ParseError) {
+			if _, ok := warning.Err.(*includeError); !ok {
+				warning = &ParseError{warning.Pos, errIncludedWarning}
+			}
+			opts.Warn(&ParseError{token.Pos, &includeError{path, warning}})
+		}
+	}
+	scanner := NewReaderScanner(f)
+	scanner.config = fileOpts.scannerConfig()
+	out, err := asciiToDERImpl(nil, scanner, &fileOpts, opts.macros(), includes, nil, depth)
+	if err != nil {
+		// Syntax error messages may quote the file, so only their
+		// positions are reported.
+		if parseErr, ok := err.(*ParseError); ok {
+			if _, ok := parseErr.Err.(*includeError); !ok {
+				err = &ParseError{parseErr.Pos, errIncludedSyntax}
+			}
+		}
+		return nil, &ParseError{token.Pos, &includeError{path, err}}
+	}
+	return out, nil
+}
+
+// resolveInclude returns the absolute path, with symlinks resolved, of the file
+// named by name in an include. includes is as in asciiToDERImpl. It returns an
+// error if name is absolute or the file is not within opts.IncludeDir.
+func (opts *Options) resolveInclude(name string, includes []string) (string, error) {
+	if filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("include path %q must be relative", name)
+	}
+	root, err := filepath.Abs(opts.IncludeDir)
+	if err != nil {
+		return "", err
+	}
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return "", err
+	}
+	dir := root
+	if len(includes) > 0 {
+		dir = filepath.Dir(includes[len(includes)-1])
+	}
+	path := filepath.Clean(filepath.Join(dir, name))
+	if !isWithin(root, path) {
+		return "", fmt.Errorf("include path %q is outside the include directory", name)
+	}
+	if path, err = filepath.EvalSymlinks(path); err != nil {
+		return "", err
+	}
+	if !isWithin(root, path) {
+		return "", fmt.Errorf("include path %q is a link outside the include directory", name)
+	}
+	return path, nil
+}
+
+// isWithin returns whether path, which must be absolute and clean, is root or
+// is within it.
+func isWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+var (
+	// errIncludedSyntax replaces the message of a syntax error in an
+	// included file.
+	errIncludedSyntax = errors.New("syntax error")
+	// errIncludedWarning replaces the message of a warning in an included
+	// file.
+	errIncludedWarning = errors.New("likely mistake")
+)
+
+// An includeError is an error from an include directive. If path is non-empty,
+// err occurred within that file.
+type includeError struct {
+	path string
+	err  error
+}
+
+func (e *includeError) Error() string {
+	if e.path == "" {
+		return e.err.Error()
+	}
+	return fmt.Sprintf("in %s: %s", e.path, e.err)
+}
+
+const (
+	// DefaultMaxDepth is the default maximum nesting depth of curly braces.
+	DefaultMaxDepth = 1000
+	// DefaultMaxRepeatSize is the default maximum number of bytes emitted by
+	// a single repeat.
+	DefaultMaxRepeatSize = 64 << 20
+)
+
+// Options contains options for assembling DER ASCII. The zero value uses the
+// defaults.
+type Options struct {
+	// MaxDepth is the maximum nesting depth of curly braces. Inputs which
+	// nest more deeply are rejected. If zero or negative, DefaultMaxDepth is
+	// used.
+	MaxDepth int
+	// MaxRepeatSize is the maximum number of bytes a single repeat may
+	// emit. If zero or negative, DefaultMaxRepeatSize is used.
+	MaxRepeatSize int
+	// AllowNonzeroPadding, if true, allows the unused bits of the final
+	// byte in bits-unused to be non-zero, as in BER. By default, they must
+	// be zero, as in DER.
+	AllowNonzeroPadding bool
+	// CheckDER, if true, checks the output with CheckDER and fails if it is
+	// not valid DER.
+	CheckDER bool
+	// MaxLength is the maximum length, in bytes, of an element's contents
+	// within curly braces. If zero or negative, lengths are unlimited.
+	MaxLength int
+	// IncludeDir, if non-empty, enables the include directive and is the
+	// directory against which relative paths in the top-level input are
+	// resolved. Paths in an included file are resolved against that file's
+	// directory. Absolute paths, and paths which lead outside IncludeDir,
+	// including through symlinks, are rejected. Syntax errors in included
+	// files are reported by position only, so their contents are not
+	// revealed. If empty, include is an error, so the input cannot cause
+	// any filesystem access.
+	IncludeDir string
+	// Macros, if non-nil, predefines macros for use. The input may not
+	// redefine them. Names must be valid macro names.
+	Macros map[string][]byte
+	// AllowLeadingZeros, if true, allows decimal integers and OID arcs to
+	// have leading zeros, such as 007 or 1.02.3. By default, they are
+	// rejected as likely typos.
+	AllowLeadingZeros bool
+	// AllowInvalidStrings, if true, allows quoted strings directly within a
+	// string type, such as NumericString, to contain characters that type
+	// does not permit. By default, they are rejected.
+	AllowInvalidStrings bool
+	// Loose, if true, emits symbols which are not otherwise recognized, such
+	// as misspelled tag names, as their ASCII bytes. By default, they are an
+	// error. This is convenient for sketching inputs but may hide mistakes.
+	Loose bool
+	// TagTable, if non-nil, names additional tags, which may be used
+	// anywhere a built-in tag name may. Built-in names take precedence.
+	TagTable *lib.TagTable
+	// StrictTags, if true, requires every tag name or bracketed tag to be
+	// followed by its element's length: curly braces, or a modifier such as
+	// indefinite or long-form. This catches mistakes like INTEGER "x",
+	// which emits a tag with no length. Tag arguments to implicit and
+	// explicit are not affected.
+	StrictTags bool
+	// IgnoreTagCase, if true, matches bare tag names case-insensitively, so
+	// sequence and Integer are accepted for SEQUENCE and INTEGER. Each such
+	// match is reported to Warn with the canonical spelling. A name which
+	// matches more than one name, such as both a built-in name and a name in
+	// TagTable, matches neither. Names in brackets must still match exactly.
+	IgnoreTagCase bool
+	// Warn, if non-nil, is called with advisories about likely mistakes in
+	// the input which do not stop assembly, such as a u16 string directly
+	// within a PrintableString.
+	Warn func(*ParseError)
+
+	// sourceMap, if non-nil, collects mappings for ConvertWithSourceMap.
+	sourceMap *sourceMap
+	// gaps collects the unused bytes of reserved lengths while assembling.
+	gaps *gapList
+	// lint, if non-nil, collects recoverable syntax errors for Lint.
+	lint *[]*ParseError
+}
+
+// scannerConfig returns the settings from opts which affect scanning.
+func (opts *Options) scannerConfig() scannerConfig {
+	return scannerConfig{allowLeadingZeros: opts.AllowLeadingZeros, loose: opts.Loose, tags: opts.TagTable, ignoreTagCase: opts.IgnoreTagCase, allowInvalidStrings: opts.AllowInvalidStrings, warn: opts.Warn}
+}
+
+// macros returns a new macro table containing opts.Macros.
+func (opts *Options) macros() map[string]macro {
+	macros := make(map[string]macro, len(opts.Macros))
+	for name, value := range opts.Macros {
+		macros[name] = macro{value: value}
+	}
+	return macros
+}
+
+func (opts *Options) maxDepth() int {
+	if opts.MaxDepth <= 0 {
+		return DefaultMaxDepth
+	}
+	return opts.MaxDepth
+}
+
+func (opts *Options) maxRepeatSize() int {
+	if opts.MaxRepeatSize <= 0 {
+		return DefaultMaxRepeatSize
+	}
+	return opts.MaxRepeatSize
+}
+
+// Convert assembles input, in DER ASCII, with the options in opts and returns
+// the resulting byte string. Syntax errors are returned as a *ParseError. If
+// opts.CheckDER is set, invalid DER is returned as a *DERError.
+func (opts Options) Convert(input string) ([]byte, error) {
+	return opts.convert(NewScanner(input))
+}
+
+// Convert assembles input, in DER ASCII, and returns the resulting byte string.
+// Syntax errors are returned as a *ParseError.
+func Convert(input string) ([]byte, error) {
+	return Options{}.Convert(input)
+}
+
+// ConvertReader behaves like Convert, but incrementally reads the input from r.
+// Errors reading from r are returned as-is.
+func (opts Options) ConvertReader(r io.Reader) ([]byte, error) {
+	return opts.convert(NewReaderScanner(r))
+}
+
+func (opts *Options) convert(scanner *Scanner) ([]byte, error) {
+	scanner.config = opts.scannerConfig()
+	for name := range opts.Macros {
+		if !IsMacroName(name) {
+			return nil, fmt.Errorf("invalid macro name '%s'", name)
+		}
+	}
+	opts.gaps = new(gapList)
+	out, err := asciiToDERImpl(nil, scanner, opts, opts.macros(), nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	out = opts.gaps.compact(out, 0, opts.sourceMap, 0)
+	if opts.CheckDER {
+		if err := CheckDER(out); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// Lint checks input, in DER ASCII, with the options in opts and returns every
+// syntax error found, in order. Unlike Convert, it does not stop at the first
+// error. After each, it skips to the next whitespace or curly brace and
+// continues with the rest of the enclosing block, so later errors may be
+// consequences of earlier ones. It does not check the output with CheckDER.
+// Errors which do not concern the input, such as an invalid macro name in
+// opts, are returned as the error.
+func (opts Options) Lint(input string) ([]*ParseError, error) {
+	var errs []*ParseError
+	opts.lint = &errs
+	opts.CheckDER = false
+	if _, err := opts.convert(NewScanner(input)); err != nil {
+		parseErr, ok := err.(*ParseError)
+		if !ok {
+			return nil, err
 		}
+		errs = append(errs, parseErr)
 	}
+	return errs, nil
 }
 
-func asciiToDER(input string) ([]byte, error) {
-	scanner := newScanner(input)
-	return asciiToDERImpl(scanner, nil)
+// ConvertReader behaves like Convert, but incrementally reads the input from r.
+// Errors reading from r are returned as-is.
+func ConvertReader(r io.Reader) ([]byte, error) {
+	return Options{}.ConvertReader(r)
 }
@@ -12,22 +12,60 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package ascii2der
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/iotest"
+
+	"github.com/google/der-ascii/lib"
 )
 
-func tokenToString(kind tokenKind) string {
+func tokenToString(kind TokenKind) string {
 	switch kind {
-	case tokenBytes:
+	case TokenBytes:
 		return "bytes"
-	case tokenLeftCurly:
+	case TokenLeftCurly:
 		return "left-curly"
-	case tokenRightCurly:
+	case TokenRightCurly:
 		return "right-curly"
-	case tokenEOF:
+	case TokenIndefinite:
+		return "indefinite"
+	case TokenSetOf:
+		return "set-of"
+	case TokenLongForm:
+		return "long-form"
+	case TokenRepeat:
+		return "repeat"
+	case TokenBitsUnused:
+		return "bits-unused"
+	case TokenDefine:
+		return "define"
+	case TokenUse:
+		return "use"
+	case TokenInclude:
+		return "include"
+	case TokenImplicit:
+		return "implicit"
+	case TokenExplicit:
+		return "explicit"
+	case TokenRaw:
+		return "raw"
+	case TokenConcat:
+		return "concat"
+	case TokenExpectLen:
+		return "expect-len"
+	case TokenTruncate:
+		return "truncate"
+	case TokenEOF:
 		return "EOF"
 	default:
 		panic(kind)
@@ -36,7 +74,7 @@ func tokenToString(kind tokenKind) string {
 
 var scannerTests = []struct {
 	in     string
-	tokens []token
+	tokens []Token
 	ok     bool
 }{
 	{
@@ -51,39 +89,187 @@ SEQUENCE[0]{}SEQUENCE}1}-1}1.2}#comment
 
 # Escape sequences.
 "\"\n\x42\\"
+"\t\r\0\01"
+"\u00e9\U0001F600"
+
+# Prefixed strings.
+u16"a\u00e9\U0001F600" u32"a\u00e9"
 
 # Uppercase hex is fine too.
-` + "`AABBCC`",
-		[]token{
-			{Kind: tokenBytes, Value: []byte{0x30}},
-			{Kind: tokenBytes, Value: []byte{0x30}},
-			{Kind: tokenBytes, Value: []byte{0x01}},
-			{Kind: tokenBytes, Value: []byte{0xff}},
-			{Kind: tokenBytes, Value: []byte{42, 3, 4}},
-			{Kind: tokenBytes, Value: []byte{0xaa, 0xbb, 0xcc}},
-			{Kind: tokenBytes, Value: []byte("hello")},
-			{Kind: tokenLeftCurly},
-			{Kind: tokenRightCurly},
-			{Kind: tokenBytes, Value: []byte{0x30}},
-			{Kind: tokenBytes, Value: []byte{0xa0}},
-			{Kind: tokenLeftCurly},
-			{Kind: tokenRightCurly},
-			{Kind: tokenBytes, Value: []byte{0x30}},
-			{Kind: tokenRightCurly},
-			{Kind: tokenBytes, Value: []byte{0x01}},
-			{Kind: tokenRightCurly},
-			{Kind: tokenBytes, Value: []byte{0xff}},
-			{Kind: tokenRightCurly},
-			{Kind: tokenBytes, Value: []byte{42}},
-			{Kind: tokenRightCurly},
-			{Kind: tokenBytes, Value: []byte{'"', '\n', 0x42, '\\'}},
-			{Kind: tokenBytes, Value: []byte{0xaa, 0xbb, 0xcc}},
-			{Kind: tokenEOF},
+` + "`AABBCC`" + `
+
+# Hex literals may contain whitespace.
+` + "`30 82\n01\t0a\r\n`" + `
+
+# Base64 literals.
+|qrvM| |AQ==| |AQI=| |qr
+ vM| ||
+
+# Digit separators.
+1_000 -1_0 1.2.840.10_045.3.1.7
+
+# Hexadecimal and binary integers.
+0xFF00 -0xff 0b1010 -0b1 0x7fffffffffffffff -0x8000000000000000
+
+# Named OIDs.
+rsaEncryption id-ecPublicKey
+
+# BOOLEAN values.
+TRUE FALSE
+
+# NULL is a complete element unless followed by a length prefix.
+NULL NULL {} NULL # comment
+{} NULL
+
+# Times.
+utctime("2021-01-01T00:00:00Z") gentime( "2021-01-01T00:00:00.50Z" # comment
+)
+
+# Bit strings.
+bits("") bits("101101")
+
+# REALs.
+real(1.5) real(-0) real(inf) real-decimal(1.5) real-decimal(0)
+
+# Relative OIDs.
+relative-oid(3.14.25) relative-oid( 0.128_000 ) relative-oid(40)
+
+# Fixed-width integers.
+int-width(4, 1) int-width(2, -1) int-width(1, 0x7f)
+
+# Single bytes.
+byte(0x30) byte(255) byte(0)
+
+# Keywords.
+indefinite set-of implicit explicit raw concat( 1 "}" ) long-form(1) long-form( 0x7e ) repeat(0) repeat(1_0) bits-unused(7) expect-len(0) truncate(1)
+
+# Macros.
+define rsa-alg { 1 } use rsa-alg
+use
+  # comment
+  FOO_2
+
+# Includes.
+include "common.txt" include
+  "dir/a b.txt"
+
+# Block comments.
+/* comment */ 1/* multi-line
+comment with "quotes" and /* nesting */2 bits(/* ) */ "1")/**/NULL /* */ {}
+
+# Integers larger than 64 bits.
+9223372036854775808 -9223372036854775809 18_446_744_073_709_551_616`,
+		[]Token{
+			{Kind: TokenBytes, Value: []byte{0x30}},
+			{Kind: TokenBytes, Value: []byte{0x30}},
+			{Kind: TokenBytes, Value: []byte{0x01}},
+			{Kind: TokenBytes, Value: []byte{0xff}},
+			{Kind: TokenBytes, Value: []byte{42, 3, 4}},
+			{Kind: TokenBytes, Value: []byte{0xaa, 0xbb, 0xcc}},
+			{Kind: TokenBytes, Value: []byte("hello")},
+			{Kind: TokenLeftCurly},
+			{Kind: TokenRightCurly},
+			{Kind: TokenBytes, Value: []byte{0x30}},
+			{Kind: TokenBytes, Value: []byte{0xa0}},
+			{Kind: TokenLeftCurly},
+			{Kind: TokenRightCurly},
+			{Kind: TokenBytes, Value: []byte{0x30}},
+			{Kind: TokenRightCurly},
+			{Kind: TokenBytes, Value: []byte{0x01}},
+			{Kind: TokenRightCurly},
+			{Kind: TokenBytes, Value: []byte{0xff}},
+			{Kind: TokenRightCurly},
+			{Kind: TokenBytes, Value: []byte{42}},
+			{Kind: TokenRightCurly},
+			{Kind: TokenBytes, Value: []byte{'"', '\n', 0x42, '\\'}},
+			{Kind: TokenBytes, Value: []byte{'\t', '\r', 0, 0, '1'}},
+			{Kind: TokenBytes, Value: []byte("\u00e9\U0001F600")},
+			{Kind: TokenBytes, Value: []byte{0x00, 'a', 0x00, 0xe9, 0xd8, 0x3d, 0xde, 0x00}},
+			{Kind: TokenBytes, Value: []byte{0x00, 0x00, 0x00, 'a', 0x00, 0x00, 0x00, 0xe9}},
+			{Kind: TokenBytes, Value: []byte{0xaa, 0xbb, 0xcc}},
+			{Kind: TokenBytes, Value: []byte{0x30, 0x82, 0x01, 0x0a}},
+			{Kind: TokenBytes, Value: []byte{0xaa, 0xbb, 0xcc}},
+			{Kind: TokenBytes, Value: []byte{0x01}},
+			{Kind: TokenBytes, Value: []byte{0x01, 0x02}},
+			{Kind: TokenBytes, Value: []byte{0xaa, 0xbb, 0xcc}},
+			{Kind: TokenBytes, Value: []byte{}},
+			{Kind: TokenBytes, Value: []byte{0x03, 0xe8}},
+			{Kind: TokenBytes, Value: []byte{0xf6}},
+			{Kind: TokenBytes, Value: []byte{0x2a, 0x86, 0x48, 0xce, 0x3d, 0x03, 0x01, 0x07}},
+			{Kind: TokenBytes, Value: []byte{0x00, 0xff, 0x00}},
+			{Kind: TokenBytes, Value: []byte{0xff, 0x01}},
+			{Kind: TokenBytes, Value: []byte{0x0a}},
+			{Kind: TokenBytes, Value: []byte{0xff}},
+			{Kind: TokenBytes, Value: []byte{0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+			{Kind: TokenBytes, Value: []byte{0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+			{Kind: TokenBytes, Value: []byte{0x2a, 0x86, 0x48, 0x86, 0xf7, 0x0d, 0x01, 0x01, 0x01}},
+			{Kind: TokenBytes, Value: []byte{0x2a, 0x86, 0x48, 0xce, 0x3d, 0x02, 0x01}},
+			{Kind: TokenBytes, Value: []byte{0xff}},
+			{Kind: TokenBytes, Value: []byte{0x00}},
+			{Kind: TokenBytes, Value: []byte{0x05, 0x00}},
+			{Kind: TokenBytes, Value: []byte{0x05}},
+			{Kind: TokenLeftCurly},
+			{Kind: TokenRightCurly},
+			{Kind: TokenBytes, Value: []byte{0x05}},
+			{Kind: TokenLeftCurly},
+			{Kind: TokenRightCurly},
+			{Kind: TokenBytes, Value: []byte{0x05, 0x00}},
+			{Kind: TokenBytes, Value: []byte("210101000000Z")},
+			{Kind: TokenBytes, Value: []byte("20210101000000.5Z")},
+			{Kind: TokenBytes, Value: []byte{0x00}},
+			{Kind: TokenBytes, Value: []byte{0x02, 0xb4}},
+			{Kind: TokenBytes, Value: []byte{0x80, 0xff, 0x03}},
+			{Kind: TokenBytes, Value: []byte{0x43}},
+			{Kind: TokenBytes, Value: []byte{0x40}},
+			{Kind: TokenBytes, Value: []byte("\x0315.E-1")},
+			{Kind: TokenBytes, Value: []byte{}},
+			{Kind: TokenBytes, Value: []byte{0x03, 0x0e, 0x19}},
+			{Kind: TokenBytes, Value: []byte{0x00, 0x87, 0xe8, 0x00}},
+			{Kind: TokenBytes, Value: []byte{0x28}},
+			{Kind: TokenBytes, Value: []byte{0x00, 0x00, 0x00, 0x01}},
+			{Kind: TokenBytes, Value: []byte{0xff, 0xff}},
+			{Kind: TokenBytes, Value: []byte{0x7f}},
+			{Kind: TokenBytes, Value: []byte{0x30}},
+			{Kind: TokenBytes, Value: []byte{0xff}},
+			{Kind: TokenBytes, Value: []byte{0x00}},
+			{Kind: TokenIndefinite},
+			{Kind: TokenSetOf},
+			{Kind: TokenImplicit},
+			{Kind: TokenExplicit},
+			{Kind: TokenRaw},
+			{Kind: TokenConcat},
+			{Kind: TokenLongForm},
+			{Kind: TokenLongForm},
+			{Kind: TokenRepeat},
+			{Kind: TokenRepeat},
+			{Kind: TokenBitsUnused},
+			{Kind: TokenExpectLen},
+			{Kind: TokenTruncate},
+			{Kind: TokenDefine, Name: "rsa-alg"},
+			{Kind: TokenLeftCurly},
+			{Kind: TokenBytes, Value: []byte{0x01}},
+			{Kind: TokenRightCurly},
+			{Kind: TokenUse, Name: "rsa-alg"},
+			{Kind: TokenUse, Name: "FOO_2"},
+			{Kind: TokenInclude, Name: "common.txt"},
+			{Kind: TokenInclude, Name: "dir/a b.txt"},
+			{Kind: TokenBytes, Value: []byte{0x01}},
+			{Kind: TokenBytes, Value: []byte{0x02}},
+			{Kind: TokenBytes, Value: []byte{0x07, 0x80}},
+			{Kind: TokenBytes, Value: []byte{0x05}},
+			{Kind: TokenLeftCurly},
+			{Kind: TokenRightCurly},
+			{Kind: TokenBytes, Value: []byte{0x00, 0x80, 0, 0, 0, 0, 0, 0, 0}},
+			{Kind: TokenBytes, Value: []byte{0xff, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+			{Kind: TokenBytes, Value: []byte{0x01, 0, 0, 0, 0, 0, 0, 0, 0}},
+			{Kind: TokenEOF},
 		},
 		true,
 	},
 	// Garbage tokens.
 	{"SEQUENC", nil, false},
+	{"true", nil, false},
+	{"rsaencryption", nil, false},
 	{"1...2", nil, false},
 	// Unmatched [.
 	{"[SEQUENCE", nil, false},
@@ -91,35 +277,323 @@ SEQUENCE[0]{}SEQUENCE}1}-1}1.2}#comment
 	{`"`, nil, false},
 	// Unmatched `.
 	{"`", nil, false},
+	// Misplaced digit separators.
+	{"_1", nil, false},
+	{"1_", nil, false},
+	{"1__0", nil, false},
+	{"-_1", nil, false},
+	{"1._2", nil, false},
+	{"1_.2", nil, false},
+	{"1.2_", nil, false},
 	// Integer overflow.
-	{"999999999999999999999999999999999999999999999999999999999999999", nil, false},
+	{"0x8000000000000000", nil, false},
+	{"-0x8000000000000001", nil, false},
+	{"0b" + strings.Repeat("1", 64), nil, false},
+	// Malformed hexadecimal and binary integers.
+	{"0x", nil, false},
+	{"0xg", nil, false},
+	{"0b2", nil, false},
+	{"0X1", nil, false},
 	// Invalid OID.
 	{"1.99.1", nil, false},
+	{"0.40", nil, false},
+	{"3.1", nil, false},
+	{"2.-1", nil, false},
+	{"-1.2", nil, false},
 	// OID component overflow.
 	{"1.1.99999999999999999999999999999999999999999999999999999999999999999", nil, false},
+	// Bad base64.
+	{"|qrvM", nil, false},
+	{"|qrv|", nil, false},
+	{"|qr!M|", nil, false},
+	{"|AQ=A|", nil, false},
 	// Bad tag string.
 	{"[THIS IS NOT A VALID TAG]", nil, false},
 	// Bad hex bytes.
 	{"`hi there!`", nil, false},
+	{"`aa b`", nil, false},
+	{"`aa\vbb`", nil, false},
 	// Bad or truncated escape sequences.
 	{`"\`, nil, false},
 	{`"\x`, nil, false},
 	{`"\x1`, nil, false},
 	{`"\x??"`, nil, false},
 	{`"\?"`, nil, false},
+	{`"\a"`, nil, false},
+	{`"\u`, nil, false},
+	{`"\u00e`, nil, false},
+	{`"\u00eg"`, nil, false},
+	{`"\U0001F60"`, nil, false},
+	// Invalid code points.
+	{`"\ud800"`, nil, false},
+	{`"\udfff"`, nil, false},
+	{`"\U00110000"`, nil, false},
+	{`"\Uffffffff"`, nil, false},
+	// Prefixed strings must directly precede the quote.
+	{`u16 "a"`, nil, false},
+	{`u8"a"`, nil, false},
+	// Prefixed strings do not allow byte escapes or invalid UTF-8.
+	{`u16"\x00"`, nil, false},
+	{"u16\"\xff\"", nil, false},
+	{`u32"`, nil, false},
+	// Bad functions.
+	{"bogus()", nil, false},
+	{"utctime(", nil, false},
+	{`utctime(")")`, nil, false},
+	{"utctime()", nil, false},
+	{`utctime(2021)`, nil, false},
+	{`utctime("2021-01-01T00:00:00Z" "")`, nil, false},
+	{`utctime("2021-01-01T00:00:00")`, nil, false},
+	{`utctime("2021-13-01T00:00:00Z")`, nil, false},
+	{`utctime("2021-02-29T00:00:00Z")`, nil, false},
+	{`utctime("2021-01-01T24:00:00Z")`, nil, false},
+	{`utctime("2050-01-01T00:00:00Z")`, nil, false},
+	{`utctime("2021-01-01T00:00:00.5Z")`, nil, false},
+	{`gentime("2021-01-01")`, nil, false},
+	{`bits("102")`, nil, false},
+	{`bits(101)`, nil, false},
+	{"real()", nil, false},
+	{"real(1, 2)", nil, false},
+	{"real(one)", nil, false},
+	{"real(1e400)", nil, false},
+	{"real-decimal(1.5.1)", nil, false},
+	{"relative-oid()", nil, false},
+	{"relative-oid(1, 2)", nil, false},
+	{"relative-oid(1..2)", nil, false},
+	{"relative-oid(.1)", nil, false},
+	{"relative-oid(-1)", nil, false},
+	{"relative-oid(4294967296)", nil, false},
+	{"byte()", nil, false},
+	{"byte(256)", nil, false},
+	{"byte(-1)", nil, false},
+	{"byte(1, 2)", nil, false},
+	{"int-width(4)", nil, false},
+	{"int-width(0, 0)", nil, false},
+	{"int-width(1025, 0)", nil, false},
+	{"int-width(1, 128)", nil, false},
+	{"int-width(1, -129)", nil, false},
+	{"int-width(8, x)", nil, false},
+	{"int-width(1, 0X7f)", nil, false},
+	{"uint()", nil, false},
+	{"uint(1, 2)", nil, false},
+	{"uint(-1)", nil, false},
+	{"uint(007)", nil, false},
+	{"uint(18446744073709551616)", nil, false},
+	{"uint(x)", nil, false},
+	{"uint(0o17)", nil, false},
+	{"long-form()", nil, false},
+	{"long-form(0)", nil, false},
+	{"long-form(127)", nil, false},
+	{"long-form(1, 2)", nil, false},
+	{"long-form(1,)", nil, false},
+	{"bits-unused(-1)", nil, false},
+	{"bits-unused(8)", nil, false},
+	{"repeat()", nil, false},
+	{"repeat(-1)", nil, false},
+	{"repeat(1, 2)", nil, false},
+	{"define", nil, false},
+	{"define {}", nil, false},
+	{"use", nil, false},
+	{"use !", nil, false},
+	{"long-form(1 2)", nil, false},
+	{"long-form(one)", nil, false},
+	// Unterminated or stray block comments.
+	{"/* comment", nil, false},
+	{"/* comment *", nil, false},
+	{"bits(/* )", nil, false},
+	{"*/", nil, false},
+	{"/", nil, false},
 	// Tokenization works up to a syntax error.
-	{`"hello" "world`, []token{{Kind: tokenBytes, Value: []byte("hello")}}, false},
+	{`"hello" "world`, []Token{{Kind: TokenBytes, Value: []byte("hello")}}, false},
+}
+
+var scannerErrorTests = []struct {
+	in     string
+	line   int
+	column int
+}{
+	// Unterminated strings report the opening quote.
+	{`"hello`, 1, 1},
+	{"INTEGER\n  u16\"hello", 2, 6},
+	// Bad escapes report the backslash.
+	{`  "abc\q"`, 1, 7},
+	{`"\u12`, 1, 2},
+	{`"\ud800"`, 1, 2},
+	// Bad hex literals report the offending byte, or the start of the
+	// literal if the length is wrong.
+	{"SEQUENCE `aabbzz`", 1, 15},
+	{"SEQUENCE\n`aabbzz`", 2, 6},
+	{"SEQUENCE `aa\n bb zz`", 2, 5},
+	{"SEQUENCE `aab`", 1, 11},
+	// Bad bytes in hex report the offending digit, or the start of the byte
+	// if the length is wrong.
+	{"hex(30 8z)", 1, 9},
+	{"hex(30\n  0x8z)", 2, 6},
+	{"hex(30 823)", 1, 8},
+	{"hex(30 0x823)", 1, 10},
+	{"hex(30 8)", 1, 8},
+	// Unterminated hex literals report the opening backtick, even if the
+	// literal spans lines.
+	{"  `aa", 1, 3},
+	{"`aa\nbb\n", 1, 1},
+	// There are no escapes in hex literals, so a backslash is an invalid
+	// byte and the next backtick ends the literal.
+	{"`aa\\`", 1, 4},
+	// Unterminated tags report the opening bracket.
+	{"SEQUENCE {\n  [0 PRIMITIVE", 2, 3},
+	// Bad base64 literals report the offending character, or the start of
+	// the contents if the length is wrong.
+	{"|qr\n!M|", 2, 1},
+	{"  |qrv|", 1, 4},
+	// Unterminated base64 literals report the opening delimiter.
+	{"1 |qrvM", 1, 3},
+	// Unterminated comments report the start of the comment.
+	{"1\n  /* comment\n", 2, 3},
+	{"bits(\"1\" /* )", 1, 10},
+	// Other tokens report the start of the token.
+	{"1 2 BOGUS", 1, 5},
+	{"\n[BOGUS]", 2, 1},
+	{"\r\n[BOGUS]", 2, 1},
+	{"\r[BOGUS]", 2, 1},
+	{"# comment\r[BOGUS]", 2, 1},
+	{"`00\r\n0g`", 2, 2},
+	{"long-form(# comment\r 1 x)", 2, 4},
+	{"[0", 1, 1},
+	{"  1.99.1", 1, 3},
+	{"1 007", 1, 3},
+	{"1.02.3", 1, 1},
+	{"relative-oid(\n  1.02)", 2, 3},
+	{"byte(010)", 1, 6},
+	{"key-usage(digitalSignature,\n  bogus)", 2, 3},
+	{"utctime(\"2050-01-01T00:00:00Z\")", 1, 9},
+}
+
+func TestScannerErrorPosition(t *testing.T) {
+	for i, tt := range scannerErrorTests {
+		scanner := NewScanner(tt.in)
+		var err error
+		for err == nil {
+			var tok Token
+			tok, err = scanner.Next()
+			if tok.Kind == TokenEOF {
+				break
+			}
+		}
+		parseErr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("%d. Scanning %q gave error %v, wanted a *ParseError.", i, tt.in, err)
+			continue
+		}
+		if parseErr.Pos.Line != tt.line || parseErr.Pos.Column != tt.column {
+			t.Errorf("%d. Scanning %q gave error at line %d column %d, wanted line %d column %d.", i, tt.in, parseErr.Pos.Line, parseErr.Pos.Column, tt.line, tt.column)
+		}
+	}
+}
+
+func TestLineEndings(t *testing.T) {
+	// \n, \r\n, and a lone \r should each count as one line break, including
+	// inside comments and hex and base64 literals.
+	lines := []string{
+		"SEQUENCE { # comment",
+		"  INTEGER { 1 } `00",
+		"01` |AAAA",
+		"AA==| } /* block",
+		"comment */ NULL {}",
+	}
+	want, ok := scanAll(strings.Join(lines, "\n"))
+	if !ok {
+		t.Fatalf("Scanning with \\n line endings failed.")
+	}
+	for _, sep := range []string{"\r\n", "\r"} {
+		got, ok := scanAll(strings.Join(lines, sep))
+		if !ok {
+			t.Errorf("Scanning with %q line endings failed.", sep)
+			continue
+		}
+		if len(got) != len(want) {
+			t.Errorf("Scanning with %q line endings gave %d tokens, wanted %d.", sep, len(got), len(want))
+			continue
+		}
+		for i := range got {
+			if got[i].Pos.Line != want[i].Pos.Line || got[i].Pos.Column != want[i].Pos.Column {
+				t.Errorf("Token %d with %q line endings was at line %d column %d, wanted line %d column %d.", i, sep, got[i].Pos.Line, got[i].Pos.Column, want[i].Pos.Line, want[i].Pos.Column)
+			}
+		}
+	}
+}
+
+var tokenEndTests = []struct {
+	in        string
+	pos, end  []int
+	line, col int
+}{
+	{"1 { }", []int{0, 2, 4, 5}, []int{1, 3, 5, 5}, 1, 6},
+	{"SEQUENCE{`0102`}", []int{0, 8, 9, 15, 16}, []int{8, 9, 15, 16, 16}, 1, 17},
+	{"define a {} use  b", []int{0, 9, 10, 12, 18}, []int{8, 10, 11, 18, 18}, 1, 19},
+	{"long-form( 2 ) NULL", []int{0, 15, 19}, []int{14, 19, 19}, 1, 20},
+	{"\"a\nb\" # comment\n", []int{0, 16}, []int{5, 16}, 3, 1},
+}
+
+func TestTokenEnd(t *testing.T) {
+	for i, tt := range tokenEndTests {
+		tokens, err := scanAllTokens(NewScanner(tt.in))
+		if err != nil {
+			t.Errorf("%d. Scanning %q failed: %s.", i, tt.in, err)
+			continue
+		}
+		if len(tokens) != len(tt.pos) {
+			t.Errorf("%d. Scanning %q gave %d tokens, wanted %d.", i, tt.in, len(tokens), len(tt.pos))
+			continue
+		}
+		for j, tok := range tokens {
+			if tok.Pos.Offset != tt.pos[j] || tok.End.Offset != tt.end[j] {
+				t.Errorf("%d. Token %d of %q spanned [%d, %d), wanted [%d, %d).", i, j, tt.in, tok.Pos.Offset, tok.End.Offset, tt.pos[j], tt.end[j])
+			}
+		}
+		if end := tokens[len(tokens)-1].End; end.Line != tt.line || end.Column != tt.col {
+			t.Errorf("%d. Scanning %q ended at line %d column %d, wanted line %d column %d.", i, tt.in, end.Line, end.Column, tt.line, tt.col)
+		}
+	}
+}
+
+func TestParseErrorString(t *testing.T) {
+	err := &ParseError{Position{Offset: 20, Line: 3, Column: 17}, errors.New("oops")}
+	if got, want := err.Error(), "line 3 column 17: oops"; got != want {
+		t.Errorf("err.Error() = %q, wanted %q.", got, want)
+	}
 }
 
-func scanAll(in string) (tokens []token, ok bool) {
-	scanner := newScanner(in)
+var oidErrorTests = []struct {
+	in  string
+	err string
+}{
+	{"3.1", "line 1 column 1: invalid OID '3.1': first OID arc must be 0, 1, or 2, got 3"},
+	{"1.40", "line 1 column 1: invalid OID '1.40': second OID arc must be less than 40 when the first arc is 1, got 40"},
+	{"2.4294967295", "line 1 column 1: invalid OID '2.4294967295': second OID arc is too large, got 4294967295"},
+	{"1.2.4294967296", "line 1 column 1: arc 3 (4294967296) does not fit in 32 bits"},
+	{"2.-1", "line 1 column 1: invalid OID '2.-1': arcs may not be negative"},
+}
+
+func TestOIDErrors(t *testing.T) {
+	for i, tt := range oidErrorTests {
+		_, err := NewScanner(tt.in).Next()
+		if err == nil {
+			t.Errorf("%d. Next() on %q unexpectedly succeeded.", i, tt.in)
+		} else if err.Error() != tt.err {
+			t.Errorf("%d. Next() on %q failed with %q, wanted %q.", i, tt.in, err, tt.err)
+		}
+	}
+}
+
+func scanAll(in string) (tokens []Token, ok bool) {
+	scanner := NewScanner(in)
 	for {
 		token, err := scanner.Next()
 		if err != nil {
 			return
 		}
 		tokens = append(tokens, token)
-		if token.Kind == tokenEOF {
+		if token.Kind == TokenEOF {
 			ok = true
 			return
 		}
@@ -136,8 +610,10 @@ func TestScanner(t *testing.T) {
 		for j := 0; j < len(tokens) && j < len(tt.tokens); j++ {
 			if tokens[j].Kind != tt.tokens[j].Kind {
 				t.Errorf("%d. token %d was %s, wanted %s.", i, j, tokenToString(tokens[j].Kind), tokenToString(tt.tokens[j].Kind))
-			} else if tokens[j].Kind == tokenBytes && !bytes.Equal(tokens[j].Value, tt.tokens[j].Value) {
+			} else if tokens[j].Kind == TokenBytes && !bytes.Equal(tokens[j].Value, tt.tokens[j].Value) {
 				t.Errorf("%d. token %d had value %x, wanted %x.", i, j, tokens[j].Value, tt.tokens[j].Value)
+			} else if tokens[j].Name != tt.tokens[j].Name {
+				t.Errorf("%d. token %d had name %q, wanted %q.", i, j, tokens[j].Name, tt.tokens[j].Name)
 			}
 		}
 
@@ -153,27 +629,1072 @@ var asciiToDERTests = []struct {
 	ok  bool
 }{
 	{"SEQUENCE { INTEGER { 42 } INTEGER { 1 } }", []byte{0x30, 0x06, 0x02, 0x01, 0x2a, 0x02, 0x01, 0x01}, true},
+	// Long lengths are filled in place.
+	{"SEQUENCE { SEQUENCE { OCTET_STRING { `" + strings.Repeat("aa", 200) + "` } } }", append([]byte{0x30, 0x81, 0xce, 0x30, 0x81, 0xcb, 0x04, 0x81, 0xc8}, bytes.Repeat([]byte{0xaa}, 200)...), true},
+	// Constructs which read their contents see them without the unused
+	// bytes reserved for nested lengths.
+	{"BIT_STRING { bits-unused(1) { SEQUENCE { SEQUENCE {} } } }", []byte{0x03, 0x05, 0x01, 0x30, 0x02, 0x30, 0x00}, true},
+	{"repeat(2) { SEQUENCE { NULL {} } }", []byte{0x30, 0x02, 0x05, 0x00, 0x30, 0x02, 0x05, 0x00}, true},
+	{"SEQUENCE long-form(2) { SEQUENCE {} }", []byte{0x30, 0x82, 0x00, 0x02, 0x30, 0x00}, true},
+	{"SEQUENCE expect-len(2) { SEQUENCE {} }", []byte{0x30, 0x02, 0x30, 0x00}, true},
+	{"explicit [0] { SEQUENCE { SEQUENCE {} } }", []byte{0xa0, 0x04, 0x30, 0x02, 0x30, 0x00}, true},
+	{"SET set-of { SEQUENCE { NULL {} } SEQUENCE {} }", []byte{0x31, 0x06, 0x30, 0x00, 0x30, 0x02, 0x05, 0x00}, true},
+	// NULL and NULL {} are equivalent.
+	{"SEQUENCE { OBJECT_IDENTIFIER { 1.2.3 } NULL }", []byte{0x30, 0x06, 0x06, 0x02, 0x2a, 0x03, 0x05, 0x00}, true},
+	{"SEQUENCE { OBJECT_IDENTIFIER { 1.2.3 } NULL {} }", []byte{0x30, 0x06, 0x06, 0x02, 0x2a, 0x03, 0x05, 0x00}, true},
+	// A comment between NULL and its braces ends at any line break.
+	{"NULL # c\n{}", []byte{0x05, 0x00}, true},
+	{"NULL # c\r\n{}", []byte{0x05, 0x00}, true},
+	{"NULL # c\r{}", []byte{0x05, 0x00}, true},
+	// Other length prefixes also make NULL a tag.
+	{"NULL long-form(2) {}", []byte{0x05, 0x82, 0x00, 0x00}, true},
+	{"NULL /* c */ long-form(2) {}", []byte{0x05, 0x82, 0x00, 0x00}, true},
+	{"NULL truncate(3) {}", []byte{0x05, 0x03}, true},
+	{"NULL expect-len(0) {}", []byte{0x05, 0x00}, true},
+	{"NULL indefinite {}", nil, false},
+	{"[NULL CONSTRUCTED] indefinite {}", []byte{0x25, 0x80, 0x00, 0x00}, true},
+	{"NULL set-of {}", []byte{0x05, 0x00}, true},
+	// Symbols which merely begin with a length keyword do not.
+	{"NULL long-form {}", nil, false},
+	{"NULL indefinite-x", nil, false},
+	{"NULL set-of(1)", nil, false},
+	// gentime converts offsets to UTC and drops trailing zeros, so it
+	// always emits DER.
+	{`gentime("2021-01-01T01:00:00.500+01:00")`, []byte("20210101000000.5Z"), true},
+	// algorithm emits a complete AlgorithmIdentifier, with NULL parameters
+	// only for algorithms which use them.
+	{"algorithm(sha256WithRSAEncryption)", []byte{0x30, 0x0d, 0x06, 0x09, 0x2a, 0x86, 0x48, 0x86, 0xf7, 0x0d, 0x01, 0x01, 0x0b, 0x05, 0x00}, true},
+	{"SEQUENCE { algorithm( ecdsa-with-SHA256 ) }", []byte{0x30, 0x0c, 0x30, 0x0a, 0x06, 0x08, 0x2a, 0x86, 0x48, 0xce, 0x3d, 0x04, 0x03, 0x02}, true},
+	{"algorithm(id-Ed25519)", []byte{0x30, 0x05, 0x06, 0x03, 0x2b, 0x65, 0x70}, true},
+	{"algorithm(commonName)", nil, false},
+	{"algorithm(id-ecPublicKey)", nil, false},
+	{"algorithm()", nil, false},
+	{"algorithm(rsaEncryption, NULL)", nil, false},
+	{`algorithm("rsaEncryption")`, nil, false},
+	// key-usage sets the named bits, trimming trailing zeros.
+	{"BIT_STRING { key-usage(digitalSignature, keyCertSign) }", []byte{0x03, 0x02, 0x02, 0x84}, true},
+	{"BIT_STRING { key-usage( keyCertSign,cRLSign ) }", []byte{0x03, 0x02, 0x01, 0x06}, true},
+	{"BIT_STRING { key-usage(cRLSign, digitalSignature) }", []byte{0x03, 0x02, 0x01, 0x82}, true},
+	{"BIT_STRING { key-usage(decipherOnly) }", []byte{0x03, 0x03, 0x07, 0x00, 0x80}, true},
+	{"BIT_STRING { key-usage() }", []byte{0x03, 0x01, 0x00}, true},
+	{"BIT_STRING { key-usage(keyCertSign, keyCertSign) }", nil, false},
+	{"BIT_STRING { key-usage(keyCertSign keyAgreement) }", nil, false},
+	{`BIT_STRING { key-usage("keyCertSign") }`, nil, false},
+	// expect-len emits a length prefix, like plain curly braces, but checks
+	// the length of the contents.
+	{"SEQUENCE expect-len(3) { INTEGER { 1 } }", []byte{0x30, 0x03, 0x02, 0x01, 0x01}, true},
+	{"OCTET_STRING expect-len(0) {}", []byte{0x04, 0x00}, true},
+	{"SEQUENCE expect-len(4) { INTEGER { 1 } }", nil, false},
+	{"SEQUENCE expect-len(-1) {}", nil, false},
+	{"SEQUENCE expect-len(3) INTEGER { 1 }", nil, false},
+	// truncate emits a length prefix with the declared length, followed by
+	// the possibly shorter contents.
+	{"SEQUENCE truncate(5) { INTEGER { 1 } }", []byte{0x30, 0x05, 0x02, 0x01, 0x01}, true},
+	{"OCTET_STRING truncate(256) { `aa` }", []byte{0x04, 0x82, 0x01, 0x00, 0xaa}, true},
+	{"SEQUENCE { INTEGER truncate(2) { `01` } }", []byte{0x30, 0x03, 0x02, 0x02, 0x01}, true},
+	{"OCTET_STRING truncate(1) { `aa` }", []byte{0x04, 0x01, 0xaa}, true},
+	{"OCTET_STRING truncate(1) { `aabb` }", nil, false},
+	{"OCTET_STRING truncate(-1) {}", nil, false},
+	{"OCTET_STRING truncate(0x80000000) {}", nil, false},
+	// DATE and DATE-TIME.
+	{`DATE { date("2021-02-28") }`, append([]byte{0x1f, 0x1f, 0x08}, "20210228"...), true},
+	{`DATE-TIME { date-time("2020-02-29T23:59:59") }`, append([]byte{0x1f, 0x21, 0x0e}, "20200229235959"...), true},
+	{`DATE { date("2021-02-29") }`, nil, false},
+	{`DATE { date("2021-13-01") }`, nil, false},
+	{`DATE { date("1581-12-31") }`, nil, false},
+	{`DATE { date("2021-1-1") }`, nil, false},
+	{`DATE { date("2021-01-01T00:00:00") }`, nil, false},
+	{`DATE-TIME { date-time("2021-01-01T24:00:00") }`, nil, false},
+	{`DATE-TIME { date-time("2021-01-01T00:60:00") }`, nil, false},
+	{`DATE-TIME { date-time("2021-01-01T00:00:60") }`, nil, false},
+	{`DATE-TIME { date-time("2021-01-01T00:00:00Z") }`, nil, false},
+	{`DATE-TIME { date-time("2021-01-01T00:00:00.5") }`, nil, false},
+	{`DATE-TIME { date-time(2021) }`, nil, false},
+	// Indefinite-length elements.
+	{"SEQUENCE indefinite { INTEGER { 1 } }", []byte{0x30, 0x80, 0x02, 0x01, 0x01, 0x00, 0x00}, true},
+	{"[OCTET_STRING CONSTRUCTED] indefinite { OCTET_STRING { `aa` } [0] indefinite {} }", []byte{0x24, 0x80, 0x04, 0x01, 0xaa, 0xa0, 0x80, 0x00, 0x00, 0x00, 0x00}, true},
+	{"indefinite {}", nil, false},
+	{"OCTET_STRING indefinite {}", nil, false},
+	{"SEQUENCE `aa` indefinite {}", nil, false},
+	{"SEQUENCE indefinite", nil, false},
+	{"SEQUENCE indefinite INTEGER", nil, false},
+	{"SEQUENCE indefinite {", nil, false},
+	// Long-form lengths.
+	{"OCTET_STRING long-form(1) { `aabbcc` }", []byte{0x04, 0x81, 0x03, 0xaa, 0xbb, 0xcc}, true},
+	{"OCTET_STRING long-form(2) { `aabbcc` }", []byte{0x04, 0x82, 0x00, 0x03, 0xaa, 0xbb, 0xcc}, true},
+	{"SEQUENCE long-form(1) { long-form(3) {} }", []byte{0x30, 0x81, 0x04, 0x83, 0x00, 0x00, 0x00}, true},
+	{"OCTET_STRING long-form(1) { `" + strings.Repeat("aa", 256) + "` }", nil, false},
+	{"OCTET_STRING long-form(1) `aa`", nil, false},
+	// The constructed bit may be given before the tag.
+	{"[PRIMITIVE APPLICATION 5] {}", []byte{0x45, 0x00}, true},
+	{"[CONSTRUCTED APPLICATION 5] {}", []byte{0x65, 0x00}, true},
+	{"[PRIMITIVE 0] {}", []byte{0x80, 0x00}, true},
+	// SET OF sorting.
+	{"SET set-of { INTEGER { 3 } INTEGER { 1 } INTEGER { 2 } }", []byte{0x31, 0x09, 0x02, 0x01, 0x01, 0x02, 0x01, 0x02, 0x02, 0x01, 0x03}, true},
+	{"SET set-of { INTEGER { 256 } INTEGER { 1 } OCTET_STRING {} }", []byte{0x31, 0x09, 0x02, 0x01, 0x01, 0x02, 0x02, 0x01, 0x00, 0x04, 0x00}, true},
+	{"SET set-of { `0100` `010100` `0100` }", []byte{0x31, 0x07, 0x01, 0x00, 0x01, 0x00, 0x01, 0x01, 0x00}, true},
+	// Only direct children are sorted.
+	{"SET set-of { SEQUENCE { 2 1 } SEQUENCE { 1 2 } }", []byte{0x31, 0x08, 0x30, 0x02, 0x01, 0x02, 0x30, 0x02, 0x02, 0x01}, true},
+	{"SET set-of {}", []byte{0x31, 0x00}, true},
+	{"SET set-of { `01` }", nil, false},
+	{"SET set-of { SEQUENCE indefinite {} }", nil, false},
+	{"SET set-of 1", nil, false},
+	{"ENUMERATED { 5 } ENUMERATED { -129 }", []byte{0x0a, 0x01, 0x05, 0x0a, 0x02, 0xff, 0x7f}, true},
+	// Concatenation.
+	{"concat(\"hdr\" `00ff` \"ftr\")", []byte{'h', 'd', 'r', 0x00, 0xff, 'f', 't', 'r'}, true},
+	{"SEQUENCE { concat(INTEGER { 1 } concat() concat(`05` { })) }", []byte{0x30, 0x05, 0x02, 0x01, 0x01, 0x05, 0x00}, true},
+	{"define a { `01` } concat(use a use a)", []byte{0x01, 0x01}, true},
+	{"concat(SEQUENCE indefinite { })", []byte{0x30, 0x80, 0x00, 0x00}, true},
+	{"concat(})", nil, false},
+	{"concat({)", nil, false},
+	{"concat(define a { 1 })", nil, false},
+	{"concat(BOGUS)", nil, false},
+	{"concat(", nil, false},
+	// Implicit tagging.
+	{"implicit [0] { OCTET_STRING { \"x\" } }", []byte{0x80, 0x01, 'x'}, true},
+	{"implicit [APPLICATION 1] { SEQUENCE { NULL } }", []byte{0x61, 0x02, 0x05, 0x00}, true},
+	{"implicit [PRIVATE 31] { INTEGER { 1 } }", []byte{0xdf, 0x1f, 0x01, 0x01}, true},
+	{"implicit [0] { [31] { 1 } }", []byte{0xa0, 0x01, 0x01}, true},
+	{"implicit INTEGER { [0 PRIMITIVE] { 1 } }", []byte{0x02, 0x01, 0x01}, true},
+	{"implicit [0] {}", nil, false},
+	{"implicit [0] { NULL NULL }", nil, false},
+	{"implicit [0] { SEQUENCE indefinite {} }", nil, false},
+	{"implicit [0] { `30` }", nil, false},
+	{"implicit 1 { NULL }", nil, false},
+	{"implicit [0] NULL", nil, false},
+	// hex takes a list of bytes.
+	{"hex()", []byte{}, true},
+	{"hex(30 82 01 0a)", []byte{0x30, 0x82, 0x01, 0x0a}, true},
+	{"hex( 0x30 0x03\n  02 # INTEGER\n  01 /* length */ ff )", []byte{0x30, 0x03, 0x02, 0x01, 0xff}, true},
+	{"SEQUENCE { hex(05 00) }", []byte{0x30, 0x02, 0x05, 0x00}, true},
+	{"hex(3082)", nil, false},
+	{"hex(3)", nil, false},
+	{"hex(0x)", nil, false},
+	{"hex(zz)", nil, false},
+	{"hex(30, 82)", nil, false},
+	// raw blocks splice in their contents with no length prefix.
+	{"raw {}", []byte{}, true},
+	{"raw { INTEGER { 1 } NULL }", []byte{0x02, 0x01, 0x01, 0x05, 0x00}, true},
+	{"SEQUENCE { raw { `01` raw { `02` } } `03` }", []byte{0x30, 0x03, 0x01, 0x02, 0x03}, true},
+	{"OCTET_STRING long-form(2) { raw { \"a\" \"b\" } }", []byte{0x04, 0x82, 0x00, 0x02, 'a', 'b'}, true},
+	{"raw", nil, false},
+	{"raw NULL", nil, false},
+	{"raw { define x { `00` } }", nil, false},
+	// Explicit tagging.
+	{"explicit [0] { INTEGER { 1 } }", []byte{0xa0, 0x03, 0x02, 0x01, 0x01}, true},
+	{"explicit [APPLICATION 2] { SEQUENCE {} }", []byte{0x62, 0x02, 0x30, 0x00}, true},
+	{"explicit [0] { SEQUENCE { NULL NULL } }", []byte{0xa0, 0x06, 0x30, 0x04, 0x05, 0x00, 0x05, 0x00}, true},
+	{"SEQUENCE { explicit [1] { `0400` } }", []byte{0x30, 0x04, 0xa1, 0x02, 0x04, 0x00}, true},
+	{"explicit [0] {}", nil, false},
+	{"explicit [0] { NULL NULL }", nil, false},
+	{"explicit [0] { INTEGER }", nil, false},
+	{"explicit [0] { SEQUENCE indefinite {} }", nil, false},
+	{"explicit [0] { `0201` }", nil, false},
+	{"explicit [0 PRIMITIVE] { NULL }", nil, false},
+	{"explicit 1 { NULL }", nil, false},
+	{"explicit [0] NULL", nil, false},
+	// Explicit unused bit counts.
+	{"BIT_STRING { bits-unused(0) { `30 00` } }", []byte{0x03, 0x03, 0x00, 0x30, 0x00}, true},
+	{"BIT_STRING { bits-unused(3) { `ff f8` } }", []byte{0x03, 0x03, 0x03, 0xff, 0xf8}, true},
+	{"BIT_STRING { bits-unused(0) {} }", []byte{0x03, 0x01, 0x00}, true},
+	{"BIT_STRING { bits-unused(1) {} }", nil, false},
+	{"BIT_STRING { bits-unused(3) { `ff fc` } }", nil, false},
+	{"BIT_STRING { bits-unused(3) `ff` }", nil, false},
+	// Repeated blocks.
+	{"SEQUENCE { repeat(3) { INTEGER { 1 } } }", []byte{0x30, 0x09, 0x02, 0x01, 0x01, 0x02, 0x01, 0x01, 0x02, 0x01, 0x01}, true},
+	{"SEQUENCE { repeat(0) { INTEGER { 1 } } }", []byte{0x30, 0x00}, true},
+	{"repeat(2) { repeat(2) { 1 } }", []byte{0x01, 0x01, 0x01, 0x01}, true},
+	{"repeat(0x7fffffffffffffff) {}", []byte{}, true},
+	{"repeat(0x7fffffffffffffff) { 1 }", nil, false},
+	{"repeat(2) 1", nil, false},
+	// Macros.
+	{"define alg { SEQUENCE { OBJECT_IDENTIFIER { 1.2.3 } NULL } } SEQUENCE { use alg use alg }", []byte{0x30, 0x10, 0x30, 0x06, 0x06, 0x02, 0x2a, 0x03, 0x05, 0x00, 0x30, 0x06, 0x06, 0x02, 0x2a, 0x03, 0x05, 0x00}, true},
+	{"define a { 1 } define b { use a 2 } SEQUENCE { SET { use b } }", []byte{0x30, 0x04, 0x31, 0x02, 0x01, 0x02}, true},
+	{"define empty {} use empty", []byte{}, true},
+	{"define a { 1 } define a { 2 }", nil, false},
+	{"use a define a { 1 }", nil, false},
+	{"define a { define b {} }", nil, false},
+	{"SEQUENCE { define a {} }", nil, false},
+	{"define a 1", nil, false},
+	{"define a { use a }", nil, false},
 	// Mismatched curlies.
 	{"{", nil, false},
 	{"}", nil, false},
 	// Invalid token.
 	{"BOGUS", nil, false},
+	// uint omits the leading zero which signed INTEGER encodings need at
+	// the 0x80 boundary.
+	{"INTEGER { 127 } INTEGER { uint(127) }", []byte{0x02, 0x01, 0x7f, 0x02, 0x01, 0x7f}, true},
+	{"INTEGER { 128 } INTEGER { uint(128) }", []byte{0x02, 0x02, 0x00, 0x80, 0x02, 0x01, 0x80}, true},
+	{"INTEGER { 200 } INTEGER { uint(200) }", []byte{0x02, 0x02, 0x00, 0xc8, 0x02, 0x01, 0xc8}, true},
+	{"INTEGER { 255 } INTEGER { uint(255) }", []byte{0x02, 0x02, 0x00, 0xff, 0x02, 0x01, 0xff}, true},
+	{"INTEGER { 256 } INTEGER { uint(256) }", []byte{0x02, 0x02, 0x01, 0x00, 0x02, 0x02, 0x01, 0x00}, true},
+	{"uint(0)", []byte{0x00}, true},
+	{"uint(0xffffffffffffffff)", []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, true},
+	{"uint(0b10000000)", []byte{0x80}, true},
+	{"uint(1_000)", []byte{0x03, 0xe8}, true},
+	// eoc emits end-of-contents markers, balanced or not.
+	{"eoc", []byte{0x00, 0x00}, true},
+	{"SEQUENCE `80` INTEGER { 1 } eoc", []byte{0x30, 0x80, 0x02, 0x01, 0x01, 0x00, 0x00}, true},
+	{"SEQUENCE indefinite { eoc }", []byte{0x30, 0x80, 0x00, 0x00, 0x00, 0x00}, true},
+	{"SEQUENCE { repeat(2) { eoc } }", []byte{0x30, 0x04, 0x00, 0x00, 0x00, 0x00}, true},
+	{"eoc(1)", nil, false},
 }
 
 func TestASCIIToDER(t *testing.T) {
 	for i, tt := range asciiToDERTests {
-		out, err := asciiToDER(tt.in)
+		out, err := Convert(tt.in)
 		ok := err == nil
 		if !tt.ok {
 			if ok {
-				t.Errorf("%d. asciiToDER(%v) unexpectedly succeeded.", i, tt.in)
+				t.Errorf("%d. Convert(%v) unexpectedly succeeded.", i, tt.in)
 			}
 		} else {
 			if !ok {
-				t.Errorf("%d. asciiToDER(%v) unexpectedly failed.", i, tt.in)
+				t.Errorf("%d. Convert(%v) unexpectedly failed.", i, tt.in)
 			} else if !bytes.Equal(out, tt.out) {
-				t.Errorf("%d. asciiToDER(%v) = %x wanted %x.", i, tt.in, out, tt.out)
+				t.Errorf("%d. Convert(%v) = %x wanted %x.", i, tt.in, out, tt.out)
+			}
+		}
+	}
+}
+
+func nestedSequences(depth int) string {
+	return strings.Repeat("SEQUENCE {", depth) + strings.Repeat("}", depth)
+}
+
+var maxDepthTests = []struct {
+	in       string
+	maxDepth int
+	ok       bool
+}{
+	{nestedSequences(DefaultMaxDepth), 0, true},
+	{nestedSequences(DefaultMaxDepth + 1), 0, false},
+	{nestedSequences(2), 2, true},
+	{nestedSequences(3), 2, false},
+	{"SEQUENCE { SEQUENCE indefinite { 1 } }", 2, true},
+	{"SEQUENCE { SEQUENCE indefinite { {} } }", 2, false},
+	{"SEQUENCE { OCTET_STRING long-form(1) { 1 } }", 2, true},
+	{"SEQUENCE { OCTET_STRING long-form(1) { {} } }", 2, false},
+}
+
+func TestImplicit(t *testing.T) {
+	// IMPLICIT tagging replaces the tag, while EXPLICIT tagging wraps the
+	// element.
+	implicit, err := Convert("implicit [1] { SEQUENCE { INTEGER { 1 } } }")
+	if err != nil {
+		t.Fatalf("Convert failed: %s.", err)
+	}
+	explicit, err := Convert("[1] { SEQUENCE { INTEGER { 1 } } }")
+	if err != nil {
+		t.Fatalf("Convert failed: %s.", err)
+	}
+	if want := []byte{0xa1, 0x03, 0x02, 0x01, 0x01}; !bytes.Equal(implicit, want) {
+		t.Errorf("Implicit tagging gave %x, wanted %x.", implicit, want)
+	}
+	if want := []byte{0xa1, 0x05, 0x30, 0x03, 0x02, 0x01, 0x01}; !bytes.Equal(explicit, want) {
+		t.Errorf("Explicit tagging gave %x, wanted %x.", explicit, want)
+	}
+	if !bytes.Equal(implicit[2:], explicit[4:]) {
+		t.Errorf("Implicit tagging changed the contents: %x vs %x.", implicit, explicit)
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	for i, tt := range maxDepthTests {
+		_, err := Options{MaxDepth: tt.maxDepth}.Convert(tt.in)
+		if tt.ok && err != nil {
+			t.Errorf("%d. Convert with MaxDepth %d unexpectedly failed: %s.", i, tt.maxDepth, err)
+		} else if !tt.ok && err == nil {
+			t.Errorf("%d. Convert with MaxDepth %d unexpectedly succeeded.", i, tt.maxDepth)
+		}
+	}
+}
+
+func TestMaxRepeatSize(t *testing.T) {
+	opts := Options{MaxRepeatSize: 6}
+	if _, err := opts.Convert("repeat(2) { INTEGER { 1 } }"); err != nil {
+		t.Errorf("Convert failed: %s.", err)
+	}
+	if _, err := opts.Convert("repeat(3) { INTEGER { 1 } }"); err == nil {
+		t.Errorf("Convert unexpectedly succeeded.")
+	}
+}
+
+var maxLengthTests = []struct {
+	in  string
+	err string
+}{
+	{"OCTET_STRING { `0102` }", ""},
+	{"SEQUENCE { NULL {} }", ""},
+	{"OCTET_STRING { `010203` }", "line 1 column 14: length 3 exceeds maximum of 2"},
+	{"SEQUENCE {\n  INTEGER { 1 }\n}", "line 1 column 10: length 3 exceeds maximum of 2"},
+	{"SEQUENCE {\n  repeat(3) { `00` }\n}", "line 1 column 10: length 3 exceeds maximum of 2"},
+	// Lengths are reported at the left curly brace.
+	{"OCTET_STRING long-form(1) { `010203` }", "line 1 column 27: length 3 exceeds maximum of 2"},
+	{"SET set-of { `0100` `0100` }", "line 1 column 12: length 4 exceeds maximum of 2"},
+	{"OCTET_STRING expect-len(3) { `010203` }", "line 1 column 28: length 3 exceeds maximum of 2"},
+	{"OCTET_STRING truncate(3) { `010203` }", "line 1 column 26: length 3 exceeds maximum of 2"},
+	{"explicit [0] { OCTET_STRING { `01` } }", "line 1 column 14: length 3 exceeds maximum of 2"},
+	// Indefinite-length elements have no length.
+	{"SEQUENCE indefinite { `010203` }", ""},
+}
+
+func TestMaxLength(t *testing.T) {
+	opts := Options{MaxLength: 2}
+	for i, tt := range maxLengthTests {
+		_, err := opts.Convert(tt.in)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%d. Convert(%q) failed: %s.", i, tt.in, err)
+			}
+		} else if err == nil || err.Error() != tt.err {
+			t.Errorf("%d. Convert(%q) returned %v, wanted %q.", i, tt.in, err, tt.err)
+		}
+	}
+
+	// By default, lengths are unlimited.
+	if _, err := Convert("OCTET_STRING { repeat(100000) { `00` } }"); err != nil {
+		t.Errorf("Convert failed: %s.", err)
+	}
+}
+
+func TestAllowNonzeroPadding(t *testing.T) {
+	const in = "BIT_STRING { bits-unused(3) { `ff fc` } }"
+	if _, err := Convert(in); err == nil {
+		t.Errorf("Convert unexpectedly succeeded.")
+	}
+	out, err := Options{AllowNonzeroPadding: true}.Convert(in)
+	if want := []byte{0x03, 0x03, 0x03, 0xff, 0xfc}; err != nil || !bytes.Equal(out, want) {
+		t.Errorf("Convert = %x, %v, wanted %x.", out, err, want)
+	}
+}
+
+var macroErrorTests = []struct {
+	in  string
+	err string
+}{
+	{"define a { 1 }\ndefine a { 2 }", "line 2 column 1: macro 'a' already defined at line 1 column 1"},
+	{"SEQUENCE {\n  use b\n}", "line 2 column 3: undefined macro 'b'"},
+	{"define\n", "line 2 column 1: expected macro name after 'define'"},
+	{"concat(\n  define a { 1 })", "line 2 column 3: define must be at the top level"},
+}
+
+func TestMacroErrors(t *testing.T) {
+	for i, tt := range macroErrorTests {
+		_, err := Convert(tt.in)
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("%d. Convert(%q) failed with %v, wanted %q.", i, tt.in, err, tt.err)
+		}
+	}
+}
+
+func TestExpectLen(t *testing.T) {
+	_, err := Convert("SEQUENCE {\n  OCTET_STRING expect-len(4) { \"abc\" }\n}")
+	if want := "line 2 column 16: contents are 3 bytes, but expected 4"; err == nil || err.Error() != want {
+		t.Errorf("Convert failed with %v, wanted %q.", err, want)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	out, err := Convert("OCTET_STRING truncate(100) { \"abc\" }")
+	if err != nil {
+		t.Fatalf("Convert failed: %s", err)
+	}
+	// The declared length exceeds the contents actually emitted.
+	if declared, actual := int(out[1]), len(out)-2; declared != 100 || actual != 3 {
+		t.Errorf("Convert = %x, declaring %d bytes with %d present, wanted 100 and 3.", out, declared, actual)
+	}
+	if err := CheckDER(out); err == nil {
+		t.Errorf("CheckDER(%x) unexpectedly succeeded.", out)
+	}
+}
+
+func TestLoose(t *testing.T) {
+	in := "SEQUENCE { hello INTEGER { 1 } SEQUNECE {} }"
+	if _, err := Convert(in); err == nil || err.Error() != "line 1 column 12: unrecognized symbol 'hello'" {
+		t.Errorf("Convert(%q) failed with %v, wanted an unrecognized symbol error.", in, err)
+	}
+	opts := Options{Loose: true}
+	want := append([]byte{0x30, 0x11}, "hello"...)
+	want = append(want, 0x02, 0x01, 0x01)
+	want = append(want, "SEQUNECE"...)
+	want = append(want, 0x00)
+	if out, err := opts.Convert(in); err != nil || !bytes.Equal(out, want) {
+		t.Errorf("Convert(%q) with Loose = %x, %v, wanted %x.", in, out, err, want)
+	}
+	// Other errors are still reported.
+	for _, in := range []string{"[BOGUS]", "1__0", "1.-2", "bogus()"} {
+		if _, err := opts.Convert(in); err == nil {
+			t.Errorf("Convert(%q) with Loose unexpectedly succeeded.", in)
+		}
+	}
+}
+
+func TestPredefinedMacros(t *testing.T) {
+	opts := Options{Macros: map[string][]byte{"serial": {0x02, 0x01, 0x05}, "empty": {}}}
+	out, err := opts.Convert("SEQUENCE { use serial use empty }")
+	if want := []byte{0x30, 0x03, 0x02, 0x01, 0x05}; err != nil || !bytes.Equal(out, want) {
+		t.Errorf("Convert = %x, %v, wanted %x.", out, err, want)
+	}
+	if _, err := opts.Convert("\ndefine serial { 1 }"); err == nil || err.Error() != "line 2 column 1: macro 'serial' already defined outside the input" {
+		t.Errorf("Convert failed with %v, wanted a redefinition error.", err)
+	}
+	opts.Macros["bad name"] = nil
+	if _, err := opts.Convert("use serial"); err == nil || err.Error() != "invalid macro name 'bad name'" {
+		t.Errorf("Convert failed with %v, wanted an invalid name error.", err)
+	}
+}
+
+var leadingZerosTests = []struct {
+	in     string
+	strict string
+	out    []byte
+}{
+	{"0 -0 0x0", "", []byte{0x00, 0x00, 0x00}},
+	{"007", "line 1 column 1: integer '007' has a leading zero", []byte{0x07}},
+	{"-0_1", "line 1 column 1: integer '-0_1' has a leading zero", []byte{0xff}},
+	{"0.0", "", []byte{0x00}},
+	{"1.2.0840", "line 1 column 1: OID arc '0840' has a leading zero", []byte{0x2a, 0x86, 0x48}},
+	{"relative-oid(01.0)", "line 1 column 14: OID arc '01' has a leading zero", []byte{0x01, 0x00}},
+	// Leading zeros do not mean octal.
+	{"byte(010)", "line 1 column 6: integer '010' has a leading zero", []byte{0x0a}},
+	{"int-width(1, 010)", "line 1 column 14: integer '010' has a leading zero", []byte{0x0a}},
+	{"uint(010)", "line 1 column 6: integer '010' has a leading zero", []byte{0x0a}},
+}
+
+func TestLeadingZeros(t *testing.T) {
+	for _, tt := range leadingZerosTests {
+		_, err := Convert(tt.in)
+		if tt.strict == "" {
+			if err != nil {
+				t.Errorf("Convert(%q) failed: %s", tt.in, err)
+			}
+		} else if err == nil || err.Error() != tt.strict {
+			t.Errorf("Convert(%q) failed with %v, wanted %q.", tt.in, err, tt.strict)
+		}
+
+		opts := Options{AllowLeadingZeros: true}
+		out, err := opts.Convert(tt.in)
+		if err != nil || !bytes.Equal(out, tt.out) {
+			t.Errorf("Convert(%q) with AllowLeadingZeros = %x, %v, wanted %x.", tt.in, out, err, tt.out)
+		}
+	}
+}
+
+var stringCharsetsTests = []struct {
+	in  string
+	err string
+	out []byte
+}{
+	{`NumericString { "123 456" }`, "", []byte{0x12, 0x07, 0x31, 0x32, 0x33, 0x20, 0x34, 0x35, 0x36}},
+	{`NumericString { "12a" }`, "line 1 column 20: invalid character 'a' in NumericString", []byte{0x12, 0x03, 0x31, 0x32, 0x61}},
+	{`NumericString { "1\n" }`, `line 1 column 19: invalid character '\n' in NumericString`, []byte{0x12, 0x02, 0x31, 0x0a}},
+	{`NumericString { "\x41" }`, "line 1 column 18: invalid character 'A' in NumericString", []byte{0x12, 0x01, 0x41}},
+	{`NumericString { "1\u00e9" }`, "line 1 column 19: invalid character 'é' in NumericString", []byte{0x12, 0x03, 0x31, 0xc3, 0xa9}},
+	{`NumericString { "1é" }`, "line 1 column 19: invalid character 'é' in NumericString", []byte{0x12, 0x03, 0x31, 0xc3, 0xa9}},
+	{`NumericString { concat("1" "x") }`, "line 1 column 29: invalid character 'x' in NumericString", []byte{0x12, 0x02, 0x31, 0x78}},
+	{`NumericString long-form(2) { "x" }`, "line 1 column 31: invalid character 'x' in NumericString", []byte{0x12, 0x82, 0x00, 0x01, 0x78}},
+	// Hex literals, nested elements, and other types are not checked.
+	{"NumericString { `78` }", "", []byte{0x12, 0x01, 0x78}},
+	{`NumericString { OCTET_STRING { "x" } "1" }`, "", []byte{0x12, 0x04, 0x04, 0x01, 0x78, 0x31}},
+	{`[NumericString CONSTRUCTED] { "x" }`, "", []byte{0x32, 0x01, 0x78}},
+	{`PrintableString { "Example Co., Ltd. (A-Z) 'x'+y/z:=?" }`, "", append([]byte{0x13, 0x22}, "Example Co., Ltd. (A-Z) 'x'+y/z:=?"...)},
+	{`PrintableString { "a@b" }`, "line 1 column 21: invalid character '@' in PrintableString", []byte{0x13, 0x03, 0x61, 0x40, 0x62}},
+	{`PrintableString { raw { "a@b" } }`, "line 1 column 27: invalid character '@' in PrintableString", []byte{0x13, 0x03, 0x61, 0x40, 0x62}},
+	{`PrintableString { "a_b" "" "*" }`, "line 1 column 21: invalid character '_' in PrintableString", []byte{0x13, 0x04, 0x61, 0x5f, 0x62, 0x2a}},
+	{`IA5String { "a@b_" }`, "", []byte{0x16, 0x04, 0x61, 0x40, 0x62, 0x5f}},
+}
+
+func TestStringCharsets(t *testing.T) {
+	for _, tt := range stringCharsetsTests {
+		out, err := Convert(tt.in)
+		if tt.err == "" {
+			if err != nil || !bytes.Equal(out, tt.out) {
+				t.Errorf("Convert(%q) = %x, %v, wanted %x.", tt.in, out, err, tt.out)
+			}
+		} else if err == nil || err.Error() != tt.err {
+			t.Errorf("Convert(%q) failed with %v, wanted %q.", tt.in, err, tt.err)
+		}
+
+		opts := Options{AllowInvalidStrings: true}
+		out, err = opts.Convert(tt.in)
+		if err != nil || !bytes.Equal(out, tt.out) {
+			t.Errorf("Convert(%q) with AllowInvalidStrings = %x, %v, wanted %x.", tt.in, out, err, tt.out)
+		}
+	}
+}
+
+var strictTagsTests = []struct {
+	in           string
+	line, column int
+}{
+	{"SEQUENCE { INTEGER { 1 } [0] {} }", 0, 0},
+	{"SEQUENCE indefinite { SET set-of {} }", 0, 0},
+	{"OCTET_STRING long-form(2) { INTEGER expect-len(1) { 1 } BIT_STRING truncate(2) {} }", 0, 0},
+	{"implicit [0] { NULL {} } explicit [1] { NULL {} }", 0, 0},
+	{"`3000` byte(0x30) byte(0)", 0, 0},
+	{`INTEGER "x"`, 1, 1},
+	{"SEQUENCE {\n  OCTET_STRING\n}", 2, 3},
+	// NULL alone is a complete element.
+	{"SEQUENCE { NULL }", 0, 0},
+	{"SEQUENCE { INTEGER }", 1, 12},
+	{"[0]", 1, 1},
+	{"SEQUENCE SEQUENCE {}", 1, 1},
+	{"UTF8String `00`", 1, 1},
+}
+
+func TestStrictTags(t *testing.T) {
+	for _, tt := range strictTagsTests {
+		if _, err := Convert(tt.in); err != nil {
+			t.Errorf("Convert(%q) failed: %s", tt.in, err)
+		}
+		_, err := Options{StrictTags: true}.Convert(tt.in)
+		if tt.line == 0 {
+			if err != nil {
+				t.Errorf("Convert(%q) with StrictTags failed: %s", tt.in, err)
+			}
+			continue
+		}
+		if pe, ok := err.(*ParseError); !ok || pe.Pos.Line != tt.line || pe.Pos.Column != tt.column {
+			t.Errorf("Convert(%q) with StrictTags gave error %v, wanted one at line %d column %d.", tt.in, err, tt.line, tt.column)
+		}
+	}
+}
+
+var warningsTests = []struct {
+	in       string
+	warnings []string
+}{
+	{`PrintableString { "x" }`, nil},
+	{`PrintableString { u16"x" }`, []string{"line 1 column 19: u16 string in PrintableString, which should not be prefixed"}},
+	{`UTF8String { u32"x" }`, []string{"line 1 column 14: u32 string in UTF8String, which should not be prefixed"}},
+	{`BMPString { u16"x" }`, nil},
+	{`BMPString { "x" u32"y" }`, []string{
+		`line 1 column 13: unprefixed string in BMPString, which should use u16"..."`,
+		`line 1 column 17: u32 string in BMPString, which should use u16"..."`,
+	}},
+	{`UniversalString long-form(1) { u16"x" }`, []string{`line 1 column 32: u16 string in UniversalString, which should use u32"..."`}},
+	{`IA5String { raw { u16"x" } }`, []string{"line 1 column 19: u16 string in IA5String, which should not be prefixed"}},
+	{`IA5String { concat(u16"x") }`, []string{"line 1 column 20: u16 string in IA5String, which should not be prefixed"}},
+	// Only strings directly within a string type are checked.
+	{`OCTET_STRING { u16"x" }`, nil},
+	{`BMPString { OCTET_STRING { "x" } }`, nil},
+	{`[BMPString CONSTRUCTED] { "x" }`, nil},
+	{`u16"x"`, nil},
+}
+
+func TestWarnings(t *testing.T) {
+	for _, tt := range warningsTests {
+		var warnings []string
+		opts := Options{Warn: func(err *ParseError) { warnings = append(warnings, err.Error()) }}
+		out, err := opts.Convert(tt.in)
+		if err != nil {
+			t.Errorf("Convert(%q) failed: %s", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(warnings, tt.warnings) {
+			t.Errorf("Convert(%q) warned %q, wanted %q.", tt.in, warnings, tt.warnings)
+		}
+		// Warnings do not change the output.
+		if want, err := Convert(tt.in); err != nil || !bytes.Equal(out, want) {
+			t.Errorf("Convert(%q) with Warn = %x, wanted %x.", tt.in, out, want)
+		}
+	}
+}
+
+var lintTests = []struct {
+	in   string
+	errs []string
+}{
+	{"SEQUENCE { INTEGER { 1 } }", nil},
+	{"INTEGER { 1 } BOGUS", []string{"line 1 column 15: unrecognized symbol 'BOGUS'"}},
+	{"SEQUENCE { `zz` INTEGER { 1 } }\nOCTET_STRING { BOGUS }", []string{
+		"line 1 column 13: invalid hex digit 'z'",
+		"line 2 column 16: unrecognized symbol 'BOGUS'",
+	}},
+	// Errors within quoted strings resume after the closing quote.
+	{`SEQUENCE { UTF8String { "bad \q escape" } byte(256) }`, []string{
+		`line 1 column 30: unknown escape sequence \q`,
+		"line 1 column 51: byte value must be between 0 and 255",
+	}},
+	// Errors after a token is complete continue with the next token.
+	{"SEQUENCE { INTEGER indefinite { } } } INTEGER { 1.2.3 }", []string{
+		"line 1 column 20: indefinite length requires a constructed tag",
+		"line 1 column 37: unmatched '}'",
+	}},
+	{"SEQUENCE { INTEGER expect-len(2) { 1 } BOGUS }", []string{
+		"line 1 column 20: contents are 1 bytes, but expected 2",
+		"line 1 column 40: unrecognized symbol 'BOGUS'",
+	}},
+	{"concat(BOGUS 1 BOGUS) 2", []string{
+		"line 1 column 8: unrecognized symbol 'BOGUS'",
+		"line 1 column 16: unrecognized symbol 'BOGUS'",
+	}},
+	// Blocks nested too deeply are skipped.
+	{nestedSequences(DefaultMaxDepth+3) + " BOGUS", []string{
+		"line 1 column 10010: nesting too deep, exceeding maximum depth of 1000",
+		"line 1 column 11035: unrecognized symbol 'BOGUS'",
+	}},
+	// Errors at EOF end linting.
+	{"SEQUENCE { BOGUS", []string{"line 1 column 12: unrecognized symbol 'BOGUS'"}},
+	{"SEQUENCE {", []string{"line 1 column 10: unmatched '{'"}},
+}
+
+func TestLint(t *testing.T) {
+	for _, tt := range lintTests {
+		errs, err := Options{}.Lint(tt.in)
+		if err != nil {
+			t.Errorf("Lint(%q) failed: %s", tt.in, err)
+			continue
+		}
+		var got []string
+		for _, err := range errs {
+			got = append(got, err.Error())
+		}
+		if !reflect.DeepEqual(got, tt.errs) {
+			t.Errorf("Lint(%q) = %q, wanted %q.", tt.in, got, tt.errs)
+		}
+		// Convert reports the first error.
+		_, err = Convert(tt.in)
+		if len(tt.errs) == 0 {
+			if err != nil {
+				t.Errorf("Convert(%q) failed: %s", tt.in, err)
+			}
+		} else if err == nil || err.Error() != tt.errs[0] {
+			t.Errorf("Convert(%q) gave error %v, wanted %q.", tt.in, err, tt.errs[0])
+		}
+	}
+
+	if _, err := (Options{Macros: map[string][]byte{"bad name": nil}}).Lint(""); err == nil {
+		t.Errorf("Lint with an invalid macro name unexpectedly succeeded.")
+	}
+}
+
+var ignoreTagCaseTests = []struct {
+	in       string
+	out      []byte
+	warnings []string
+}{
+	{"SEQUENCE { INTEGER { 1 } }", []byte{0x30, 0x03, 0x02, 0x01, 0x01}, nil},
+	{"sequence { Integer { 1 } }", []byte{0x30, 0x03, 0x02, 0x01, 0x01}, []string{
+		"line 1 column 1: tag name 'sequence' should be spelled 'SEQUENCE'",
+		"line 1 column 12: tag name 'Integer' should be spelled 'INTEGER'",
+	}},
+	// A bare null is a complete NULL, as with NULL.
+	{"sequence { null }", []byte{0x30, 0x02, 0x05, 0x00}, []string{
+		"line 1 column 1: tag name 'sequence' should be spelled 'SEQUENCE'",
+		"line 1 column 12: tag name 'null' should be spelled 'NULL'",
+	}},
+	{"getrequest {}", []byte{0xa0, 0x00}, []string{"line 1 column 1: tag name 'getrequest' should be spelled 'GetRequest'"}},
+	// A name which matches both a built-in name and a name in the
+	// table is ambiguous.
+	{"boolean { TRUE }", nil, nil},
+	// Names in either which match exactly are unaffected.
+	{"BOOLEAN { TRUE } Boolean { TRUE }", []byte{0x01, 0x01, 0xff, 0x81, 0x01, 0xff}, nil},
+	// Other symbols take precedence.
+	{"true", nil, nil},
+	{"[sequence] {}", nil, nil},
+}
+
+func TestIgnoreTagCase(t *testing.T) {
+	var table lib.TagTable
+	if err := table.Add("GetRequest", lib.Tag{Class: lib.ClassContextSpecific, Number: 0, Constructed: true}); err != nil {
+		t.Fatalf("Add failed: %s.", err)
+	}
+	if err := table.Add("Boolean", lib.Tag{Class: lib.ClassContextSpecific, Number: 1, Constructed: false}); err != nil {
+		t.Fatalf("Add failed: %s.", err)
+	}
+	for _, tt := range ignoreTagCaseTests {
+		var warnings []string
+		opts := Options{TagTable: &table, IgnoreTagCase: true, Warn: func(err *ParseError) { warnings = append(warnings, err.Error()) }}
+		out, err := opts.Convert(tt.in)
+		if tt.out == nil {
+			if err == nil {
+				t.Errorf("Convert(%q) with IgnoreTagCase unexpectedly succeeded.", tt.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Convert(%q) with IgnoreTagCase failed: %s", tt.in, err)
+			continue
+		}
+		if !bytes.Equal(out, tt.out) {
+			t.Errorf("Convert(%q) with IgnoreTagCase = %x, wanted %x.", tt.in, out, tt.out)
+		}
+		if !reflect.DeepEqual(warnings, tt.warnings) {
+			t.Errorf("Convert(%q) with IgnoreTagCase warned %q, wanted %q.", tt.in, warnings, tt.warnings)
+		}
+		// By default, tag names are case-sensitive.
+		if _, err := (Options{TagTable: &table}).Convert(tt.in); (err == nil) != (tt.warnings == nil) {
+			t.Errorf("Convert(%q) returned %v, wanted an error only for non-canonical names.", tt.in, err)
+		}
+	}
+}
+
+func TestInclude(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := filepath.Join(t.TempDir(), "secret.txt")
+	if err := os.WriteFile(secret, []byte("TOPSECRET"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(secret, filepath.Join(dir, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"name.txt":        "SEQUENCE { include \"sub/cn.txt\" }",
+		"sub/cn.txt":      "define cn { OBJECT_IDENTIFIER { 2.5.4.3 } }\nSET { SEQUENCE { use cn include \"value.txt\" } }",
+		"sub/value.txt":   "UTF8String { \"x\" }",
+		"cycle1.txt":      "include \"cycle2.txt\"",
+		"cycle2.txt":      "SEQUENCE {\n  include \"cycle1.txt\"\n}",
+		"bad.txt":         "SEQUENCE {\n  BOGUS\n}",
+		"twice.txt":       "include \"sub/value.txt\" include \"sub/value.txt\"",
+		"sub/uses-cn.txt": "use cn",
+		"macros.txt":      "define cn { 1 } include \"sub/uses-cn.txt\"",
+		"sub/escape.txt":  "include \"../../secret.txt\"",
+		"warn.txt":        "SEQUENCE {\n  PrintableString { u16\"TOPSECRET\" }\n}",
+		"sub/warn.txt":    "SEQUENCE {\n  include \"../warn.txt\"\n}",
+	}
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		in  string
+		out []byte
+		err string
+	}{
+		{
+			in:  `include "name.txt"`,
+			out: []byte{0x30, 0x0c, 0x31, 0x0a, 0x30, 0x08, 0x06, 0x03, 0x55, 0x04, 0x03, 0x0c, 0x01, 'x'},
+		},
+		{
+			in:  `include "sub/../sub/value.txt"`,
+			out: []byte{0x0c, 0x01, 'x'},
+		},
+		// Includes may not leave the include directory.
+		{
+			in:  "include \"" + filepath.Join(dir, "sub", "value.txt") + "\"",
+			err: "line 1 column 1: include path \"" + filepath.Join(dir, "sub", "value.txt") + "\" must be relative",
+		},
+		{
+			in:  "include \"" + secret + "\"",
+			err: "line 1 column 1: include path \"" + secret + "\" must be relative",
+		},
+		{
+			in:  `include "../secret.txt"`,
+			err: `line 1 column 1: include path "../secret.txt" is outside the include directory`,
+		},
+		{
+			in:  `include "sub/escape.txt"`,
+			err: "line 1 column 1: in " + filepath.Join(dir, "sub", "escape.txt") + `: line 1 column 1: include path "../../secret.txt" is outside the include directory`,
+		},
+		{
+			in:  `include "link.txt"`,
+			err: `line 1 column 1: include path "link.txt" is a link outside the include directory`,
+		},
+		{
+			in:  `include "twice.txt"`,
+			out: []byte{0x0c, 0x01, 'x', 0x0c, 0x01, 'x'},
+		},
+		{
+			in:  "1\ninclude \"missing.txt\"",
+			err: "line 2 column 1: lstat " + filepath.Join(dir, "missing.txt") + ": no such file or directory",
+		},
+		{
+			in:  "include \"bad.txt\"",
+			err: "line 1 column 1: in " + filepath.Join(dir, "bad.txt") + ": line 2 column 3: syntax error",
+		},
+		{
+			in:  "include \"cycle1.txt\"",
+			err: "line 1 column 1: in " + filepath.Join(dir, "cycle1.txt") + ": line 1 column 1: in " + filepath.Join(dir, "cycle2.txt") + ": line 2 column 3: include cycle: " + filepath.Join(dir, "cycle1.txt") + " -> " + filepath.Join(dir, "cycle2.txt") + " -> " + filepath.Join(dir, "cycle1.txt"),
+		},
+		// Included files do not see the includer's macros.
+		{
+			in:  "include \"macros.txt\"",
+			err: "line 1 column 1: in " + filepath.Join(dir, "macros.txt") + ": line 1 column 17: in " + filepath.Join(dir, "sub", "uses-cn.txt") + ": line 1 column 1: syntax error",
+		},
+		{in: "include 1", err: "line 1 column 9: expected quoted path after 'include'"},
+	}
+	opts := Options{IncludeDir: dir}
+	for i, tt := range tests {
+		out, err := opts.Convert(tt.in)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d. Convert(%q) failed with %v, wanted %q.", i, tt.in, err, tt.err)
+			}
+			if err != nil && strings.Contains(err.Error(), "TOPSECRET") {
+				t.Errorf("%d. Convert(%q) revealed the included file: %s", i, tt.in, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d. Convert(%q) failed: %s.", i, tt.in, err)
+		} else if !bytes.Equal(out, tt.out) {
+			t.Errorf("%d. Convert(%q) = %x, wanted %x.", i, tt.in, out, tt.out)
+		}
+	}
+
+	// Warnings within the file are reported at the include.
+	warnTests := []struct {
+		in       string
+		warnings []string
+	}{
+		{`include "warn.txt"`, []string{"line 1 column 1: in " + filepath.Join(dir, "warn.txt") + ": line 2 column 21: likely mistake"}},
+		{"NULL\ninclude \"sub/warn.txt\"", []string{"line 2 column 1: in " + filepath.Join(dir, "sub", "warn.txt") + ": line 2 column 3: in " + filepath.Join(dir, "warn.txt") + ": line 2 column 21: likely mistake"}},
+	}
+	for i, tt := range warnTests {
+		var warnings []string
+		warnOpts := opts
+		warnOpts.Warn = func(err *ParseError) { warnings = append(warnings, err.Error()) }
+		if _, err := warnOpts.Convert(tt.in); err != nil {
+			t.Errorf("%d. Convert(%q) failed: %s.", i, tt.in, err)
+		}
+		if !reflect.DeepEqual(warnings, tt.warnings) {
+			t.Errorf("%d. Convert(%q) warned %q, wanted %q.", i, tt.in, warnings, tt.warnings)
+		}
+	}
+
+	// Without IncludeDir, include is disabled.
+	if _, err := Convert(`include "name.txt"`); err == nil || err.Error() != "line 1 column 1: include is not enabled" {
+		t.Errorf("Convert failed with %v, wanted include to be disabled.", err)
+	}
+}
+
+var sourceMapTests = []struct {
+	in   string
+	want []string
+}{
+	{"SEQUENCE {\n  INTEGER { 1 }\n}", []string{"0-1 1:1", "1-2 1:10", "2-3 2:3", "3-4 2:11", "4-5 2:13"}},
+	// set-of moves each element's mappings with it.
+	{"SET set-of { `020102` `020101` }", []string{"0-1 1:1", "1-2 1:5", "2-5 1:23", "5-8 1:14"}},
+	// implicit drops the mapping for the replaced tag.
+	{"implicit [0] { INTEGER { 5 } }", []string{"0-1 1:10", "1-2 1:24", "2-3 1:26"}},
+	{"explicit [0] { INTEGER { 5 } }", []string{"0-2 1:10", "2-3 1:16", "3-4 1:24", "4-5 1:26"}},
+	{"SEQUENCE indefinite { NULL {} }", []string{"0-1 1:1", "1-2 1:10", "2-3 1:23", "3-4 1:28", "4-6 1:10"}},
+	{"define x { `01` }\nrepeat(2) { use x }", []string{"0-2 2:1"}},
+	{"define x { `01` }\nuse x", []string{"0-1 2:1"}},
+	{"BIT_STRING { bits-unused(1) { `80` } }", []string{"0-1 1:1", "1-2 1:12", "2-3 1:14", "3-4 1:31"}},
+	{`OCTET_STRING { concat("ab" "c") }`, []string{"0-1 1:1", "1-2 1:14", "2-4 1:23", "4-5 1:28"}},
+	// Widening a length moves the mappings which follow it.
+	{"SEQUENCE { SEQUENCE { OCTET_STRING { `" + strings.Repeat("aa", 200) + "` } } }", []string{"0-1 1:1", "1-3 1:10", "3-4 1:12", "4-6 1:21", "6-7 1:23", "7-9 1:36", "9-209 1:38"}},
+}
+
+func TestSourceMap(t *testing.T) {
+	for i, tt := range sourceMapTests {
+		out, mappings, err := Options{}.ConvertWithSourceMap(tt.in)
+		if err != nil {
+			t.Errorf("%d. ConvertWithSourceMap(%q) failed: %s", i, tt.in, err)
+			continue
+		}
+		var got []string
+		next := 0
+		for _, m := range mappings {
+			if m.Start != next {
+				t.Errorf("%d. ConvertWithSourceMap(%q) mappings are not contiguous at %d.", i, tt.in, m.Start)
 			}
+			next = m.End
+			got = append(got, fmt.Sprintf("%d-%d %d:%d", m.Start, m.End, m.Pos.Line, m.Pos.Column))
+		}
+		if next != len(out) {
+			t.Errorf("%d. ConvertWithSourceMap(%q) mappings end at %d, but the output is %d bytes.", i, tt.in, next, len(out))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d. ConvertWithSourceMap(%q) mappings = %v, wanted %v.", i, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMaxDepthError(t *testing.T) {
+	_, err := Options{MaxDepth: 1}.Convert("SEQUENCE {\n  SEQUENCE {}\n}")
+	want := "line 2 column 12: nesting too deep, exceeding maximum depth of 1"
+	if err == nil || err.Error() != want {
+		t.Errorf("Convert failed with %v, wanted %q.", err, want)
+	}
+}
+
+// scanAllTokens returns all tokens from scanner, through EOF, or the first
+// error.
+func scanAllTokens(scanner *Scanner) ([]Token, error) {
+	var tokens []Token
+	for {
+		tok, err := scanner.Next()
+		if err != nil {
+			return tokens, err
+		}
+		tokens = append(tokens, tok)
+		if tok.Kind == TokenEOF {
+			return tokens, nil
+		}
+	}
+}
+
+func TestReaderScanner(t *testing.T) {
+	var inputs []string
+	for _, tt := range scannerTests {
+		inputs = append(inputs, tt.in)
+	}
+	for _, tt := range scannerErrorTests {
+		inputs = append(inputs, tt.in)
+	}
+	for _, tt := range asciiToDERTests {
+		inputs = append(inputs, tt.in)
+	}
+
+	for i, in := range inputs {
+		// Reading one byte at a time forces a refill at every
+		// possible point. The result should match the string scanner.
+		want, wantErr := scanAllTokens(NewScanner(in))
+		got, err := scanAllTokens(NewReaderScanner(iotest.OneByteReader(strings.NewReader(in))))
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%d. Reader scanner on %q gave %v, wanted %v.", i, in, got, want)
+		}
+		if !reflect.DeepEqual(err, wantErr) {
+			t.Errorf("%d. Reader scanner on %q gave error %v, wanted %v.", i, in, err, wantErr)
+		}
+	}
+}
+
+func TestReaderScannerBuffer(t *testing.T) {
+	// The scanner should only buffer input for the current token.
+	scanner := NewReaderScanner(strings.NewReader(strings.Repeat("1 # comment\n", 100000)))
+	for {
+		tok, err := scanner.Next()
+		if err != nil {
+			t.Fatalf("Next failed: %s.", err)
+		}
+		if len(scanner.text) > 8192 {
+			t.Fatalf("Scanner buffered %d bytes.", len(scanner.text))
+		}
+		if tok.Kind == TokenEOF {
+			break
+		}
+	}
+	if want := 100001; scanner.pos.Line != want {
+		t.Errorf("Scanner ended on line %d, wanted %d.", scanner.pos.Line, want)
+	}
+}
+
+func TestConvertReader(t *testing.T) {
+	for i, tt := range asciiToDERTests {
+		out, err := ConvertReader(iotest.HalfReader(strings.NewReader(tt.in)))
+		if ok := err == nil; ok != tt.ok {
+			t.Errorf("%d. ConvertReader(%v) gave error %v.", i, tt.in, err)
+		} else if ok && !bytes.Equal(out, tt.out) {
+			t.Errorf("%d. ConvertReader(%v) = %x wanted %x.", i, tt.in, out, tt.out)
+		}
+	}
+
+	// Read errors are returned as-is, even if the input so far is
+	// incomplete.
+	r := io.MultiReader(strings.NewReader("SEQUENCE { "), iotest.ErrReader(iotest.ErrTimeout))
+	if _, err := ConvertReader(r); err != iotest.ErrTimeout {
+		t.Errorf("ConvertReader gave error %v, wanted %v.", err, iotest.ErrTimeout)
+	}
+}
+
+// BenchmarkConvertSetOfSourceMap assembles a set-of with many elements, each of
+// whose mappings is moved when the elements are sorted.
+func BenchmarkConvertSetOfSourceMap(b *testing.B) {
+	in := "SET set-of {" + strings.Repeat("INTEGER { 2 } INTEGER { 1 } ", 8192) + "}"
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		out, _, err := Options{}.ConvertWithSourceMap(in)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.SetBytes(int64(len(out)))
+	}
+}
+
+// nestedInput returns DER ASCII for a tree of SEQUENCEs, fanout wide and depth
+// deep, with an OCTET STRING of leafLen bytes at each leaf.
+func nestedInput(fanout, depth, leafLen int) string {
+	leaf := "OCTET_STRING { `" + strings.Repeat("ab", leafLen) + "` }\n"
+	var b strings.Builder
+	var write func(depth int)
+	write = func(depth int) {
+		if depth == 0 {
+			b.WriteString(leaf)
+			return
+		}
+		b.WriteString("SEQUENCE {\n")
+		for i := 0; i < fanout; i++ {
+			write(depth - 1)
+		}
+		b.WriteString("}\n")
+	}
+	write(depth)
+	return b.String()
+}
+
+// BenchmarkConvertLarge assembles about 10 MB of nested output.
+func BenchmarkConvertLarge(b *testing.B) {
+	in := nestedInput(4, 8, 160)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		out, err := Convert(in)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.SetBytes(int64(len(out)))
+	}
+}
+
+// nestedDER returns the expected output for nestedInput.
+func nestedDER(fanout, depth, leafLen int) []byte {
+	if depth == 0 {
+		contents := bytes.Repeat([]byte{0xab}, leafLen)
+		return append(lib.AppendLength([]byte{0x04}, len(contents)), contents...)
+	}
+	child := nestedDER(fanout, depth-1, leafLen)
+	contents := bytes.Repeat(child, fanout)
+	return append(lib.AppendLength([]byte{0x30}, len(contents)), contents...)
+}
+
+var nestedLengthsTests = []struct{ fanout, depth, leafLen int }{
+	{1, 1, 0},
+	{1, 50, 1},
+	{1, 50, 200},
+	{2, 6, 100},
+	// The lengths cross from one to two bytes partway up.
+	{2, 10, 20},
+	{1, 3, 0x10000},
+}
+
+func TestNestedLengths(t *testing.T) {
+	for _, tt := range nestedLengthsTests {
+		in := nestedInput(tt.fanout, tt.depth, tt.leafLen)
+		out, err := Convert(in)
+		if err != nil {
+			t.Errorf("Convert(nestedInput(%d, %d, %d)) failed: %s", tt.fanout, tt.depth, tt.leafLen, err)
+		} else if want := nestedDER(tt.fanout, tt.depth, tt.leafLen); !bytes.Equal(out, want) {
+			t.Errorf("Convert(nestedInput(%d, %d, %d)) gave the wrong result.", tt.fanout, tt.depth, tt.leafLen)
+		}
+	}
+}
+
+// BenchmarkConvertDeep assembles a single chain of SEQUENCEs, nested as deep as
+// permitted, around a 64 KB OCTET STRING.
+func BenchmarkConvertDeep(b *testing.B) {
+	// Each length takes more than one byte, so the contents would move at
+	// every level if lengths were inserted rather than reserved.
+	in := nestedInput(1, DefaultMaxDepth-1, 64*1024)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		out, err := Convert(in)
+		if err != nil {
+			b.Fatal(err)
 		}
+		b.SetBytes(int64(len(out)))
 	}
 }
@@ -0,0 +1,112 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ascii2der
+
+import "sort"
+
+// A Mapping records that a range of output bytes came from a token in the
+// input.
+type Mapping struct {
+	// Start and End are the offsets of the range in the output. Start is
+	// inclusive and End is exclusive.
+	Start, End int
+	// Pos is the position of the token which emitted the range. Bytes from
+	// a block's length prefix, or from a keyword such as indefinite, map to
+	// the curly brace or keyword.
+	Pos Position
+}
+
+// A sourceMap collects mappings while assembling. Mappings are adjusted as
+// bytes move, such as when unused length bytes are removed or a set-of is
+// sorted. Some blocks are assembled into their own buffer, in which case
+// mappings are first recorded relative to that buffer. All methods are no-ops
+// on a nil *sourceMap.
+type sourceMap struct {
+	mappings []Mapping
+}
+
+// mark returns a marker for the mappings recorded from this point, for use with
+// the other methods.
+func (m *sourceMap) mark() int {
+	if m == nil {
+		return 0
+	}
+	return len(m.mappings)
+}
+
+// add records that output bytes from start to end came from the token at pos.
+func (m *sourceMap) add(start, end int, pos Position) {
+	if m == nil || start == end {
+		return
+	}
+	m.mappings = append(m.mappings, Mapping{start, end, pos})
+}
+
+// reset discards the mappings recorded since mark.
+func (m *sourceMap) reset(mark int) {
+	if m == nil {
+		return
+	}
+	m.mappings = m.mappings[:mark]
+}
+
+// A span is a range of bytes which was moved from one offset to another.
+type span struct {
+	from, to, length int
+}
+
+// relocate replaces the mappings recorded since mark with their portions which
+// fall within spans, moved accordingly. Bytes not covered by any span are
+// dropped. spans must not overlap, but may be in any order.
+func (m *sourceMap) relocate(mark int, spans []span) {
+	if m == nil {
+		return
+	}
+	// Sort the spans by source offset, so the spans overlapping each
+	// mapping can be found by binary search.
+	sorted := append([]span{}, spans...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].from < sorted[j].from })
+	old := append([]Mapping{}, m.mappings[mark:]...)
+	m.mappings = m.mappings[:mark]
+	for _, mapping := range old {
+		i := sort.Search(len(sorted), func(i int) bool { return sorted[i].from+sorted[i].length > mapping.Start })
+		for ; i < len(sorted) && sorted[i].from < mapping.End; i++ {
+			s := sorted[i]
+			start, end := mapping.Start, mapping.End
+			if start < s.from {
+				start = s.from
+			}
+			if end > s.from+s.length {
+				end = s.from + s.length
+			}
+			m.add(start-s.from+s.to, end-s.from+s.to, mapping.Pos)
+		}
+	}
+}
+
+// ConvertWithSourceMap behaves like Convert, but additionally returns a list of
+// mappings, sorted by Start, from ranges of the output to the tokens which
+// emitted them. Bytes from an include, or from a macro or repeat, map to the
+// include, use, or repeat token.
+func (opts Options) ConvertWithSourceMap(input string) ([]byte, []Mapping, error) {
+	opts.sourceMap = new(sourceMap)
+	out, err := opts.convert(NewScanner(input))
+	if err != nil {
+		return nil, nil, err
+	}
+	mappings := opts.sourceMap.mappings
+	sort.SliceStable(mappings, func(i, j int) bool { return mappings[i].Start < mappings[j].Start })
+	return out, mappings, nil
+}
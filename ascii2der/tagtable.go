@@ -0,0 +1,68 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ascii2der
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/der-ascii/lib"
+)
+
+// ParseTagTable reads a table of tag names from r, for use in
+// Options.TagTable. Each line has the form
+//
+//	NAME = TAG
+//
+// where TAG is a tag in the bracketed syntax, such as [0] or
+// [APPLICATION 2 PRIMITIVE]. Blank lines and lines beginning with # are
+// ignored. Entries which reuse a built-in name are skipped, since the built-in
+// name takes precedence, and reported in warnings.
+func ParseTagTable(r io.Reader) (table *lib.TagTable, warnings []string, err error) {
+	table = new(lib.TagTable)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || text[0] == '#' {
+			continue
+		}
+		i := strings.IndexByte(text, '=')
+		if i < 0 {
+			return nil, nil, fmt.Errorf("line %d: expected NAME = TAG", line)
+		}
+		name := strings.TrimSpace(text[:i])
+		value := strings.TrimSpace(text[i+1:])
+		if len(value) < 2 || value[0] != '[' || value[len(value)-1] != ']' {
+			return nil, nil, fmt.Errorf("line %d: expected a tag in brackets", line)
+		}
+		tag, err := decodeTagString(value[1:len(value)-1], nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		if _, ok := lib.TagByName(name); ok {
+			warnings = append(warnings, fmt.Sprintf("line %d: tag name '%s' is built in, so this entry is ignored", line, name))
+			continue
+		}
+		if err := table.Add(name, tag); err != nil {
+			return nil, nil, fmt.Errorf("line %d: %s", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return table, warnings, nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ascii2der
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/der-ascii/lib"
+)
+
+var parseTagTableErrorTests = []struct {
+	in, err string
+}{
+	{"GetRequest [0]", "line 1: expected NAME = TAG"},
+	{"GetRequest = 0", "line 1: expected a tag in brackets"},
+	{"\nGetRequest = [BOGUS]", `line 2: strconv.ParseUint: parsing "BOGUS": invalid syntax`},
+	{"A = [0]\nA = [1]", `line 2: tag name "A" is already defined`},
+	{"1A = [0]", `line 1: tag name "1A" must begin with a letter`},
+	{"rsaEncryption = [0]", `line 1: tag name "rsaEncryption" is the name of an OID`},
+}
+
+func TestParseTagTable(t *testing.T) {
+	in := `# SNMP PDUs.
+GetRequest = [0]
+  GetNextRequest=[1]
+
+Trap = [APPLICATION 4 PRIMITIVE]
+SEQUENCE = [2]
+`
+	table, warnings, err := ParseTagTable(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("ParseTagTable failed: %s", err)
+	}
+	if want := "line 6: tag name 'SEQUENCE' is built in, so this entry is ignored"; len(warnings) != 1 || warnings[0] != want {
+		t.Errorf("ParseTagTable gave warnings %q, wanted %q.", warnings, want)
+	}
+	for name, want := range map[string]lib.Tag{
+		"GetRequest":     {lib.ClassContextSpecific, 0, true},
+		"GetNextRequest": {lib.ClassContextSpecific, 1, true},
+		"Trap":           {lib.ClassApplication, 4, false},
+	} {
+		if tag, ok := table.TagByName(name); !ok || tag != want {
+			t.Errorf("TagByName(%q) = %v, %v, wanted %v.", name, tag, ok, want)
+		}
+	}
+
+	// The names may be used in place of tags, including in brackets.
+	opts := Options{TagTable: table}
+	out, err := opts.Convert("GetRequest { Trap { `01` } } [GetNextRequest PRIMITIVE] {}")
+	if want := []byte{0xa0, 0x03, 0x44, 0x01, 0x01, 0x81, 0x00}; err != nil || !bytes.Equal(out, want) {
+		t.Errorf("Convert = %x, %v, wanted %x.", out, err, want)
+	}
+	if _, err := Convert("GetRequest {}"); err == nil {
+		t.Errorf("Convert without the table unexpectedly succeeded.")
+	}
+
+	for _, tt := range parseTagTableErrorTests {
+		if _, _, err := ParseTagTable(strings.NewReader(tt.in)); err == nil || err.Error() != tt.err {
+			t.Errorf("ParseTagTable(%q) returned %v, wanted %q.", tt.in, err, tt.err)
+		}
+	}
+}
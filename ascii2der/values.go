@@ -12,7 +12,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package ascii2der
 
 import (
 	"errors"
@@ -24,12 +24,30 @@ import (
 )
 
 // decodeTagString decodes s as a tag descriptor and returns the decoded tag or
-// an error.
-func decodeTagString(s string) (lib.Tag, error) {
+// an error. The descriptor may name a tag in tags, which may be nil, in addition
+// to the built-in names.
+func decodeTagString(s string, tags *lib.TagTable) (lib.Tag, error) {
 	ss := strings.Split(s, " ")
 
-	// Tag aliases may only be in the first component.
+	// The first component may be CONSTRUCTED or PRIMITIVE, which overrides
+	// the constructed bit.
+	var constructed *bool
+	switch ss[0] {
+	case "CONSTRUCTED", "PRIMITIVE":
+		v := ss[0] == "CONSTRUCTED"
+		constructed = &v
+		ss = ss[1:]
+		if len(ss) == 0 {
+			return lib.Tag{}, errors.New("expected tag number")
+		}
+	}
+
+	// Tag aliases may only be in the first component, after any constructed
+	// bit.
 	tag, ok := lib.TagByName(ss[0])
+	if !ok {
+		tag, ok = tags.TagByName(ss[0])
+	}
 	if ok {
 		ss = ss[1:]
 		goto constructedOrPrimitive
@@ -69,6 +87,9 @@ func decodeTagString(s string) (lib.Tag, error) {
 constructedOrPrimitive:
 	// The final token, if any, may be CONSTRUCTED or PRIMITIVE.
 	if len(ss) > 0 {
+		if constructed != nil {
+			return lib.Tag{}, fmt.Errorf("excess tag component '%s'", ss[0])
+		}
 		switch ss[0] {
 		case "CONSTRUCTED":
 			tag.Constructed = true
@@ -84,5 +105,8 @@ constructedOrPrimitive:
 		return lib.Tag{}, fmt.Errorf("excess tag component '%s'", ss[0])
 	}
 
+	if constructed != nil {
+		tag.Constructed = *constructed
+	}
 	return tag, nil
 }
@@ -12,7 +12,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package ascii2der
 
 import (
 	"testing"
@@ -38,6 +38,10 @@ var decodeTagStringTests = []struct {
 	{"UNIVERSAL 2", lib.Tag{lib.ClassUniversal, 2, true}, true},
 	{"UNIVERSAL 2 CONSTRUCTED", lib.Tag{lib.ClassUniversal, 2, true}, true},
 	{"UNIVERSAL 2 PRIMITIVE", lib.Tag{lib.ClassUniversal, 2, false}, true},
+	{"UNIVERSAL 28", lib.Tag{lib.ClassUniversal, 28, true}, true},
+	{"PRIVATE 500", lib.Tag{lib.ClassPrivate, 500, true}, true},
+	{"4294967295", lib.Tag{lib.ClassContextSpecific, 4294967295, true}, true},
+	{"4294967296", lib.Tag{}, false},
 	{"UNIVERSAL 2 CONSTRUCTED EXTRA", lib.Tag{}, false},
 	{"UNIVERSAL 2 EXTRA", lib.Tag{}, false},
 	{"UNIVERSAL NOT_A_NUMBER", lib.Tag{}, false},
@@ -48,13 +52,57 @@ var decodeTagStringTests = []struct {
 	{" SEQUENCE", lib.Tag{}, false},
 	{"SEQUENCE ", lib.Tag{}, false},
 	{"SEQUENCE  CONSTRUCTED", lib.Tag{}, false},
+	// The constructed bit may also be given first.
+	{"PRIMITIVE 0", lib.Tag{lib.ClassContextSpecific, 0, false}, true},
+	{"CONSTRUCTED 0", lib.Tag{lib.ClassContextSpecific, 0, true}, true},
+	{"PRIMITIVE APPLICATION 5", lib.Tag{lib.ClassApplication, 5, false}, true},
+	{"CONSTRUCTED UNIVERSAL 2", lib.Tag{lib.ClassUniversal, 2, true}, true},
+	{"PRIMITIVE SEQUENCE", lib.Tag{lib.ClassUniversal, 16, false}, true},
+	{"CONSTRUCTED OCTET_STRING", lib.Tag{lib.ClassUniversal, 4, true}, true},
+	{"PRIMITIVE", lib.Tag{}, false},
+	{"PRIMITIVE CONSTRUCTED 0", lib.Tag{}, false},
+	{"PRIMITIVE 0 PRIMITIVE", lib.Tag{}, false},
+	{"PRIMITIVE 0 CONSTRUCTED", lib.Tag{}, false},
+	{"APPLICATION PRIMITIVE 5", lib.Tag{}, false},
 }
 
 func TestDecodeTagString(t *testing.T) {
 	for i, tt := range decodeTagStringTests {
-		tag, err := decodeTagString(tt.input)
+		tag, err := decodeTagString(tt.input, nil)
 		if tag != tt.tag || (err == nil) != tt.ok {
 			t.Errorf("%d. decodeTagString(%v) = %v, err=%s, wanted %v, success=%v", i, tt.input, tag, err, tt.tag, tt.ok)
 		}
 	}
 }
+
+var universalTagsTests = []struct {
+	universal, name string
+	encoded         byte
+}{
+	{"UNIVERSAL 16", "SEQUENCE", 0x30},
+	{"UNIVERSAL 4 PRIMITIVE", "OCTET_STRING", 0x04},
+	{"UNIVERSAL 4", "OCTET_STRING CONSTRUCTED", 0x24},
+}
+
+// TestUniversalTags checks that UNIVERSAL tags encode like the equivalent named
+// tags. Bracketed tags default to constructed, so primitive types must say so.
+func TestUniversalTags(t *testing.T) {
+	for _, tt := range universalTagsTests {
+		universal, err := decodeTagString(tt.universal, nil)
+		if err != nil {
+			t.Errorf("decodeTagString(%q) failed: %s", tt.universal, err)
+			continue
+		}
+		named, err := decodeTagString(tt.name, nil)
+		if err != nil {
+			t.Errorf("decodeTagString(%q) failed: %s", tt.name, err)
+			continue
+		}
+		if universal != named {
+			t.Errorf("decodeTagString(%q) = %v, wanted %v to match %q.", tt.universal, universal, named, tt.name)
+		}
+		if out := lib.AppendTag(nil, universal); len(out) != 1 || out[0] != tt.encoded {
+			t.Errorf("AppendTag(%v) = %x, wanted %02x.", universal, out, tt.encoded)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/google/der-ascii/ascii2der"
+)
+
+// errorContext returns the line of input containing pos, followed by a line
+// with a caret under pos, for display with a syntax error. It returns the empty
+// string if pos is not in input.
+func errorContext(input string, pos ascii2der.Position) string {
+	if pos.Offset < 0 || pos.Offset > len(input) {
+		return ""
+	}
+	start := strings.LastIndexAny(input[:pos.Offset], "\r\n") + 1
+	end := strings.IndexAny(input[pos.Offset:], "\r\n")
+	if end < 0 {
+		end = len(input)
+	} else {
+		end += pos.Offset
+	}
+	line := input[start:end]
+
+	// Keep tabs in the prefix so the caret lines up however tabs are
+	// rendered.
+	var b strings.Builder
+	b.WriteString(line)
+	b.WriteByte('\n')
+	for i := start; i < pos.Offset; i++ {
+		if input[i] == '\t' {
+			b.WriteByte('\t')
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	b.WriteString("^\n")
+	return b.String()
+}
+
+// readContext behaves like errorContext, but reads the input from r, starting
+// from the beginning. Only the line containing pos is retained, so the input
+// need not fit in memory. It returns the empty string if r cannot seek back to
+// the start or pos is not in the input.
+func readContext(r io.ReadSeeker, pos ascii2der.Position) string {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return ""
+	}
+	br := bufio.NewReader(r)
+	var line []byte
+	var lineStart int
+	for offset := 0; ; offset++ {
+		c, err := br.ReadByte()
+		if err != nil {
+			if offset < pos.Offset {
+				return ""
+			}
+			break
+		}
+		if c == '\n' || c == '\r' {
+			if offset >= pos.Offset {
+				break
+			}
+			line = line[:0]
+			lineStart = offset + 1
+			continue
+		}
+		line = append(line, c)
+	}
+	return errorContext(string(line), ascii2der.Position{Offset: pos.Offset - lineStart})
+}
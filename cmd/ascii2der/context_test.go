@@ -0,0 +1,58 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/der-ascii/ascii2der"
+)
+
+var errorContextTests = []struct {
+	in   string
+	want string
+}{
+	{"SEQUENCE {\n  BOGUS\n}\n", "  BOGUS\n  ^\n"},
+	{"SEQUENCE {\r\n\tBOGUS\r\n}", "\tBOGUS\n\t^\n"},
+	{"SEQUENCE {\r  [BOGUS]\r}", "  [BOGUS]\n  ^\n"},
+	{"1 2 BOGUS", "1 2 BOGUS\n    ^\n"},
+	// Errors at the end of the input point past the last byte.
+	{"SEQUENCE {\n  \"unterminated", "  \"unterminated\n  ^\n"},
+}
+
+func TestErrorContext(t *testing.T) {
+	for i, tt := range errorContextTests {
+		_, err := ascii2der.Convert(tt.in)
+		parseErr, ok := err.(*ascii2der.ParseError)
+		if !ok {
+			t.Errorf("%d. Convert(%q) returned %v, wanted a *ParseError.", i, tt.in, err)
+			continue
+		}
+		if got := errorContext(tt.in, parseErr.Pos); got != tt.want {
+			t.Errorf("%d. errorContext(%q, %+v) = %q, wanted %q.", i, tt.in, parseErr.Pos, got, tt.want)
+		}
+		if got := readContext(strings.NewReader(tt.in), parseErr.Pos); got != tt.want {
+			t.Errorf("%d. readContext(%q, %+v) = %q, wanted %q.", i, tt.in, parseErr.Pos, got, tt.want)
+		}
+	}
+
+	if got := errorContext("1", ascii2der.Position{Offset: 2, Line: 1, Column: 3}); got != "" {
+		t.Errorf("errorContext with an out of range position = %q, wanted the empty string.", got)
+	}
+	if got := readContext(strings.NewReader("1"), ascii2der.Position{Offset: 2, Line: 1, Column: 3}); got != "" {
+		t.Errorf("readContext with an out of range position = %q, wanted the empty string.", got)
+	}
+}
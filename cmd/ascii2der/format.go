@@ -0,0 +1,114 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// bytesPerLine is the number of bytes on each line of a formatted array.
+const bytesPerLine = 12
+
+var regexpIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// keywords are the keywords of each language, which may not be used as
+// variable names. The C keywords include those of C23.
+var keywords = map[string][]string{
+	"c": {
+		"alignas", "alignof", "auto", "bool", "break", "case", "char", "const", "constexpr", "continue",
+		"default", "do", "double", "else", "enum", "extern", "false", "float", "for", "goto", "if",
+		"inline", "int", "long", "nullptr", "register", "restrict", "return", "short", "signed",
+		"sizeof", "static", "static_assert", "struct", "switch", "thread_local", "true", "typedef",
+		"typeof", "typeof_unqual", "union", "unsigned", "void", "volatile", "while", "_Alignas",
+		"_Alignof", "_Atomic", "_BitInt", "_Bool", "_Complex", "_Decimal128", "_Decimal32",
+		"_Decimal64", "_Generic", "_Imaginary", "_Noreturn", "_Static_assert", "_Thread_local",
+	},
+	"go": {
+		"break", "case", "chan", "const", "continue", "default", "defer", "else", "fallthrough",
+		"for", "func", "go", "goto", "if", "import", "interface", "map", "package", "range",
+		"return", "select", "struct", "switch", "type", "var",
+	},
+}
+
+// checkFormat returns an error if format and name are not valid values for
+// -format and -var-name.
+func checkFormat(format, name string) error {
+	switch format {
+	case "raw":
+		if name != "" {
+			return errors.New("-var-name requires -format=c or -format=go")
+		}
+		return nil
+	case "c", "go":
+		if name != "" && !regexpIdentifier.MatchString(name) {
+			return fmt.Errorf("invalid variable name %q", name)
+		}
+		for _, keyword := range keywords[format] {
+			if name == keyword {
+				return fmt.Errorf("variable name %q is a keyword", name)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// formatOutput returns der formatted for -format, as either "raw", "c", or "go".
+// For "c" and "go", der is written as an array or byte slice literal, declared
+// as a variable named name if name is non-empty.
+func formatOutput(der []byte, format, name string) ([]byte, error) {
+	if err := checkFormat(format, name); err != nil {
+		return nil, err
+	}
+	if format == "raw" {
+		return der, nil
+	}
+
+	var b strings.Builder
+	var indent, end string
+	if format == "c" {
+		if name != "" {
+			fmt.Fprintf(&b, "static const uint8_t %s[] = ", name)
+			end = ";"
+		}
+		b.WriteString("{")
+		indent = "    "
+	} else {
+		if name != "" {
+			fmt.Fprintf(&b, "var %s = ", name)
+		}
+		b.WriteString("[]byte{")
+		indent = "\t"
+	}
+	for i, c := range der {
+		if i%bytesPerLine == 0 {
+			b.WriteString("\n")
+			b.WriteString(indent)
+		} else {
+			b.WriteString(" ")
+		}
+		fmt.Fprintf(&b, "0x%02x,", c)
+	}
+	if len(der) > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString("}")
+	b.WriteString(end)
+	b.WriteString("\n")
+	return []byte(b.String()), nil
+}
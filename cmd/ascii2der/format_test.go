@@ -0,0 +1,65 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+var formatOutputTests = []struct {
+	der    []byte
+	format string
+	name   string
+	out    string
+	ok     bool
+}{
+	{[]byte{0x05, 0x00}, "raw", "", "\x05\x00", true},
+	{[]byte{0x05, 0x00}, "c", "", "{\n    0x05, 0x00,\n}\n", true},
+	{[]byte{0x05, 0x00}, "c", "kNull", "static const uint8_t kNull[] = {\n    0x05, 0x00,\n};\n", true},
+	{[]byte{0x05, 0x00}, "go", "", "[]byte{\n\t0x05, 0x00,\n}\n", true},
+	{[]byte{0x05, 0x00}, "go", "null", "var null = []byte{\n\t0x05, 0x00,\n}\n", true},
+	{nil, "c", "", "{}\n", true},
+	{nil, "go", "empty", "var empty = []byte{}\n", true},
+	// Lines are wrapped every 12 bytes.
+	{
+		[]byte{0x04, 0x0b, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+		"go", "",
+		"[]byte{\n\t0x04, 0x0b, 0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09,\n\t0x0a,\n}\n",
+		true,
+	},
+	{[]byte{0x05, 0x00}, "raw", "kNull", "", false},
+	{[]byte{0x05, 0x00}, "c", "1st", "", false},
+	{[]byte{0x05, 0x00}, "go", "a-b", "", false},
+	// Keywords are rejected, but only for their own language.
+	{[]byte{0x05, 0x00}, "go", "type", "", false},
+	{[]byte{0x05, 0x00}, "c", "int", "", false},
+	{[]byte{0x05, 0x00}, "c", "type", "static const uint8_t type[] = {\n    0x05, 0x00,\n};\n", true},
+	{[]byte{0x05, 0x00}, "go", "int", "var int = []byte{\n\t0x05, 0x00,\n}\n", true},
+	{[]byte{0x05, 0x00}, "rust", "", "", false},
+}
+
+func TestFormatOutput(t *testing.T) {
+	for i, tt := range formatOutputTests {
+		out, err := formatOutput(tt.der, tt.format, tt.name)
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("%d. formatOutput(%x, %q, %q) unexpectedly succeeded.", i, tt.der, tt.format, tt.name)
+			}
+		} else if err != nil || !bytes.Equal(out, []byte(tt.out)) {
+			t.Errorf("%d. formatOutput(%x, %q, %q) = %q, %v, wanted %q.", i, tt.der, tt.format, tt.name, out, err, tt.out)
+		}
+	}
+}
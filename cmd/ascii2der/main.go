@@ -0,0 +1,347 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/google/der-ascii/ascii2der"
+	"github.com/google/der-ascii/der2ascii"
+	"github.com/google/der-ascii/internal/roundtrip"
+	"github.com/google/der-ascii/internal/tagtable"
+	"github.com/google/der-ascii/lib"
+)
+
+var inPath = flag.String("i", "", "input file to use, or - for stdin (defaults to stdin)")
+var outPath = flag.String("o", "", "output file to use, or - for stdout (defaults to stdout)")
+var maxDepth = flag.Int("max-depth", ascii2der.DefaultMaxDepth, "maximum nesting depth of curly braces")
+var maxLength = flag.Int("max-length", 0, "maximum length of an element's contents, or 0 for no limit")
+var checkDER = flag.Bool("check-der", false, "fail if the output is not valid DER")
+var outFormat = flag.String("format", "raw", "output format: raw for binary, c for a C array, or go for a Go byte slice")
+var varName = flag.String("var-name", "", "if set with -format=c or -format=go, declare the output as a variable with this name")
+var pemLabel = flag.String("pem", "", "if set, wrap the output in a PEM block with this label")
+var allowLeadingZeros = flag.Bool("allow-leading-zeros", false, "allow leading zeros in decimal integers and OID arcs")
+var allowInvalidStrings = flag.Bool("allow-invalid-strings", false, "allow characters in quoted strings which the enclosing string type does not permit")
+var strictTags = flag.Bool("strict-tags", false, "require every tag to be followed by curly braces or a length modifier")
+var ignoreTagCase = flag.Bool("ignore-tag-case", false, "match tag names case-insensitively, warning about non-canonical spellings")
+var lint = flag.Bool("lint", false, "report every syntax error in the input, rather than only the first, and write no output")
+var warn = flag.Bool("warn", false, "print advisories about likely mistakes, such as a u16 string in a PrintableString, to stderr")
+var loose = flag.Bool("loose", false, "emit unrecognized symbols as their ASCII bytes rather than failing")
+var roundTrip = flag.Bool("round-trip", false, "check that the output disassembles and reassembles to the same bytes")
+var sourceMapPath = flag.String("sourcemap", "", "if set, write a JSON source map from output byte ranges to input positions to this file")
+var tagTablePath = flag.String("tag-table", "", "if set, accept the tag names given as NAME = [TAG] lines in this file")
+var includeDir = flag.String("include-dir", "", "if set, enable include and resolve relative paths in the input against this directory")
+var defines = make(macroFlags)
+var hexInput = flag.Bool("hex", false, "treat the input as raw hex, ignoring whitespace, rather than DER ASCII")
+
+func init() {
+	flag.Var(defines, "define", "define a macro as NAME=VALUE, where VALUE is DER ASCII (may be repeated)")
+}
+
+func main() {
+	// Allow flags after the input file, as in "ascii2der in.txt -o out.der".
+	// The flag package otherwise stops at the first argument, including "-".
+	flag.Parse()
+	var args []string
+	for flag.NArg() > 0 {
+		args = append(args, flag.Arg(0))
+		flag.CommandLine.Parse(flag.Args()[1:])
+	}
+
+	path, err := inputPath(*inPath, args)
+	if err == nil && *hexInput && *sourceMapPath != "" {
+		err = errors.New("-sourcemap may not be used with -hex")
+	}
+	if err == nil && *lint && (*hexInput || *sourceMapPath != "") {
+		err = errors.New("-lint may not be used with -hex or -sourcemap")
+	}
+	if err == nil {
+		err = checkFormat(*outFormat, *varName)
+	}
+	if err == nil && *outFormat != "raw" && *pemLabel != "" {
+		err = errors.New("-pem may not be used with -format")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		fmt.Fprintf(os.Stderr, "Usage: %s [-o OUTPUT] [-max-depth N] [-max-length N] [-check-der] [-strict-tags] [-ignore-tag-case] [-lint] [-warn] [-include-dir DIR] [-define NAME=VALUE] [-hex] [-round-trip] [-sourcemap FILE] [-tag-table FILE] [-pem LABEL] [-format raw|c|go [-var-name NAME]] [INPUT | -i INPUT]\n", os.Args[0])
+		os.Exit(1)
+	}
+
+	var tagTable *lib.TagTable
+	if *tagTablePath != "" {
+		tagTable, err = tagtable.Load(*tagTablePath, os.Stderr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading tag table: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	inFile := os.Stdin
+	if path != "-" {
+		inFile, err = os.Open(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening %s: %s\n", path, err)
+			os.Exit(1)
+		}
+		defer inFile.Close()
+	}
+
+	var outBytes []byte
+	// context returns the lines of input to show with a syntax error or
+	// warning at pos.
+	var context func(pos ascii2der.Position) string
+	if *hexInput {
+		outBytes, err = decodeHexInput(inFile, *checkDER)
+	} else {
+		opts := ascii2der.Options{MaxDepth: *maxDepth, MaxLength: *maxLength, CheckDER: *checkDER, IncludeDir: *includeDir, AllowLeadingZeros: *allowLeadingZeros, AllowInvalidStrings: *allowInvalidStrings, Loose: *loose, TagTable: tagTable, StrictTags: *strictTags, IgnoreTagCase: *ignoreTagCase}
+		opts.Macros, err = defines.assemble(opts, *warn || *ignoreTagCase)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid %s\n", err)
+			os.Exit(1)
+		}
+		// Case-insensitive matches are always reported, so the input can
+		// be fixed. Warnings are printed once the input is assembled, so
+		// their context may be read back from the input.
+		var warnings []*ascii2der.ParseError
+		if *warn || *ignoreTagCase {
+			opts.Warn = func(err *ascii2der.ParseError) { warnings = append(warnings, err) }
+		}
+		if *lint || *sourceMapPath != "" {
+			// These modes need the whole input in memory.
+			var in []byte
+			in, err = ioutil.ReadAll(inFile)
+			context = func(pos ascii2der.Position) string { return errorContext(string(in), pos) }
+			if err == nil && *lint {
+				errs, err := opts.Lint(string(in))
+				printWarnings(warnings, context)
+				lintInput(errs, err, context)
+			}
+			if err == nil {
+				var mappings []ascii2der.Mapping
+				outBytes, mappings, err = opts.ConvertWithSourceMap(string(in))
+				if err == nil {
+					writeSourceMap(*sourceMapPath, mappings)
+				}
+			}
+		} else {
+			// Stream the input, and only read it again to show context.
+			context = func(pos ascii2der.Position) string { return readContext(inFile, pos) }
+			outBytes, err = opts.ConvertReader(inFile)
+		}
+		printWarnings(warnings, context)
+	}
+	switch err := err.(type) {
+	case nil:
+	case *ascii2der.ParseError:
+		fmt.Fprintf(os.Stderr, "Syntax error: %s\n%s", err, context(err.Pos))
+		os.Exit(1)
+	case *ascii2der.DERError:
+		fmt.Fprintf(os.Stderr, "Invalid DER: %s\n", err)
+		os.Exit(1)
+	default:
+		fmt.Fprintf(os.Stderr, "Error reading input: %s\n", err)
+		os.Exit(1)
+	}
+
+	if *roundTrip {
+		if err := roundtrip.Check(outBytes, der2ascii.Options{TagTable: tagTable}); err != nil {
+			fmt.Fprintf(os.Stderr, "Round trip failed: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Round trip passed\n")
+	}
+
+	if *pemLabel != "" {
+		outBytes = pem.EncodeToMemory(&pem.Block{Type: *pemLabel, Bytes: outBytes})
+	}
+	outBytes, err = formatOutput(outBytes, *outFormat, *varName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	outFile := os.Stdout
+	if *outPath != "" && *outPath != "-" {
+		// The output is written as raw bytes, with no newline translation,
+		// on all platforms.
+		outFile, err = os.Create(*outPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening %s: %s\n", *outPath, err)
+			os.Exit(1)
+		}
+		defer outFile.Close()
+	}
+	_, err = outFile.Write(outBytes)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// printWarnings prints warnings to stderr with context.
+func printWarnings(warnings []*ascii2der.ParseError, context func(ascii2der.Position) string) {
+	for _, err := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n%s", err, context(err.Pos))
+	}
+}
+
+// lintInput prints errs, the result of Options.Lint, to stderr with context and
+// exits, with a non-zero status if there were any errors.
+func lintInput(errs []*ascii2der.ParseError, err error, context func(ascii2der.Position) string) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "Syntax error: %s\n%s", err, context(err.Pos))
+	}
+	if len(errs) > 0 {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// inputPath returns the path of the input file, given the value of the -i flag
+// and the positional arguments. At most one of the two may name the input. It
+// returns "-" if the input is stdin, either because neither names it or because
+// it is named "-".
+func inputPath(flagPath string, args []string) (string, error) {
+	if len(args) > 1 {
+		return "", errors.New("too many arguments")
+	}
+	if len(args) == 1 {
+		if flagPath != "" {
+			return "", errors.New("input given both with -i and as an argument")
+		}
+		flagPath = args[0]
+	}
+	if flagPath == "" {
+		return "-", nil
+	}
+	return flagPath, nil
+}
+
+// sourceMapEntry is the JSON form of an ascii2der.Mapping. Line and Column are
+// 1-based, and Offset is the byte offset of the token in the input.
+type sourceMapEntry struct {
+	Start  int `json:"start"`
+	End    int `json:"end"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Offset int `json:"offset"`
+}
+
+// writeSourceMap writes mappings to path as a JSON array. It exits on error.
+func writeSourceMap(path string, mappings []ascii2der.Mapping) {
+	entries := make([]sourceMapEntry, 0, len(mappings))
+	for _, m := range mappings {
+		entries = append(entries, sourceMapEntry{m.Start, m.End, m.Pos.Line, m.Pos.Column, m.Pos.Offset})
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding source map: %s\n", err)
+		os.Exit(1)
+	}
+	if err := ioutil.WriteFile(path, append(data, '\n'), 0666); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing source map: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// decodeHexInput reads all of r and decodes it as hex, ignoring whitespace. If
+// checkDER is true, it also returns a *ascii2der.DERError if the result is not
+// valid DER.
+func decodeHexInput(r io.Reader, checkDER bool) ([]byte, error) {
+	in, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	out, err := hex.DecodeString(strings.Join(strings.Fields(string(in)), ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex: %s", err)
+	}
+	if checkDER {
+		if err := ascii2der.CheckDER(out); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// macroFlags is a flag.Value which collects -define flags. The values are
+// assembled by assemble, once the options they depend on are known.
+type macroFlags map[string]string
+
+func (m macroFlags) String() string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func (m macroFlags) Set(s string) error {
+	i := strings.IndexByte(s, '=')
+	if i < 0 {
+		return errors.New("expected NAME=VALUE")
+	}
+	name, value := s[:i], s[i+1:]
+	if !ascii2der.IsMacroName(name) {
+		return fmt.Errorf("invalid macro name '%s'", name)
+	}
+	if _, ok := m[name]; ok {
+		return fmt.Errorf("macro '%s' already defined", name)
+	}
+	m[name] = value
+	return nil
+}
+
+// assemble converts the value of each macro in m with opts and returns the
+// results, for use as opts.Macros. If warn is true, it prints warnings to
+// stderr. The values are not part of the input, so warnings are printed
+// without context.
+func (m macroFlags) assemble(opts ascii2der.Options, warn bool) (map[string][]byte, error) {
+	// The values are fragments, which need not be complete elements.
+	opts.CheckDER = false
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	macros := make(map[string][]byte, len(m))
+	for _, name := range names {
+		if warn {
+			name := name
+			opts.Warn = func(err *ascii2der.ParseError) { fmt.Fprintf(os.Stderr, "Warning: -define %s: %s\n", name, err) }
+		}
+		der, err := opts.Convert(m[name])
+		if err != nil {
+			return nil, fmt.Errorf("-define %s: %s", name, err)
+		}
+		macros[name] = der
+	}
+	return macros, nil
+}
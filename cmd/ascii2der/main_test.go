@@ -0,0 +1,130 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/der-ascii/ascii2der"
+)
+
+var inputPathTests = []struct {
+	flagPath string
+	args     []string
+	path     string
+	ok       bool
+}{
+	{"", nil, "-", true},
+	{"-", nil, "-", true},
+	{"", []string{"-"}, "-", true},
+	{"in.txt", nil, "in.txt", true},
+	{"", []string{"in.txt"}, "in.txt", true},
+	{"", []string{`C:\tests\in.txt`}, `C:\tests\in.txt`, true},
+	{"a.txt", []string{"b.txt"}, "", false},
+	{"", []string{"a.txt", "b.txt"}, "", false},
+}
+
+func TestInputPath(t *testing.T) {
+	for i, tt := range inputPathTests {
+		path, err := inputPath(tt.flagPath, tt.args)
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("%d. inputPath(%q, %q) unexpectedly succeeded.", i, tt.flagPath, tt.args)
+			}
+		} else if err != nil || path != tt.path {
+			t.Errorf("%d. inputPath(%q, %q) = %q, %v, wanted %q.", i, tt.flagPath, tt.args, path, err, tt.path)
+		}
+	}
+}
+
+var decodeHexInputTests = []struct {
+	in       string
+	checkDER bool
+	out      []byte
+	err      string
+}{
+	{"3000", false, []byte{0x30, 0x00}, ""},
+	{"", false, []byte{}, ""},
+	// Whitespace, including line breaks, is ignored.
+	{" 30 03\n\t02 01\r\n01\n", false, []byte{0x30, 0x03, 0x02, 0x01, 0x01}, ""},
+	{"300", false, nil, "invalid hex: encoding/hex: odd length hex string"},
+	{"30 0", false, nil, "invalid hex: encoding/hex: odd length hex string"},
+	{"zz00", false, nil, "invalid hex: encoding/hex: invalid byte: U+007A 'z'"},
+	// With checkDER, the output must also be valid DER.
+	{"3000", true, []byte{0x30, 0x00}, ""},
+	{"3081 00", false, []byte{0x30, 0x81, 0x00}, ""},
+	{"3081 00", true, nil, "offset 1: length has a leading zero byte"},
+}
+
+func TestDecodeHexInput(t *testing.T) {
+	for i, tt := range decodeHexInputTests {
+		out, err := decodeHexInput(strings.NewReader(tt.in), tt.checkDER)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%d. decodeHexInput(%q, %t) gave error %v, wanted %q.", i, tt.in, tt.checkDER, err, tt.err)
+			}
+		} else if err != nil || !bytes.Equal(out, tt.out) {
+			t.Errorf("%d. decodeHexInput(%q, %t) = %x, %v, wanted %x.", i, tt.in, tt.checkDER, out, err, tt.out)
+		}
+	}
+}
+
+var macroFlagsTests = []struct {
+	flags []string
+	opts  ascii2der.Options
+	name  string
+	value []byte
+	ok    bool
+}{
+	{[]string{"x=SEQUENCE { NULL }"}, ascii2der.Options{}, "x", []byte{0x30, 0x02, 0x05, 0x00}, true},
+	// Values need not be complete elements, even with CheckDER.
+	{[]string{"x=INTEGER"}, ascii2der.Options{CheckDER: true}, "x", []byte{0x02}, true},
+	// Values are assembled with the same options as the input.
+	{[]string{"x=SEQUENCE { SEQUENCE {} }"}, ascii2der.Options{MaxDepth: 1}, "", nil, false},
+	{[]string{"x=OCTET_STRING { `010203` }"}, ascii2der.Options{MaxLength: 2}, "", nil, false},
+	{[]string{"x=007"}, ascii2der.Options{AllowLeadingZeros: true}, "x", []byte{0x07}, true},
+	{[]string{"x=007"}, ascii2der.Options{}, "", nil, false},
+}
+
+func TestMacroFlags(t *testing.T) {
+	for i, tt := range macroFlagsTests {
+		m := make(macroFlags)
+		for _, flag := range tt.flags {
+			if err := m.Set(flag); err != nil {
+				t.Fatalf("%d. Set(%q) failed: %s.", i, flag, err)
+			}
+		}
+		macros, err := m.assemble(tt.opts, false)
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("%d. assemble(%q) unexpectedly succeeded.", i, tt.flags)
+			}
+		} else if err != nil || !bytes.Equal(macros[tt.name], tt.value) {
+			t.Errorf("%d. assemble(%q) gave %s = %x, %v, wanted %x.", i, tt.flags, tt.name, macros[tt.name], err, tt.value)
+		}
+	}
+
+	m := make(macroFlags)
+	for _, flag := range []string{"", "=NULL", "a b=NULL", "a=NULL"} {
+		if err := m.Set(flag); (err == nil) != (flag == "a=NULL") {
+			t.Errorf("Set(%q) returned %v.", flag, err)
+		}
+	}
+	if err := m.Set("a=TRUE"); err == nil {
+		t.Errorf("Set redefining a macro unexpectedly succeeded.")
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/google/der-ascii/der2ascii"
+	"github.com/google/der-ascii/internal/roundtrip"
+	"github.com/google/der-ascii/internal/tagtable"
+)
+
+var inPath = flag.String("i", "", "input file to use (defaults to stdin)")
+var outPath = flag.String("o", "", "output file to use (defaults to stdout)")
+var oidNames = flag.Bool("oid-names", false, "annotate well-known OIDs and KeyUsage bits with their names")
+var timeComments = flag.Bool("time-comments", false, "annotate UTCTimes and GeneralizedTimes with human-readable times")
+var format = flag.String("format", "ascii", "output format, either \"ascii\" or \"json\"")
+var strict = flag.Bool("strict", false, "require the input to be exactly one complete element")
+var pemIndex = flag.Int("pem-index", -1, "index of the PEM block to decode if the input contains several")
+var noRecurse = flag.Bool("no-recurse", false, "do not decode OCTET STRING and BIT STRING contents as nested DER")
+var wrap = flag.Int("wrap", der2ascii.DefaultWrap, "column at which to wrap long byte strings, or 0 to disable wrapping")
+var showHeader = flag.Bool("show-header", false, "annotate each element with its raw tag and length bytes")
+var canonicalize = flag.Bool("canonicalize", false, "re-encode the input as DER before disassembling it, warning about each change")
+var roundTrip = flag.Bool("round-trip", false, "check that the output reassembles to the input")
+var tagTablePath = flag.String("tag-table", "", "if set, name tags using the NAME = [TAG] lines in this file")
+var inferOf = flag.Bool("infer-of", false, "annotate SEQUENCEs and SETs whose children share a tag with a SEQUENCE OF or SET OF comment")
+var numericTags = flag.Bool("numeric-tags", false, "write every tag in the bracketed numeric form, such as [UNIVERSAL 16], rather than by name")
+var maxDepth = flag.Int("max-depth", der2ascii.DefaultMaxDepth, "maximum nesting depth of elements; deeper contents are written as hex")
+var indent = flag.String("indent", "2", "indentation per level, as a number of spaces or \"tab\"")
+
+func main() {
+	flag.Parse()
+
+	diffMode := flag.NArg() == 3 && flag.Arg(0) == "diff"
+	if flag.NArg() > 0 && !diffMode {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-i INPUT] [-o OUTPUT] [-format ascii|json] [-pem-index N] [-strict] [-canonicalize] [-round-trip] [-oid-names] [-time-comments] [-infer-of] [-numeric-tags] [-no-recurse] [-show-header] [-tag-table FILE] [-max-depth N] [-indent N|tab] [-wrap COLUMNS]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [-o OUTPUT] [-pem-index N] [-strict] [-oid-names] [-time-comments] [-infer-of] [-numeric-tags] [-no-recurse] [-tag-table FILE] [-max-depth N] [-indent N|tab] [-wrap COLUMNS] diff A B\n", os.Args[0])
+		os.Exit(1)
+	}
+
+	indentUnit, ok := parseIndent(*indent)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Invalid indent %q: must be a positive number of spaces or \"tab\"\n", *indent)
+		os.Exit(1)
+	}
+
+	if *maxDepth <= 0 {
+		fmt.Fprintf(os.Stderr, "Invalid maximum depth %d\n", *maxDepth)
+		os.Exit(1)
+	}
+
+	if *wrap < 0 {
+		fmt.Fprintf(os.Stderr, "Invalid wrap column %d\n", *wrap)
+		os.Exit(1)
+	}
+	wrapColumn := *wrap
+	if wrapColumn == 0 {
+		// In Options, zero means the default, so disable wrapping with a
+		// negative value.
+		wrapColumn = -1
+	}
+
+	var convert func([]byte, der2ascii.Options) (string, error)
+	switch *format {
+	case "ascii":
+		convert = der2ascii.Convert
+	case "json":
+		convert = der2ascii.ConvertJSON
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid format %q: must be \"ascii\" or \"json\"\n", *format)
+		os.Exit(1)
+	}
+	if diffMode && (*format != "ascii" || *inPath != "" || *canonicalize || *roundTrip) {
+		fmt.Fprintf(os.Stderr, "diff does not support -format, -i, -canonicalize, or -round-trip\n")
+		os.Exit(1)
+	}
+	if *roundTrip && *format != "ascii" {
+		fmt.Fprintf(os.Stderr, "-round-trip requires -format ascii\n")
+		os.Exit(1)
+	}
+
+	opts := der2ascii.Options{
+		OIDNames:     *oidNames,
+		NoRecurse:    *noRecurse,
+		TimeComments: *timeComments,
+		Indent:       indentUnit,
+		Wrap:         wrapColumn,
+		Strict:       *strict,
+		ShowHeader:   *showHeader,
+		MaxDepth:     *maxDepth,
+		InferOf:      *inferOf,
+		NumericTags:  *numericTags,
+	}
+	if *tagTablePath != "" {
+		var err error
+		if opts.TagTable, err = tagtable.Load(*tagTablePath, os.Stderr); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading tag table: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if diffMode {
+		a := readDiffInput(flag.Arg(1))
+		b := readDiffInput(flag.Arg(2))
+		out, differ, err := der2ascii.Diff(a, b, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid input: %s\n", err)
+			os.Exit(1)
+		}
+		writeOutput(out)
+		if differ {
+			os.Exit(1)
+		}
+		return
+	}
+
+	inFile := os.Stdin
+	if *inPath != "" {
+		var err error
+		inFile, err = os.Open(*inPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening %s: %s\n", *inPath, err)
+			os.Exit(1)
+		}
+		defer inFile.Close()
+	}
+
+	inBytes, err := ioutil.ReadAll(inFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading input: %s\n", err)
+		os.Exit(1)
+	}
+
+	inBytes, label, err := decodePEMInput(inBytes, *pemIndex)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid PEM input: %s\n", err)
+		os.Exit(1)
+	}
+
+	if *canonicalize {
+		var changes []string
+		inBytes, changes = der2ascii.Canonicalize(inBytes)
+		for _, change := range changes {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", change)
+		}
+	}
+
+	out, err := convert(inBytes, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid input: %s\n", err)
+		os.Exit(1)
+	}
+	if *roundTrip {
+		if err := roundtrip.Check(inBytes, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Round trip failed: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Round trip passed\n")
+	}
+	if label != "" && *format == "ascii" {
+		out = fmt.Sprintf("# PEM: %s\n", label) + out
+	}
+
+	writeOutput(out)
+}
+
+// readDiffInput reads an input to diff from path, decoding it as PEM if needed.
+// It exits on error.
+func readDiffInput(path string) []byte {
+	in, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading %s: %s\n", path, err)
+		os.Exit(1)
+	}
+	in, _, err = decodePEMInput(in, *pemIndex)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid PEM input in %s: %s\n", path, err)
+		os.Exit(1)
+	}
+	return in
+}
+
+// writeOutput writes out to the output file. It exits on error.
+func writeOutput(out string) {
+	outFile := os.Stdout
+	if *outPath != "" {
+		var err error
+		outFile, err = os.Create(*outPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening %s: %s\n", *outPath, err)
+			os.Exit(1)
+		}
+		defer outFile.Close()
+	}
+	if _, err := outFile.Write([]byte(out)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// parseIndent parses the value of the -indent flag and returns the string to
+// write for each level of indentation.
+func parseIndent(s string) (string, bool) {
+	if s == "tab" {
+		return "\t", true
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return "", false
+	}
+	return strings.Repeat(" ", n), true
+}
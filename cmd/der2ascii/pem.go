@@ -0,0 +1,60 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// decodePEMInput decodes in as PEM if it begins, after any whitespace, with a
+// PEM header. It returns the DER contents of the block and its label. If in
+// contains multiple blocks, index selects one. Otherwise, index must be
+// negative and in must contain at most one block. If in is not PEM, it returns
+// in unmodified and an empty label, and index must be negative.
+func decodePEMInput(in []byte, index int) (der []byte, label string, err error) {
+	// DER may contain the header, such as in an OCTET STRING, so it is only
+	// detected at the start.
+	if !bytes.HasPrefix(bytes.TrimLeft(in, " \t\r\n"), []byte("-----BEGIN ")) {
+		if index >= 0 {
+			return nil, "", errors.New("-pem-index was given, but the input is not PEM")
+		}
+		return in, "", nil
+	}
+
+	var blocks []*pem.Block
+	for rest := in; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		blocks = append(blocks, block)
+	}
+
+	switch {
+	case len(blocks) == 0:
+		return nil, "", errors.New("could not decode PEM block")
+	case index < 0 && len(blocks) > 1:
+		return nil, "", fmt.Errorf("input contains %d PEM blocks; use -pem-index to select one", len(blocks))
+	case index < 0:
+		index = 0
+	case index >= len(blocks):
+		return nil, "", fmt.Errorf("PEM block %d requested, but input contains %d", index, len(blocks))
+	}
+	return blocks[index].Bytes, blocks[index].Type, nil
+}
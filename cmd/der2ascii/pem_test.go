@@ -0,0 +1,75 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+const testPEM = `
+-----BEGIN TEST-----
+MAMCAQE=
+-----END TEST-----
+`
+
+const testPEMs = testPEM + `Some text between blocks.
+-----BEGIN OTHER-----
+AgEC
+-----END OTHER-----
+`
+
+var decodePEMInputTests = []struct {
+	in    string
+	index int
+	der   []byte
+	label string
+	ok    bool
+}{
+	// Non-PEM input is passed through.
+	{"\x30\x03\x02\x01\x01", -1, []byte{0x30, 0x03, 0x02, 0x01, 0x01}, "", true},
+	// PEM headers elsewhere in the input, such as within DER, are ignored.
+	{"\x04\x0b-----BEGIN ", -1, []byte("\x04\x0b-----BEGIN "), "", true},
+	{"Some leading text." + testPEM, -1, []byte("Some leading text." + testPEM), "", true},
+	// An index requires PEM input.
+	{"\x30\x03\x02\x01\x01", 0, nil, "", false},
+	{testPEM, -1, []byte{0x30, 0x03, 0x02, 0x01, 0x01}, "TEST", true},
+	{testPEM, 0, []byte{0x30, 0x03, 0x02, 0x01, 0x01}, "TEST", true},
+	{testPEMs, 0, []byte{0x30, 0x03, 0x02, 0x01, 0x01}, "TEST", true},
+	{testPEMs, 1, []byte{0x02, 0x01, 0x02}, "OTHER", true},
+	// Multiple blocks require an index.
+	{testPEMs, -1, nil, "", false},
+	// The index is out of range.
+	{testPEM, 1, nil, "", false},
+	{testPEMs, 2, nil, "", false},
+	// The PEM block is malformed.
+	{"-----BEGIN TEST-----\n!!!!\n-----END TEST-----\n", -1, nil, "", false},
+	{"-----BEGIN TEST-----\nMAMCAQE=\n", -1, nil, "", false},
+}
+
+func TestDecodePEMInput(t *testing.T) {
+	for i, tt := range decodePEMInputTests {
+		der, label, err := decodePEMInput([]byte(tt.in), tt.index)
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("%d. decodePEMInput(%q, %d) unexpectedly succeeded.", i, tt.in, tt.index)
+			}
+		} else if err != nil {
+			t.Errorf("%d. decodePEMInput(%q, %d) unexpectedly failed: %s.", i, tt.in, tt.index, err)
+		} else if !bytes.Equal(der, tt.der) || label != tt.label {
+			t.Errorf("%d. decodePEMInput(%q, %d) = %x, %q, wanted %x, %q.", i, tt.in, tt.index, der, label, tt.der, tt.label)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package der2ascii
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/der-ascii/lib"
+)
+
+// Canonicalize re-encodes der, which is typically BER, as DER where it can. It
+// converts lengths to the minimal definite-length form, converts constructed
+// strings to primitive ones, sorts the elements of each SET, and minimally
+// encodes BOOLEANs and INTEGERs. The contents of other primitive elements are
+// kept as-is, even if they could be parsed as nested BER. Bytes which cannot be
+// parsed are also kept as-is. It returns the result and a description of each
+// change made, in order.
+func Canonicalize(der []byte) (canonical []byte, changes []string) {
+	// The contents of primitive elements are not canonicalized, so there is
+	// no need to parse them.
+	elems, _, _ := parseElements(&Options{NoRecurse: true}, der, false, 0)
+	var c canonicalizer
+	return c.appendElements(nil, elems, nil), c.changes
+}
+
+// A canonicalizer accumulates the changes made by Canonicalize.
+type canonicalizer struct {
+	changes []string
+}
+
+// note records a change made to the element at path, a list of the tags of the
+// enclosing elements.
+func (c *canonicalizer) note(path []string, format string, args ...interface{}) {
+	change := fmt.Sprintf(format, args...)
+	if len(path) != 0 {
+		change = strings.Join(path, " > ") + ": " + change
+	}
+	c.changes = append(c.changes, change)
+}
+
+// appendElements appends the canonical encoding of elems, which are within the
+// elements at path, to dst.
+func (c *canonicalizer) appendElements(dst []byte, elems []*element, path []string) []byte {
+	for _, elem := range elems {
+		dst = c.appendElement(dst, elem, path)
+	}
+	return dst
+}
+
+// appendElement appends the canonical encoding of elem, which is within the
+// elements at path, to dst.
+func (c *canonicalizer) appendElement(dst []byte, elem *element, path []string) []byte {
+	if elem.raw != nil {
+		c.note(path, "kept %d bytes which could not be parsed", len(elem.raw))
+		return append(dst, elem.raw...)
+	}
+
+	path = append(path[:len(path):len(path)], tagToString(elem.tag, nil))
+	if elem.longForm != 0 {
+		c.note(path, "converted non-minimal length to minimal")
+	}
+	if elem.missingEOC {
+		c.note(path, "converted indefinite length with missing end-of-contents to definite")
+	} else if elem.indefinite {
+		c.note(path, "converted indefinite length to definite")
+	}
+
+	tag := elem.tag
+	var body []byte
+	if elem.indefinite || tag.Constructed {
+		if str, ok := flattenString(elem); ok {
+			c.note(path, "converted constructed string to primitive")
+			tag.Constructed = false
+			body = str
+		} else {
+			body = c.appendChildren(elem, path)
+		}
+	} else {
+		body = c.canonicalPrimitive(elem, path)
+	}
+
+	dst = lib.AppendTag(dst, tag)
+	dst = lib.AppendLength(dst, len(body))
+	return append(dst, body...)
+}
+
+// appendChildren returns the canonical encoding of the children of elem, which
+// is at path. The elements of a SET are sorted, but any bytes which could not be
+// parsed are kept at the end.
+func (c *canonicalizer) appendChildren(elem *element, path []string) []byte {
+	if elem.tag != (lib.Tag{Class: lib.ClassUniversal, Number: 17, Constructed: true}) {
+		return c.appendElements(nil, elem.children, path)
+	}
+	children := elem.children
+	var raw *element
+	if len(children) != 0 && children[len(children)-1].raw != nil {
+		raw = children[len(children)-1]
+		children = children[:len(children)-1]
+	}
+	// DER sorts SET OF by encoding. The elements of a SET are sorted by
+	// tag, which sorting by encoding also achieves.
+	encoded := make([][]byte, len(children))
+	for i, child := range children {
+		encoded[i] = c.appendElement(nil, child, path)
+	}
+	sorted := sort.SliceIsSorted(encoded, func(i, j int) bool { return bytes.Compare(encoded[i], encoded[j]) < 0 })
+	if !sorted {
+		c.note(path, "sorted elements")
+		sort.SliceStable(encoded, func(i, j int) bool { return bytes.Compare(encoded[i], encoded[j]) < 0 })
+	}
+	body := bytes.Join(encoded, nil)
+	if raw != nil {
+		body = c.appendElement(body, raw, path)
+	}
+	return body
+}
+
+// canonicalPrimitive returns the canonical contents of elem, a definite-length
+// primitive element at path.
+func (c *canonicalizer) canonicalPrimitive(elem *element, path []string) []byte {
+	body := elem.body
+	// If ok is false, name will be empty. There is also no need to check
+	// toggleConstructed as we already know the tag is primitive.
+	name, _, _ := elem.tag.GetAlias()
+	switch name {
+	case "BOOLEAN":
+		if len(body) == 1 && body[0] != 0x00 && body[0] != 0xff {
+			c.note(path, "converted non-canonical TRUE to 0xff")
+			return []byte{0xff}
+		}
+	case "INTEGER", "ENUMERATED":
+		var n int
+		for n+1 < len(body) && ((body[n] == 0x00 && body[n+1]&0x80 == 0) || (body[n] == 0xff && body[n+1]&0x80 != 0)) {
+			n++
+		}
+		if n != 0 {
+			c.note(path, "removed %d redundant leading bytes", n)
+			return body[n:]
+		}
+	}
+	return body
+}
+
+// isStringTag returns true if tag, ignoring the constructed bit, is a universal
+// type which DER requires to be primitive, and whose BER constructed form is a
+// series of segments which are concatenated.
+func isStringTag(tag lib.Tag) bool {
+	if tag.Class != lib.ClassUniversal {
+		return false
+	}
+	switch tag.Number {
+	case 3, 4, 12, 18, 19, 20, 21, 22, 25, 26, 27, 28, 29, 30:
+		return true
+	}
+	return false
+}
+
+// flattenString returns the contents of elem, which must be constructed or
+// indefinite-length, as the equivalent primitive string, or false if it is not
+// a valid BER constructed string.
+func flattenString(elem *element) ([]byte, bool) {
+	if !isStringTag(elem.tag) {
+		return nil, false
+	}
+	isBitString := elem.tag.Number == 3
+	var out []byte
+	if isBitString {
+		out = []byte{0}
+	}
+	for i, child := range elem.children {
+		if child.raw != nil || child.tag.Class != elem.tag.Class || child.tag.Number != elem.tag.Number {
+			return nil, false
+		}
+		segment := child.body
+		if child.indefinite || child.tag.Constructed {
+			var ok bool
+			if segment, ok = flattenString(child); !ok {
+				return nil, false
+			}
+		}
+		if isBitString {
+			// Each segment begins with its number of unused bits, which
+			// must be zero for all but the last segment.
+			if len(segment) == 0 || segment[0] > 7 || (segment[0] != 0 && i != len(elem.children)-1) {
+				return nil, false
+			}
+			out[0] = segment[0]
+			segment = segment[1:]
+		}
+		out = append(out, segment...)
+	}
+	return out, true
+}
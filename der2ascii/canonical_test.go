@@ -0,0 +1,159 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package der2ascii
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/google/der-ascii/ascii2der"
+)
+
+var canonicalizeTests = []struct {
+	in      string
+	out     string
+	changes []string
+}{
+	// Canonical input is unchanged.
+	{
+		"SEQUENCE { INTEGER { 1 } SET { INTEGER { 1 } INTEGER { 2 } } }",
+		"SEQUENCE { INTEGER { 1 } SET { INTEGER { 1 } INTEGER { 2 } } }",
+		nil,
+	},
+	{
+		"SEQUENCE long-form(2) { INTEGER long-form(1) { 1 } }",
+		"SEQUENCE { INTEGER { 1 } }",
+		[]string{"SEQUENCE: converted non-minimal length to minimal", "SEQUENCE > INTEGER: converted non-minimal length to minimal"},
+	},
+	{
+		"SEQUENCE indefinite { [0] indefinite { NULL } }",
+		"SEQUENCE { [0] { NULL } }",
+		[]string{"SEQUENCE: converted indefinite length to definite", "SEQUENCE > [0]: converted indefinite length to definite"},
+	},
+	{
+		"[OCTET_STRING CONSTRUCTED] indefinite { OCTET_STRING { \"a\" } [OCTET_STRING CONSTRUCTED] { OCTET_STRING { \"bc\" } } }",
+		"OCTET_STRING { \"abc\" }",
+		[]string{"[OCTET_STRING CONSTRUCTED]: converted indefinite length to definite", "[OCTET_STRING CONSTRUCTED]: converted constructed string to primitive"},
+	},
+	{
+		"[BIT_STRING CONSTRUCTED] { BIT_STRING { `00aa` } BIT_STRING { `04b0` } }",
+		"BIT_STRING { `04aab0` }",
+		[]string{"[BIT_STRING CONSTRUCTED]: converted constructed string to primitive"},
+	},
+	{
+		"SET { INTEGER { 2 } OCTET_STRING {} INTEGER { 1 } }",
+		"SET { INTEGER { 1 } INTEGER { 2 } OCTET_STRING {} }",
+		[]string{"SET: sorted elements"},
+	},
+	// SETs are sorted after their elements are canonicalized.
+	{
+		"SET { INTEGER { `0002` } INTEGER { 1 } }",
+		"SET { INTEGER { 1 } INTEGER { 2 } }",
+		[]string{"SET > INTEGER: removed 1 redundant leading bytes", "SET: sorted elements"},
+	},
+	{
+		"SEQUENCE { INTEGER { `ffff80` } ENUMERATED { `0080` } BOOLEAN { `01` } BOOLEAN { `00` } }",
+		"SEQUENCE { INTEGER { -128 } ENUMERATED { `0080` } BOOLEAN { TRUE } BOOLEAN { FALSE } }",
+		[]string{"SEQUENCE > INTEGER: removed 2 redundant leading bytes", "SEQUENCE > BOOLEAN: converted non-canonical TRUE to 0xff"},
+	},
+	// The contents of other primitive elements are kept.
+	{
+		"OCTET_STRING { SEQUENCE long-form(1) {} }",
+		"OCTET_STRING { SEQUENCE long-form(1) {} }",
+		nil,
+	},
+	{
+		"[APPLICATION 1234] indefinite {}",
+		"[APPLICATION 1234] {}",
+		[]string{"[APPLICATION 1234]: converted indefinite length to definite"},
+	},
+}
+
+// canonicalizeBERTests contains inputs which cannot be written in DER ASCII
+// without being repaired.
+var canonicalizeBERTests = []struct {
+	in      []byte
+	out     []byte
+	changes []string
+}{
+	// Bytes which cannot be parsed are kept.
+	{
+		[]byte{0x30, 0x80, 0x00, 0x00, 0xaa, 0xbb},
+		[]byte{0x30, 0x00, 0xaa, 0xbb},
+		[]string{"SEQUENCE: converted indefinite length to definite", "kept 2 bytes which could not be parsed"},
+	},
+	// They are kept at the end of a SET, which is otherwise sorted.
+	{
+		[]byte{0x31, 0x04, 0x02, 0x01, 0x02, 0x00},
+		[]byte{0x31, 0x04, 0x02, 0x01, 0x02, 0x00},
+		[]string{"SET: kept 1 bytes which could not be parsed"},
+	},
+	{
+		[]byte{0x31, 0x07, 0x02, 0x01, 0x02, 0x02, 0x01, 0x01, 0x00},
+		[]byte{0x31, 0x07, 0x02, 0x01, 0x01, 0x02, 0x01, 0x02, 0x00},
+		[]string{"SET: sorted elements", "SET: kept 1 bytes which could not be parsed"},
+	},
+	// An indefinite-length string missing its end-of-contents is still
+	// converted to a primitive string.
+	{
+		[]byte{0x24, 0x80},
+		[]byte{0x04, 0x00},
+		[]string{"[OCTET_STRING CONSTRUCTED]: converted indefinite length with missing end-of-contents to definite", "[OCTET_STRING CONSTRUCTED]: converted constructed string to primitive"},
+	},
+	{
+		[]byte{0x24, 0x80, 0x04, 0x01, 0x61},
+		[]byte{0x04, 0x01, 0x61},
+		[]string{"[OCTET_STRING CONSTRUCTED]: converted indefinite length with missing end-of-contents to definite", "[OCTET_STRING CONSTRUCTED]: converted constructed string to primitive"},
+	},
+}
+
+func TestCanonicalize(t *testing.T) {
+	for i, tt := range canonicalizeTests {
+		in, err := ascii2der.Convert(tt.in)
+		if err != nil {
+			t.Fatalf("%d. Could not assemble %q: %s", i, tt.in, err)
+		}
+		want, err := ascii2der.Convert(tt.out)
+		if err != nil {
+			t.Fatalf("%d. Could not assemble %q: %s", i, tt.out, err)
+		}
+		out, changes := Canonicalize(in)
+		if !bytes.Equal(out, want) {
+			t.Errorf("%d. Canonicalize(%x) = %x, wanted %x.", i, in, out, want)
+		}
+		if !reflect.DeepEqual(changes, tt.changes) {
+			t.Errorf("%d. Canonicalize(%x) made changes %q, wanted %q.", i, in, changes, tt.changes)
+		}
+		// The result is DER, so canonicalizing it again makes no
+		// changes.
+		if err := ascii2der.CheckDER(out); err != nil {
+			t.Errorf("%d. Canonicalize(%x) = %x, which is not DER: %s", i, in, out, err)
+		}
+		if out2, changes := Canonicalize(out); !bytes.Equal(out2, out) || changes != nil {
+			t.Errorf("%d. Canonicalizing %x again = %x with changes %q, wanted no changes.", i, out, out2, changes)
+		}
+	}
+
+	for i, tt := range canonicalizeBERTests {
+		out, changes := Canonicalize(tt.in)
+		if !bytes.Equal(out, tt.out) || !reflect.DeepEqual(changes, tt.changes) {
+			t.Errorf("%d. Canonicalize(%x) = %x, %q, wanted %x, %q.", i, tt.in, out, changes, tt.out, tt.changes)
+		}
+		if out2, _ := Canonicalize(out); !bytes.Equal(out2, out) {
+			t.Errorf("%d. Canonicalizing %x again = %x, wanted no change.", i, out, out2)
+		}
+	}
+}
@@ -12,9 +12,14 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package der2ascii
 
-import "github.com/google/der-ascii/lib"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/der-ascii/lib"
+)
 
 func parseBase128(bytes []byte) (ret uint32, rest []byte, ok bool) {
 	// The tag must be minimally-encoded, so the first byte may not be 0x80.
@@ -39,105 +44,140 @@ func parseBase128(bytes []byte) (ret uint32, rest []byte, ok bool) {
 
 // parseTag parses a tag from b, returning the resulting tag and the remainder
 // of the slice. On parse failure, ok is returned as false and rest is
-// unchanged.
+// unchanged. Unlike lib.ParseTag, it rejects the EOC tag.
 func parseTag(bytes []byte) (tag lib.Tag, rest []byte, ok bool) {
-	rest = bytes
-
-	// Consume the first byte. Reject EOC.
-	if len(rest) == 0 || rest[0] == 0 {
-		return
-	}
-	b := rest[0]
-	rest = rest[1:]
-
-	class := lib.Class(b & 0xc0)
-	number := uint32(b & 0x1f)
-	constructed := b&0x20 != 0
-	if number < 0x1f {
-		// Low-tag-number form.
-		tag = lib.Tag{class, number, constructed}
-		ok = true
-		return
+	if len(bytes) == 0 || bytes[0] == 0 {
+		return lib.Tag{}, bytes, false
 	}
-
-	n, rest, base128Ok := parseBase128(rest)
-	if !base128Ok || n < 0x1f {
-		// Parse error or non-minimal encoding.
-		rest = bytes
-		return
+	tag, n, err := lib.ParseTag(bytes)
+	if err != nil {
+		return lib.Tag{}, bytes, false
 	}
-	number = n
-
-	tag = lib.Tag{class, number, constructed}
-	ok = true
-	return
+	return tag, bytes[n:], true
 }
 
 // parseTagAndLength parses a tag and length pair from bytes. If the resulting
 // length is indefinite, it sets indefinite to true.
 func parseTagAndLength(bytes []byte) (tag lib.Tag, length int, indefinite bool, rest []byte, ok bool) {
+	// Reject EOC.
+	if len(bytes) == 0 || bytes[0] == 0 {
+		return lib.Tag{}, 0, false, bytes, false
+	}
+	tag, length, n, err := lib.ParseElement(bytes)
+	if err != nil {
+		return lib.Tag{}, 0, false, bytes, false
+	}
+	if length == lib.IndefiniteLength {
+		return tag, 0, true, bytes[n:], true
+	}
+	return tag, length, false, bytes[n:], true
+}
+
+// parseElement parses an element from bytes. If the element is
+// indefinite-length body is left as nil and instead indefinite is set to true.
+func parseElement(bytes []byte) (tag lib.Tag, body []byte, indefinite bool, rest []byte, ok bool) {
 	rest = bytes
 
-	// Parse the tag.
-	tag, rest, ok = parseTag(rest)
-	if !ok {
-		return lib.Tag{}, 0, false, bytes, false
+	tag, length, indefinite, rest, ok := parseTagAndLength(rest)
+	if !ok || length > len(rest) {
+		return lib.Tag{}, nil, false, bytes, false
 	}
 
-	// Parse the length.
-	if len(rest) == 0 {
-		return lib.Tag{}, 0, false, bytes, false
+	body = rest[:length]
+	rest = rest[length:]
+	return
+}
+
+// parseLongFormElement parses an element from bytes with a definite length in
+// the long form. Unlike parseElement, it accepts non-minimal lengths. It
+// returns the number of bytes used to encode the length in lengthBytes.
+func parseLongFormElement(bytes []byte) (tag lib.Tag, body []byte, lengthBytes int, rest []byte, ok bool) {
+	tag, rest, ok = parseTag(bytes)
+	if !ok || len(rest) == 0 {
+		return lib.Tag{}, nil, 0, bytes, false
 	}
 	b := rest[0]
 	rest = rest[1:]
-	if b < 0x80 {
-		// Short form length.
-		length = int(b)
-		return
-	}
-	if b == 0x80 {
-		// Indefinite-length. Must be constructed.
-		if !tag.Constructed {
-			return lib.Tag{}, 0, false, bytes, false
-		}
-		indefinite = true
-		return
+	// 0x80 is indefinite-length and 0xff is reserved.
+	if b <= 0x80 || b == 0xff {
+		return lib.Tag{}, nil, 0, bytes, false
 	}
-	// Long form length.
-	b &= 0x7f
-	if int(b) > len(rest) || rest[0] == 0 {
-		// Not enough room or non-minimal length.
-		return lib.Tag{}, 0, false, bytes, false
+	lengthBytes = int(b & 0x7f)
+	if lengthBytes > len(rest) {
+		return lib.Tag{}, nil, 0, bytes, false
 	}
-	for i := 0; i < int(b); i++ {
+	var length int
+	for i := 0; i < lengthBytes; i++ {
 		if length >= 1<<23 {
 			// Overflow.
-			return lib.Tag{}, 0, false, bytes, false
+			return lib.Tag{}, nil, 0, bytes, false
 		}
 		length <<= 8
 		length |= int(rest[i])
 	}
-	if length < 0x80 {
-		// Should have been short form.
-		return lib.Tag{}, 0, false, bytes, false
+	rest = rest[lengthBytes:]
+	if length > len(rest) {
+		return lib.Tag{}, nil, 0, bytes, false
 	}
-	rest = rest[b:]
-	return
+	return tag, rest[:length], lengthBytes, rest[length:], true
 }
 
-// parseElement parses an element from bytes. If the element is
-// indefinite-length body is left as nil and instead indefinite is set to true.
-func parseElement(bytes []byte) (tag lib.Tag, body []byte, indefinite bool, rest []byte, ok bool) {
-	rest = bytes
+// elementLength returns the length of the complete element at the start of
+// bytes, including the end-of-contents markers of any indefinite-length
+// elements, and true. It returns false if bytes does not begin with a complete
+// element.
+func elementLength(bytes []byte) (int, bool) {
+	_, _, indefinite, rest, ok := parseElement(bytes)
+	if !ok {
+		return 0, false
+	}
+	if !indefinite {
+		return len(bytes) - len(rest), true
+	}
+	if _, rest, missingEOC := splitIndefinite(rest); !missingEOC {
+		return len(bytes) - len(rest), true
+	}
+	return 0, false
+}
 
-	tag, length, indefinite, rest, ok := parseTagAndLength(rest)
-	if !ok || length > len(rest) {
-		return lib.Tag{}, nil, false, bytes, false
+// splitIndefinite splits bytes, which follow the header of an indefinite-length
+// element, into the element's contents and the bytes after its end-of-contents
+// marker. It does not recurse, so it may be used on deeply nested input. If the
+// marker is not found, it returns all of bytes as the contents, nil, and true.
+func splitIndefinite(bytes []byte) (contents, rest []byte, missingEOC bool) {
+	var indefiniteCount int
+	for rest = bytes; len(rest) != 0; {
+		if len(rest) >= 2 && rest[0] == 0 && rest[1] == 0 {
+			if indefiniteCount == 0 {
+				return bytes[:len(bytes)-len(rest)], rest[2:], false
+			}
+			rest = rest[2:]
+			indefiniteCount--
+			continue
+		}
+		_, _, indefinite, next, ok := parseElement(rest)
+		if !ok {
+			break
+		}
+		rest = next
+		if indefinite {
+			indefiniteCount++
+		}
 	}
+	return bytes, nil, true
+}
 
-	body = rest[:length]
-	rest = rest[length:]
-	return
+// checkSingleElement returns an error unless bytes is exactly one complete
+// element with no trailing data.
+func checkSingleElement(bytes []byte) error {
+	n, ok := elementLength(bytes)
+	if !ok {
+		return errors.New("input does not begin with a complete element")
+	}
+	if n != len(bytes) {
+		return fmt.Errorf("trailing data at offset %d", n)
+	}
+	return nil
 }
 
 // decodeInteger decodes bytes as the contents of a DER INTEGER. It returns the
@@ -12,7 +12,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package der2ascii
 
 import (
 	"bytes"
@@ -130,7 +130,7 @@ func TestParseTagAndLength(t *testing.T) {
 			if !ok {
 				t.Errorf("%d. parseTagAndLength(%v) unexpectedly failed.", i, in)
 			} else if tag != tt.tag || length != tt.length || indefinite != tt.indefinite || !bytes.Equal(rest, in[len(tt.in):]) {
-				t.Errorf("%d. parseTagAndLength(%v) = %v, %v, %v, %v wanted %v, %v.", i, in, tag, length, indefinite, rest, tt.tag, tt.length, tt.indefinite, in[len(tt.in):])
+				t.Errorf("%d. parseTagAndLength(%v) = %v, %v, %v, %v wanted %v, %v, %v, %v.", i, in, tag, length, indefinite, rest, tt.tag, tt.length, tt.indefinite, in[len(tt.in):])
 			}
 		}
 	}
@@ -177,12 +177,92 @@ func TestParseElement(t *testing.T) {
 			if !ok {
 				t.Errorf("%d. parseElement(%v) unexpectedly failed.", i, in)
 			} else if tag != tt.tag || !bytes.Equal(body, tt.body) || indefinite != tt.indefinite || !bytes.Equal(rest, in[len(tt.in):]) {
-				t.Errorf("%d. parseElement(%v) = %v, %v, %v, %v wanted %v, %v.", i, in, tag, body, indefinite, rest, tt.tag, tt.body, tt.indefinite, in[len(tt.in):])
+				t.Errorf("%d. parseElement(%v) = %v, %v, %v, %v wanted %v, %v, %v, %v.", i, in, tag, body, indefinite, rest, tt.tag, tt.body, tt.indefinite, in[len(tt.in):])
 			}
 		}
 	}
 }
 
+var parseLongFormElementTests = []struct {
+	in          []byte
+	tag         lib.Tag
+	body        []byte
+	lengthBytes int
+	ok          bool
+}{
+	// Non-minimal lengths.
+	{[]byte{0x30, 0x81, 0x01, 0xaa}, sequenceTag, []byte{0xaa}, 1, true},
+	{[]byte{0x30, 0x82, 0x00, 0x01, 0xaa}, sequenceTag, []byte{0xaa}, 2, true},
+	{[]byte{0x30, 0x84, 0x00, 0x00, 0x00, 0x00}, sequenceTag, []byte{}, 4, true},
+	// Minimal long-form lengths are also accepted.
+	{append([]byte{0x30, 0x81, 0x80}, make([]byte, 0x80)...), sequenceTag, make([]byte, 0x80), 1, true},
+	// Short-form, indefinite, and reserved lengths.
+	{[]byte{0x30, 0x00}, lib.Tag{}, nil, 0, false},
+	{[]byte{0x30, 0x80}, lib.Tag{}, nil, 0, false},
+	{[]byte{0x30, 0xff}, lib.Tag{}, nil, 0, false},
+	// Too short.
+	{[]byte{0x30, 0x82, 0x00}, lib.Tag{}, nil, 0, false},
+	{[]byte{0x30, 0x81, 0x01}, lib.Tag{}, nil, 0, false},
+	// Overflow.
+	{[]byte{0x30, 0x85, 0xff, 0xff, 0xff, 0xff, 0xff}, lib.Tag{}, nil, 0, false},
+}
+
+func TestParseLongFormElement(t *testing.T) {
+	for i, tt := range parseLongFormElementTests {
+		tag, body, lengthBytes, rest, ok := parseLongFormElement(tt.in)
+		if !tt.ok {
+			if ok {
+				t.Errorf("%d. parseLongFormElement(%v) unexpectedly succeeded.", i, tt.in)
+			} else if !bytes.Equal(rest, tt.in) {
+				t.Errorf("%d. parseLongFormElement(%v) did not preserve input.", i, tt.in)
+			}
+		} else if !ok {
+			t.Errorf("%d. parseLongFormElement(%v) unexpectedly failed.", i, tt.in)
+		} else if tag != tt.tag || !bytes.Equal(body, tt.body) || lengthBytes != tt.lengthBytes || len(rest) != 0 {
+			t.Errorf("%d. parseLongFormElement(%v) = %v, %v, %v, %v wanted %v, %v, %v, [].", i, tt.in, tag, body, lengthBytes, rest, tt.tag, tt.body, tt.lengthBytes)
+		}
+	}
+}
+
+var checkSingleElementTests = []struct {
+	in     []byte
+	ok     bool
+	length int
+}{
+	{[]byte{0x02, 0x01, 0x01}, true, 3},
+	{[]byte{0x30, 0x03, 0x02, 0x01, 0x01}, true, 5},
+	{[]byte{0x30, 0x80, 0x30, 0x80, 0x00, 0x00, 0x02, 0x01, 0x01, 0x00, 0x00}, true, 11},
+	// One valid element followed by a stray byte.
+	{[]byte{0x02, 0x01, 0x01, 0x00}, false, 3},
+	{[]byte{0x30, 0x80, 0x00, 0x00, 0x30, 0x00}, false, 4},
+	// Incomplete elements.
+	{[]byte{}, false, 0},
+	{[]byte{0x30, 0x03, 0x02, 0x01}, false, 0},
+	{[]byte{0x30, 0x80, 0x02, 0x01, 0x01}, false, 0},
+}
+
+func TestCheckSingleElement(t *testing.T) {
+	for i, tt := range checkSingleElementTests {
+		err := checkSingleElement(tt.in)
+		if tt.ok {
+			if err != nil {
+				t.Errorf("%d. checkSingleElement(%x) unexpectedly failed: %s.", i, tt.in, err)
+			}
+		} else if err == nil {
+			t.Errorf("%d. checkSingleElement(%x) unexpectedly succeeded.", i, tt.in)
+		}
+
+		if n, ok := elementLength(tt.in); ok != (tt.length != 0) || n != tt.length {
+			t.Errorf("%d. elementLength(%x) = %d, %v, wanted %d.", i, tt.in, n, ok, tt.length)
+		}
+	}
+
+	// The error should report the offset of the first leftover byte.
+	if err := checkSingleElement([]byte{0x02, 0x01, 0x01, 0x00}); err == nil || err.Error() != "trailing data at offset 3" {
+		t.Errorf("checkSingleElement returned %v, wanted trailing data at offset 3.", err)
+	}
+}
+
 var decodeIntegerTests = []struct {
 	in  []byte
 	out int64
@@ -0,0 +1,137 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package der2ascii
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Diff disassembles a and b with the options in opts and compares them element
+// by element. It returns the DER ASCII for both, where each line is prefixed by
+// "  " if it is common to both, "- " if it is only in a, or "+ " if it is only
+// in b. Before each pair of differing elements, a comment notes whether the
+// tag, length, or content differs. It also returns whether a and b differ. If
+// opts.Strict is set, each input must be a single complete element.
+func Diff(a, b []byte, opts Options) (diff string, differ bool, err error) {
+	if opts.Strict {
+		if err := checkSingleElement(a); err != nil {
+			return "", false, err
+		}
+		if err := checkSingleElement(b); err != nil {
+			return "", false, err
+		}
+	}
+	opts.ShowHeader = false
+	d := diffWriter{opts: &opts}
+	as, _, _ := parseElements(&opts, a, false, 0)
+	bs, _, _ := parseElements(&opts, b, false, 0)
+	d.diffElements(as, bs, 0)
+	return d.out.String(), d.differ, nil
+}
+
+// A diffWriter accumulates the output of Diff.
+type diffWriter struct {
+	opts   *Options
+	out    strings.Builder
+	differ bool
+}
+
+// render returns the DER ASCII for elem, indented to depth.
+func (d *diffWriter) render(elem *element, depth int) string {
+	w := writer{indent: depth, indentUnit: d.opts.Indent}
+	writeElement(&w, d.opts, elem)
+	return w.String()
+}
+
+// writeMarked writes each line of text, which must end in a newline, prefixed
+// by marker.
+func (d *diffWriter) writeMarked(marker, text string) {
+	for _, line := range strings.SplitAfter(strings.TrimSuffix(text, "\n"), "\n") {
+		d.out.WriteString(marker)
+		d.out.WriteString(strings.TrimSuffix(line, "\n"))
+		d.out.WriteString("\n")
+	}
+}
+
+// writeLine writes line, indented to depth, as a line common to both inputs.
+func (d *diffWriter) writeLine(line string, depth int) {
+	w := writer{indent: depth, indentUnit: d.opts.Indent}
+	w.WriteLine(line)
+	d.writeMarked("  ", w.String())
+}
+
+// note records that the inputs differ and writes a comment explaining how.
+func (d *diffWriter) note(reason string, depth int) {
+	d.differ = true
+	d.writeLine("# "+reason, depth)
+}
+
+func (d *diffWriter) diffElements(as, bs []*element, depth int) {
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		switch {
+		case i >= len(bs):
+			d.note("only in first", depth)
+			d.writeMarked("- ", d.render(as[i], depth))
+		case i >= len(as):
+			d.note("only in second", depth)
+			d.writeMarked("+ ", d.render(bs[i], depth))
+		default:
+			d.diffElement(as[i], bs[i], depth)
+		}
+	}
+}
+
+func (d *diffWriter) diffElement(a, b *element, depth int) {
+	aStr, bStr := d.render(a, depth), d.render(b, depth)
+	if aStr == bStr {
+		d.writeMarked("  ", aStr)
+		return
+	}
+
+	var reason string
+	switch {
+	case a.raw != nil || b.raw != nil:
+		reason = "content differs"
+	case a.tag != b.tag:
+		reason = "tag differs"
+	default:
+		aLine, closing, aOK := openLine(d.opts, a)
+		bLine, _, bOK := openLine(d.opts, b)
+		if aOK && bOK && aLine == bLine && bytes.Equal(a.prefix, b.prefix) {
+			// The elements differ in their children, so compare those.
+			if !a.indefinite && len(a.body) != len(b.body) {
+				d.note("length differs", depth)
+			}
+			d.writeLine(aLine, depth)
+			if len(a.prefix) != 0 {
+				d.writeLine(bytesToString(a.prefix), depth+1)
+			}
+			d.diffElements(a.children, b.children, depth+1)
+			if closing {
+				d.writeLine("}", depth)
+			}
+			return
+		}
+		if a.longForm != b.longForm || a.indefinite != b.indefinite || len(a.body) != len(b.body) {
+			reason = "length differs"
+		} else {
+			reason = "content differs"
+		}
+	}
+	d.note(reason, depth)
+	d.writeMarked("- ", aStr)
+	d.writeMarked("+ ", bStr)
+}
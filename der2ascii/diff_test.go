@@ -0,0 +1,107 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package der2ascii
+
+import (
+	"testing"
+
+	"github.com/google/der-ascii/ascii2der"
+)
+
+var diffTests = []struct {
+	a, b   string
+	diff   string
+	differ bool
+}{
+	{
+		"SEQUENCE { INTEGER { 1 } }",
+		"SEQUENCE { INTEGER { 1 } }",
+		"  SEQUENCE {\n    INTEGER { 1 }\n  }\n",
+		false,
+	},
+	{
+		"SEQUENCE { INTEGER { 1 } NULL {} }",
+		"SEQUENCE { INTEGER { 2 } NULL {} }",
+		"  SEQUENCE {\n    # content differs\n-   INTEGER { 1 }\n+   INTEGER { 2 }\n    NULL {}\n  }\n",
+		true,
+	},
+	{
+		"SEQUENCE { INTEGER { 1 } }",
+		"SEQUENCE { ENUMERATED { 1 } }",
+		"  SEQUENCE {\n    # tag differs\n-   INTEGER { 1 }\n+   ENUMERATED { 1 }\n  }\n",
+		true,
+	},
+	{
+		"SEQUENCE { INTEGER { 1 } }",
+		"SEQUENCE { INTEGER { 1 } INTEGER { 2 } }",
+		"  # length differs\n  SEQUENCE {\n    INTEGER { 1 }\n    # only in second\n+   INTEGER { 2 }\n  }\n",
+		true,
+	},
+	{
+		"OCTET_STRING { \"abc\" } NULL {}",
+		"OCTET_STRING { \"abcd\" }",
+		"  # length differs\n- OCTET_STRING { \"abc\" }\n+ OCTET_STRING { \"abcd\" }\n  # only in first\n- NULL {}\n",
+		true,
+	},
+	{
+		"SEQUENCE { SEQUENCE { } }",
+		"SEQUENCE indefinite { SEQUENCE { } }",
+		"  # length differs\n- SEQUENCE {\n-   SEQUENCE {}\n- }\n+ SEQUENCE indefinite {\n+   SEQUENCE {}\n+ }\n",
+		true,
+	},
+	// Indefinite-length elements are compared by their children.
+	{
+		"[0] indefinite { INTEGER { 1 } }",
+		"[0] indefinite { INTEGER { 3 } }",
+		"  [0] indefinite {\n    # content differs\n-   INTEGER { 1 }\n+   INTEGER { 3 }\n  }\n",
+		true,
+	},
+	// Guessed nesting is compared structurally.
+	{
+		"OCTET_STRING { SEQUENCE { INTEGER { 1 } } }",
+		"OCTET_STRING { SEQUENCE { INTEGER { 2 } } }",
+		"  OCTET_STRING { # guessed nesting\n    SEQUENCE {\n      # content differs\n-     INTEGER { 1 }\n+     INTEGER { 2 }\n    }\n  }\n",
+		true,
+	},
+	{
+		"`ff`",
+		"`fe`",
+		"  # content differs\n- `ff`\n+ `fe`\n",
+		true,
+	},
+}
+
+func TestDiff(t *testing.T) {
+	for i, tt := range diffTests {
+		a, err := ascii2der.Convert(tt.a)
+		if err != nil {
+			t.Fatalf("%d. Could not assemble %q: %s.", i, tt.a, err)
+		}
+		b, err := ascii2der.Convert(tt.b)
+		if err != nil {
+			t.Fatalf("%d. Could not assemble %q: %s.", i, tt.b, err)
+		}
+		diff, differ, err := Diff(a, b, Options{})
+		if err != nil {
+			t.Errorf("%d. Diff failed: %s.", i, err)
+		} else if diff != tt.diff || differ != tt.differ {
+			t.Errorf("%d. Diff(%q, %q) = %q, %v, wanted %q, %v.", i, tt.a, tt.b, diff, differ, tt.diff, tt.differ)
+		}
+	}
+
+	if _, _, err := Diff([]byte{0x05, 0x00}, []byte{0x05, 0x00, 0x05, 0x00}, Options{Strict: true}); err == nil {
+		t.Errorf("Diff with Strict unexpectedly accepted trailing data.")
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package der2ascii
+
+import (
+	"bytes"
+
+	"github.com/google/der-ascii/lib"
+)
+
+// An element is a node in the tree of parsed input. Each output format renders
+// the same tree, so they agree on how the input was parsed.
+type element struct {
+	// tag is the element's tag. It is unset if raw is non-nil.
+	tag lib.Tag
+	// raw, if non-nil, contains bytes which could not be parsed as an element.
+	// It is always the last element in its list.
+	raw []byte
+	// depthLimited is true if raw contains the contents of an element which
+	// were not parsed because they exceed Options.MaxDepth.
+	depthLimited bool
+	// indefinite is true if the element is indefinite-length. Its contents
+	// are then in children, rather than body.
+	indefinite bool
+	// missingEOC is true if the element is indefinite-length but its
+	// end-of-contents marker was not found.
+	missingEOC bool
+	// longForm, if non-zero, is the number of bytes used to encode a
+	// non-minimal long-form length.
+	longForm int
+	// body contains the element's contents if it is definite-length.
+	body []byte
+	// children contains the elements within the element if it is
+	// indefinite-length, constructed, or guessed.
+	children []*element
+	// guessed is true if the element is primitive, but its body was
+	// heuristically parsed as nested DER. In this case, the children were
+	// parsed from body after prefix.
+	guessed bool
+	// prefix contains any bytes in body which precede the children.
+	prefix []byte
+	// keyUsage is true if the element is the BIT STRING in an X.509 KeyUsage
+	// extension.
+	keyUsage bool
+	// header contains the tag and length bytes of the element, exactly as
+	// they appeared in the input.
+	header []byte
+}
+
+// parseElements parses bytes as a series of elements. If stopAtEOC is true, it
+// stops at an end-of-contents marker and returns the remaining input and true.
+// Otherwise, it consumes all of bytes and returns nil and false. depth is the
+// number of elements enclosing bytes. The contents of elements at
+// opts.MaxDepth are not parsed.
+func parseElements(opts *Options, bytes []byte, stopAtEOC bool, depth int) ([]*element, []byte, bool) {
+	var elems []*element
+	for len(bytes) != 0 {
+		if stopAtEOC && len(bytes) >= 2 && bytes[0] == 0 && bytes[1] == 0 {
+			return elems, bytes[2:], true
+		}
+
+		var longForm int
+		tag, body, indefinite, rest, ok := parseElement(bytes)
+		if !ok {
+			// The length may be non-minimal. This is preserved in the
+			// output, so the input still round-trips.
+			tag, body, longForm, rest, ok = parseLongFormElement(bytes)
+		}
+		if !ok {
+			// Nothing more to parse. Save the rest as bytes.
+			return append(elems, &element{raw: bytes}), nil, false
+		}
+		// For an indefinite-length element, body is empty and rest begins
+		// just after the header.
+		header := bytes[:len(bytes)-len(rest)-len(body)]
+		bytes = rest
+
+		elem := &element{tag: tag, indefinite: indefinite, longForm: longForm, header: header}
+		elems = append(elems, elem)
+		limited := depth+1 >= opts.maxDepth()
+		if indefinite && limited {
+			// Find the end-of-contents marker without recursing.
+			var contents []byte
+			contents, bytes, elem.missingEOC = splitIndefinite(bytes)
+			if len(contents) != 0 {
+				elem.children = []*element{{raw: contents, depthLimited: true}}
+			}
+			continue
+		}
+		if indefinite {
+			var foundEOC bool
+			elem.children, bytes, foundEOC = parseElements(opts, bytes, true, depth+1)
+			elem.missingEOC = !foundEOC
+			continue
+		}
+
+		elem.body = body
+		if len(body) == 0 {
+			continue
+		}
+		if tag.Constructed && limited {
+			elem.children = []*element{{raw: body, depthLimited: true}}
+			continue
+		}
+		if tag.Constructed {
+			elem.children, _, _ = parseElements(opts, body, false, depth+1)
+			markKeyUsage(elem)
+			continue
+		}
+
+		// The element is primitive. In some cases, we heuristically
+		// parse the body as DER too.
+		if opts.NoRecurse || limited {
+			continue
+		}
+		// If ok is false, name will be empty. There is also no need to
+		// check toggleConstructed as we already know the tag is
+		// primitive.
+		name, _, _ := tag.GetAlias()
+		switch name {
+		case "BOOLEAN", "INTEGER", "ENUMERATED", "OBJECT_IDENTIFIER":
+			// These are always decoded as values.
+		case "BIT_STRING":
+			// X.509 encodes signatures and SPKIs in BIT STRINGs, so
+			// there is a 0 phase byte followed by the potentially
+			// DER-encoded structure.
+			if len(body) > 1 && body[0] == 0 && isMadeOfElements(body[1:]) {
+				elem.guessed = true
+				elem.prefix = body[:1]
+				elem.children, _, _ = parseElements(opts, body[1:], false, depth+1)
+			}
+		default:
+			// Keep parsing if the body looks like ASN.1, unless it
+			// will be annotated as a time.
+			//
+			// TODO(davidben): This is O(N^2) for deeply-nested
+			// indefinite-length encodings inside primitive
+			// elements.
+			if opts.TimeComments && timeComment(name, body) != "" {
+				break
+			}
+			if isMadeOfElements(body) {
+				elem.guessed = true
+				elem.children, _, _ = parseElements(opts, body, false, depth+1)
+			}
+		}
+	}
+	return elems, nil, false
+}
+
+// keyUsageOID is the encoded OID of the X.509 KeyUsage extension.
+var keyUsageOID = []byte{0x55, 0x1d, 0x0f}
+
+// markKeyUsage marks the BIT STRING within elem, if elem is an X.509 KeyUsage
+// extension whose value was parsed as nested DER.
+func markKeyUsage(elem *element) {
+	// Extension ::= SEQUENCE {
+	//   extnID     OBJECT IDENTIFIER,
+	//   critical   BOOLEAN DEFAULT FALSE,
+	//   extnValue  OCTET STRING }
+	children := elem.children
+	if elem.tag != (lib.Tag{Class: lib.ClassUniversal, Number: 16, Constructed: true}) || len(children) < 2 {
+		return
+	}
+	extnID, extnValue := children[0], children[len(children)-1]
+	if extnID.tag != (lib.Tag{Class: lib.ClassUniversal, Number: 6}) || !bytes.Equal(extnID.body, keyUsageOID) {
+		return
+	}
+	if extnValue.tag != (lib.Tag{Class: lib.ClassUniversal, Number: 4}) || !extnValue.guessed || len(extnValue.children) != 1 {
+		return
+	}
+	if bits := extnValue.children[0]; bits.tag == (lib.Tag{Class: lib.ClassUniversal, Number: 3}) && !bits.guessed {
+		bits.keyUsage = true
+	}
+}
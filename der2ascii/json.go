@@ -0,0 +1,118 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package der2ascii
+
+import (
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/google/der-ascii/lib"
+)
+
+// jsonElement is the JSON representation of an element.
+type jsonElement struct {
+	// Raw contains, in hex, bytes which could not be parsed as an element.
+	// If set, no other fields are set.
+	Raw string `json:"raw,omitempty"`
+
+	Tag *jsonTag `json:"tag,omitempty"`
+	// Length is the length of the element's contents. It is omitted for
+	// indefinite-length elements.
+	Length *int `json:"length,omitempty"`
+	// LongForm, if non-zero, is the number of bytes in a non-minimal
+	// long-form length.
+	LongForm   int  `json:"long_form,omitempty"`
+	Indefinite bool `json:"indefinite,omitempty"`
+	MissingEOC bool `json:"missing_eoc,omitempty"`
+	// Contents contains, in hex, the contents of a primitive element which
+	// was not heuristically parsed as nested DER.
+	Contents *string `json:"contents,omitempty"`
+	// Guessed is true if a primitive element was heuristically parsed as
+	// nested DER. Prefix then contains, in hex, any bytes before the nested
+	// elements.
+	Guessed  bool           `json:"guessed,omitempty"`
+	Prefix   string         `json:"prefix,omitempty"`
+	Children []*jsonElement `json:"children,omitempty"`
+}
+
+type jsonTag struct {
+	Class       string `json:"class"`
+	Number      uint32 `json:"number"`
+	Constructed bool   `json:"constructed"`
+}
+
+func jsonClass(class lib.Class) string {
+	switch class {
+	case lib.ClassUniversal:
+		return "UNIVERSAL"
+	case lib.ClassApplication:
+		return "APPLICATION"
+	case lib.ClassContextSpecific:
+		return "CONTEXT_SPECIFIC"
+	case lib.ClassPrivate:
+		return "PRIVATE"
+	default:
+		panic(class)
+	}
+}
+
+func elementsToJSON(elems []*element) []*jsonElement {
+	ret := make([]*jsonElement, 0, len(elems))
+	for _, elem := range elems {
+		if elem.raw != nil {
+			ret = append(ret, &jsonElement{Raw: hex.EncodeToString(elem.raw)})
+			continue
+		}
+
+		j := &jsonElement{
+			Tag: &jsonTag{
+				Class:       jsonClass(elem.tag.Class),
+				Number:      elem.tag.Number,
+				Constructed: elem.tag.Constructed,
+			},
+			LongForm:   elem.longForm,
+			Indefinite: elem.indefinite,
+			MissingEOC: elem.missingEOC,
+			Guessed:    elem.guessed,
+			Prefix:     hex.EncodeToString(elem.prefix),
+			Children:   elementsToJSON(elem.children),
+		}
+		if !elem.indefinite {
+			length := len(elem.body)
+			j.Length = &length
+			if !elem.tag.Constructed && !elem.guessed {
+				contents := hex.EncodeToString(elem.body)
+				j.Contents = &contents
+			}
+		}
+		ret = append(ret, j)
+	}
+	return ret
+}
+
+// derToJSON returns a JSON representation of the elements in bytes, parsed as
+// for derToASCII.
+func (opts *Options) derToJSON(bytes []byte) string {
+	elems, _, _ := parseElements(opts, bytes, false, 0)
+	indent := opts.Indent
+	if indent == "" {
+		indent = "  "
+	}
+	out, err := json.MarshalIndent(elementsToJSON(elems), "", indent)
+	if err != nil {
+		panic(err)
+	}
+	return string(out) + "\n"
+}
@@ -0,0 +1,71 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package der2ascii
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDERToJSON(t *testing.T) {
+	// [0] indefinite { INTEGER { 1 } OCTET_STRING { SEQUENCE {} } } `ff`
+	in := []byte{0xa0, 0x80, 0x02, 0x01, 0x01, 0x04, 0x02, 0x30, 0x00, 0x00, 0x00, 0xff}
+	var opts Options
+	var out []map[string]interface{}
+	if err := json.Unmarshal([]byte(opts.derToJSON(in)), &out); err != nil {
+		t.Fatalf("Could not parse JSON output: %s.", err)
+	}
+
+	want := []map[string]interface{}{
+		{
+			"tag":        map[string]interface{}{"class": "CONTEXT_SPECIFIC", "number": 0.0, "constructed": true},
+			"indefinite": true,
+			"children": []interface{}{
+				map[string]interface{}{
+					"tag":      map[string]interface{}{"class": "UNIVERSAL", "number": 2.0, "constructed": false},
+					"length":   1.0,
+					"contents": "01",
+				},
+				map[string]interface{}{
+					"tag":     map[string]interface{}{"class": "UNIVERSAL", "number": 4.0, "constructed": false},
+					"length":  2.0,
+					"guessed": true,
+					"children": []interface{}{
+						map[string]interface{}{
+							"tag":    map[string]interface{}{"class": "UNIVERSAL", "number": 16.0, "constructed": true},
+							"length": 0.0,
+						},
+					},
+				},
+			},
+		},
+		{"raw": "ff"},
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("derToJSON(%x) = %v, wanted %v.", in, out, want)
+	}
+
+	// Disabling the heuristic replaces the nested elements with contents.
+	opts.NoRecurse = true
+	out = nil
+	if err := json.Unmarshal([]byte(opts.derToJSON(in)), &out); err != nil {
+		t.Fatalf("Could not parse JSON output: %s.", err)
+	}
+	octetString := out[0]["children"].([]interface{})[1].(map[string]interface{})
+	if octetString["contents"] != "3000" || octetString["children"] != nil {
+		t.Errorf("OCTET STRING without recursion was %v, wanted contents 3000.", octetString)
+	}
+}
@@ -12,24 +12,99 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+// Package der2ascii implements the DER ASCII disassembler, which converts a
+// byte string to DER ASCII. See language.txt for the language specification.
+package der2ascii
 
 import (
 	"encoding/hex"
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
 	"unicode"
 
 	"github.com/google/der-ascii/lib"
 )
 
+// Options contains options for disassembling DER. The zero value uses the
+// defaults.
+type Options struct {
+	// OIDNames, if true, annotates well-known OIDs with their names in
+	// comments. It also annotates the BIT STRING in an X.509 KeyUsage
+	// extension with the names of the bits set.
+	OIDNames bool
+	// NoRecurse, if true, disables heuristically decoding the contents of
+	// primitive elements, such as OCTET STRINGs, as nested DER.
+	NoRecurse bool
+	// TimeComments, if true, annotates valid UTCTimes and GeneralizedTimes
+	// with the time in a human-readable form in comments.
+	TimeComments bool
+	// Indent is the string written for each level of indentation. If empty,
+	// two spaces are used.
+	Indent string
+	// Wrap is the column at which long byte strings are split across lines.
+	// If zero, DefaultWrap is used. If negative, byte strings are never
+	// wrapped.
+	Wrap int
+	// Strict, if true, requires the input be exactly one complete element
+	// with no trailing data.
+	Strict bool
+	// ShowHeader, if true, annotates the line which begins each element with
+	// a comment containing the element's tag and length bytes, as they
+	// appeared in the input. It is ignored by Diff, where the two inputs'
+	// headers may differ.
+	ShowHeader bool
+	// TagTable, if non-nil, names tags which have no built-in name. Tags
+	// given more than one name in the table are not named.
+	TagTable *lib.TagTable
+	// MaxDepth is the maximum nesting depth of elements. The contents of
+	// elements nested this deeply are written as a hex literal, rather than
+	// parsed. If zero or negative, DefaultMaxDepth is used.
+	MaxDepth int
+	// InferOf, if true, annotates each SEQUENCE or SET whose children all
+	// have the same tag with a comment such as "SEQUENCE OF INTEGER". This
+	// is only a guess from the encoding, not from a schema.
+	InferOf bool
+	// NumericTags, if true, writes every tag in the bracketed numeric form,
+	// such as [UNIVERSAL 16] for SEQUENCE, rather than by name. Contents are
+	// still decoded according to the tag.
+	NumericTags bool
+}
+
+// DefaultWrap is the default column at which long byte strings are wrapped.
+const DefaultWrap = 80
+
+// DefaultMaxDepth is the default maximum nesting depth of elements. It matches
+// ascii2der's default limit, so the output may be reassembled.
+const DefaultMaxDepth = 1000
+
+func (opts *Options) maxDepth() int {
+	if opts.MaxDepth <= 0 {
+		return DefaultMaxDepth
+	}
+	return opts.MaxDepth
+}
+
+func (opts *Options) wrapColumn() int {
+	if opts.Wrap == 0 {
+		return DefaultWrap
+	}
+	return opts.Wrap
+}
+
 type writer struct {
-	out    string
+	out    strings.Builder
 	indent int
+	// indentUnit is the string written for each level of indentation. If
+	// empty, two spaces are used.
+	indentUnit string
+	// comment, if non-empty, is appended to the next line written.
+	comment string
 }
 
 func (w *writer) String() string {
-	return w.out
+	return w.out.String()
 }
 
 func (w *writer) SetIndent(indent int) {
@@ -44,12 +119,87 @@ func (w *writer) AddIndent(v int) {
 	w.indent += v
 }
 
+func (w *writer) unit() string {
+	if w.indentUnit == "" {
+		return "  "
+	}
+	return w.indentUnit
+}
+
+// IndentWidth returns the number of columns taken by the current indentation,
+// counting tabs as eight columns.
+func (w *writer) IndentWidth() int {
+	var width int
+	for _, c := range w.unit() {
+		if c == '\t' {
+			width += 8
+		} else {
+			width++
+		}
+	}
+	return width * w.indent
+}
+
+// minWrapWidth is the narrowest width to which byte strings are wrapped. Without
+// it, once the indentation passed the wrap column, each byte would be written on
+// its own line.
+const minWrapWidth = 16
+
+// wrapWidth returns the width available for byte strings before wrap, after the
+// current indentation, but at least minWrapWidth.
+func (w *writer) wrapWidth(wrap int) int {
+	if width := wrap - w.IndentWidth(); width > minWrapWidth {
+		return width
+	}
+	return minWrapWidth
+}
+
 func (w *writer) WriteLine(line string) {
+	unit := w.unit()
 	for i := 0; i < w.indent; i++ {
-		w.out += "  "
+		w.out.WriteString(unit)
+	}
+	w.out.WriteString(line)
+	w.out.WriteString(w.comment)
+	w.out.WriteString("\n")
+	w.comment = ""
+}
+
+// writeBytes writes bytes as a raw byte string, splitting it across multiple
+// lines if it would otherwise extend past the wrap column.
+func writeBytes(w *writer, opts *Options, bytes []byte) {
+	wrap := opts.wrapColumn()
+	if wrap < 0 {
+		w.WriteLine(bytesToString(bytes))
+		return
+	}
+	for _, segment := range splitBytes(bytes, w.wrapWidth(wrap), isMostlyPrintable(bytes)) {
+		w.WriteLine(segment)
+	}
+}
+
+// writeBytesElement writes an element with tag and the given body, encoded as
+// a raw byte string. If quoted is true, the body is encoded as a quoted string
+// and otherwise as a hex literal. If the element does not fit on one line
+// before the wrap column, the body is split across multiple lines.
+func writeBytesElement(w *writer, opts *Options, tag string, body []byte, quoted bool) {
+	str := bytesToHexString(body)
+	if quoted {
+		str = bytesToQuotedString(body)
+	}
+	line := fmt.Sprintf("%s { %s }", tag, str)
+	wrap := opts.wrapColumn()
+	if wrap < 0 || w.IndentWidth()+len(line) <= wrap {
+		w.WriteLine(line)
+		return
 	}
-	w.out += line
-	w.out += "\n"
+	w.WriteLine(fmt.Sprintf("%s {", tag))
+	w.AddIndent(1)
+	for _, segment := range splitBytes(body, w.wrapWidth(wrap), quoted) {
+		w.WriteLine(segment)
+	}
+	w.AddIndent(-1)
+	w.WriteLine("}")
 }
 
 // isMadeOfElements returns true if bytes can be parsed as a series of DER
@@ -90,9 +240,14 @@ func classToString(class lib.Class) string {
 	}
 }
 
-func tagToString(tag lib.Tag) string {
+// tagToString returns tag as written in DER ASCII, using a name from the
+// built-in names or tags, which may be nil, if possible.
+func tagToString(tag lib.Tag, tags *lib.TagTable) string {
 	// Write a short name if possible.
 	name, toggleConstructed, ok := tag.GetAlias()
+	if !ok {
+		name, toggleConstructed, ok = tags.GetAlias(tag)
+	}
 	if ok {
 		if !toggleConstructed {
 			return name
@@ -103,7 +258,12 @@ func tagToString(tag lib.Tag) string {
 		}
 		return fmt.Sprintf("[%s %s]", name, constructed)
 	}
+	return numericTagString(tag)
+}
 
+// numericTagString returns tag as written in DER ASCII in the bracketed numeric
+// form, with no name.
+func numericTagString(tag lib.Tag) string {
 	out := "["
 	if tag.Class != lib.ClassContextSpecific {
 		out += fmt.Sprintf("%s ", classToString(tag.Class))
@@ -116,25 +276,82 @@ func tagToString(tag lib.Tag) string {
 	return out
 }
 
-func bytesToString(bytes []byte) string {
-	if len(bytes) == 0 {
-		return ""
+// tagString returns tag as written in DER ASCII with the options in opts.
+func (opts *Options) tagString(tag lib.Tag) string {
+	if opts.NumericTags {
+		return numericTagString(tag)
 	}
+	return tagToString(tag, opts.TagTable)
+}
 
+// isMostlyPrintable returns true if bytes should be encoded as a quoted string
+// rather than a hex literal.
+func isMostlyPrintable(bytes []byte) bool {
 	var asciiCount int
 	for _, b := range bytes {
-		if b < 0x80 && (b == '\n' || unicode.IsPrint(rune(b))) {
+		if b < 0x80 && (b == '\n' || b == '\t' || unicode.IsPrint(rune(b))) {
 			asciiCount++
 		}
 	}
+	return float64(asciiCount)/float64(len(bytes)) > 0.85
+}
 
-	if float64(asciiCount)/float64(len(bytes)) > 0.85 {
+// isValidString returns true if bytes contains only characters permitted in
+// the string type with the given name, which must be NumericString or
+// PrintableString.
+func isValidString(name string, bytes []byte) bool {
+	for _, b := range bytes {
+		switch {
+		case b == ' ' || ('0' <= b && b <= '9'):
+		case name == "PrintableString" && (('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z') || strings.IndexByte("'()+,-./:=?", b) >= 0):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func bytesToString(bytes []byte) string {
+	if len(bytes) == 0 {
+		return ""
+	}
+
+	if isMostlyPrintable(bytes) {
 		return bytesToQuotedString(bytes)
 	} else {
 		return bytesToHexString(bytes)
 	}
 }
 
+// splitBytes encodes bytes as a series of quoted strings, if quoted is true, or
+// hex literals otherwise. Each is at most width columns wide, but contains at
+// least one byte.
+func splitBytes(bytes []byte, width int, quoted bool) []string {
+	var ret []string
+	if !quoted {
+		n := (width - 2) / 2
+		if n < 1 {
+			n = 1
+		}
+		for len(bytes) > n {
+			ret = append(ret, bytesToHexString(bytes[:n]))
+			bytes = bytes[n:]
+		}
+		return append(ret, bytesToHexString(bytes))
+	}
+
+	cur := `"`
+	for _, b := range bytes {
+		escaped := quoteByte(b)
+		if len(cur) > 1 && len(cur)+len(escaped)+1 > width {
+			ret = append(ret, cur+`"`)
+			cur = `"`
+		}
+		cur += escaped
+	}
+	return append(ret, cur+`"`)
+}
+
 func bytesToHexString(bytes []byte) string {
 	return fmt.Sprintf("`%s`", hex.EncodeToString(bytes))
 }
@@ -142,25 +359,63 @@ func bytesToHexString(bytes []byte) string {
 func bytesToQuotedString(bytes []byte) string {
 	out := `"`
 	for _, b := range bytes {
-		if b == '\n' {
-			out += `\n`
-		} else if b == '"' {
-			out += `\"`
-		} else if b == '\\' {
-			out += `\\`
-		} else if b >= 0x80 || !unicode.IsPrint(rune(b)) {
-			out += fmt.Sprintf(`\x%02x`, b)
-		} else {
-			out += string([]byte{b})
-		}
+		out += quoteByte(b)
 	}
 	out += `"`
 	return out
 }
 
-func integerToString(bytes []byte) string {
+// quoteByte returns b as it would be written in a quoted string. Common
+// whitespace uses the short escapes, while other control characters and all
+// bytes above 0x7f, which may not be valid UTF-8, are hex escaped. The output is
+// then printable ASCII, safe to write to a terminal.
+func quoteByte(b byte) string {
+	switch {
+	case b == '\n':
+		return `\n`
+	case b == '\t':
+		return `\t`
+	case b == '\r':
+		return `\r`
+	case b == '"':
+		return `\"`
+	case b == '\\':
+		return `\\`
+	case b >= 0x80 || !unicode.IsPrint(rune(b)):
+		return fmt.Sprintf(`\x%02x`, b)
+	}
+	return string([]byte{b})
+}
+
+// booleanToString returns the TRUE or FALSE keyword for the BOOLEAN contents in
+// bytes. Other contents are encoded as a hex literal, along with a comment to
+// write after the element, including the leading space, noting the problem.
+func booleanToString(bytes []byte) (str, comment string) {
+	if len(bytes) != 1 {
+		return bytesToHexString(bytes), " # invalid BOOLEAN"
+	}
+	switch bytes[0] {
+	case 0x00:
+		return "FALSE", ""
+	case 0xff:
+		return "TRUE", ""
+	default:
+		return bytesToHexString(bytes), " # non-canonical BOOLEAN"
+	}
+}
+
+// decodeSmallInteger decodes bytes as the contents of an INTEGER which is small
+// enough to be written in decimal.
+func decodeSmallInteger(bytes []byte) (int64, bool) {
 	v, ok := decodeInteger(bytes)
 	if ok && -100000 <= v && v <= 100000 {
+		return v, true
+	}
+	return 0, false
+}
+
+func integerToString(bytes []byte) string {
+	if v, ok := decodeSmallInteger(bytes); ok {
 		return strconv.FormatInt(v, 10)
 	}
 	return bytesToHexString(bytes)
@@ -181,103 +436,279 @@ func objectIdentifierToString(bytes []byte) string {
 	return out
 }
 
-func derToASCIIImpl(w *writer, bytes []byte, stopAtEOC bool) []byte {
-	for len(bytes) != 0 {
-		if stopAtEOC && len(bytes) >= 2 && bytes[0] == 0 && bytes[1] == 0 {
-			// Emit a `0000` in lieu of a closing base.
-			w.AddIndent(-1)
-			w.WriteLine(bytesToString(bytes[:2]))
-			return bytes[2:]
-		}
+// timeComment returns a comment with the time in bytes, which are the contents of
+// an element of the named tag, including the leading space. If the tag is not
+// a time type or bytes is not a valid time, it returns the empty string.
+func timeComment(name string, bytes []byte) string {
+	var t time.Time
+	var err error
+	switch name {
+	case "UTCTime":
+		t, err = lib.ParseUTCTime(string(bytes))
+	case "GeneralizedTime":
+		t, err = lib.ParseGeneralizedTime(string(bytes))
+	default:
+		return ""
+	}
+	if err != nil {
+		return ""
+	}
+	return " # " + t.Format("2006-01-02 15:04:05.999999999 UTC")
+}
+
+// objectIdentifierComment returns a comment naming the OID in bytes, including
+// the leading space, or the empty string if the OID is not well-known.
+func objectIdentifierComment(bytes []byte) string {
+	oid, ok := decodeObjectIdentifier(bytes)
+	if !ok {
+		return ""
+	}
+	name, ok := lib.OIDName(oid)
+	if !ok {
+		return ""
+	}
+	return " # " + name
+}
+
+// headerComment returns a comment listing the bytes of header, including the
+// leading space.
+func headerComment(header []byte) string {
+	hexBytes := make([]string, len(header))
+	for i, b := range header {
+		hexBytes[i] = fmt.Sprintf("%02x", b)
+	}
+	return " # " + strings.Join(hexBytes, " ")
+}
 
-		tag, body, indefinite, rest, ok := parseElement(bytes)
+// keyUsageComment returns a comment naming the bits set in bytes, the contents
+// of a KeyUsage BIT STRING, including the leading space. It returns the empty
+// string if bytes is not a valid BIT STRING or has no bits set.
+func keyUsageComment(bytes []byte) string {
+	if len(bytes) == 0 || bytes[0] > 7 || (len(bytes) == 1 && bytes[0] != 0) {
+		return ""
+	}
+	numBits := (len(bytes)-1)*8 - int(bytes[0])
+	var names []string
+	for i := 0; i < numBits; i++ {
+		if bytes[1+i/8]&(0x80>>uint(i%8)) == 0 {
+			continue
+		}
+		name, ok := lib.KeyUsageBitName(i)
 		if !ok {
-			// Nothing more to encode. Write the rest as bytes.
-			w.WriteLine(bytesToString(bytes))
-			return nil
+			name = fmt.Sprintf("bit %d", i)
 		}
-		bytes = rest
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	return " # " + strings.Join(names, ", ")
+}
 
-		if indefinite {
-			// Emit a `80` in lieu of an open brace.
-			w.WriteLine(fmt.Sprintf("%s `80`", tagToString(tag)))
-			indent := w.Indent()
-			w.AddIndent(1)
-			bytes = derToASCIIImpl(w, bytes, true)
-			// If EOC was missing, the indent may not have been
-			// restored correctly.
-			w.SetIndent(indent)
-			continue
+// guessedNestingComment is appended to the opening brace of a primitive element
+// whose contents were heuristically decoded as nested DER.
+const guessedNestingComment = " # guessed nesting"
+
+// maxDepthComment is appended to the contents of elements at Options.MaxDepth.
+const maxDepthComment = " # max depth reached"
+
+// maxLimitedIndent is the most levels of indentation used for the contents of
+// elements at Options.MaxDepth. Those contents may be most of the input, so
+// indenting every line to the full depth would multiply the output size.
+const maxLimitedIndent = 16
+
+// writeElements writes elems to w.
+func writeElements(w *writer, opts *Options, elems []*element) {
+	for _, elem := range elems {
+		writeElement(w, opts, elem)
+	}
+}
+
+// writeElement writes elem to w.
+func writeElement(w *writer, opts *Options, elem *element) {
+	if elem.raw != nil {
+		if elem.depthLimited {
+			w.comment = maxDepthComment
+			if indent := w.Indent(); indent > maxLimitedIndent {
+				w.SetIndent(maxLimitedIndent)
+				defer w.SetIndent(indent)
+			}
+		}
+		writeBytes(w, opts, elem.raw)
+		return
+	}
+	if opts.ShowHeader {
+		w.comment = headerComment(elem.header)
+	}
+	if line, closing, ok := openLine(opts, elem); ok {
+		w.WriteLine(line)
+		w.AddIndent(1)
+		if len(elem.prefix) != 0 {
+			w.WriteLine(bytesToString(elem.prefix))
+		}
+		writeElements(w, opts, elem.children)
+		w.AddIndent(-1)
+		if closing {
+			w.WriteLine("}")
+		}
+		return
+	}
+	tag := elementTagString(opts, elem)
+	if len(elem.body) == 0 {
+		// If the body is empty, skip the newlines.
+		w.WriteLine(fmt.Sprintf("%s {}", tag))
+		return
+	}
+	writePrimitive(w, opts, elem, tag)
+}
+
+// elementTagString returns the tag of elem as written in DER ASCII, including
+// any length modifier.
+func elementTagString(opts *Options, elem *element) string {
+	tag := opts.tagString(elem.tag)
+	if elem.longForm != 0 {
+		tag += fmt.Sprintf(" long-form(%d)", elem.longForm)
+	}
+	return tag
+}
+
+// openLine returns the line which begins elem, if elem is written with its
+// children on separate lines. If so, it also returns whether a closing brace
+// follows the children. Otherwise it returns false.
+func openLine(opts *Options, elem *element) (line string, closing, ok bool) {
+	if elem.raw != nil {
+		return "", false, false
+	}
+	tag := elementTagString(opts, elem)
+	switch {
+	case elem.indefinite && elem.missingEOC:
+		// Emit a `80` in lieu of an open brace.
+		return fmt.Sprintf("%s `80`", tag), false, true
+	case elem.indefinite:
+		return fmt.Sprintf("%s indefinite {%s", tag, ofComment(opts, elem)), true, true
+	case len(elem.body) == 0:
+		return "", false, false
+	case elem.tag.Constructed || elem.guessed:
+		var comment string
+		if elem.guessed {
+			comment = guessedNestingComment
 		}
+		return fmt.Sprintf("%s {%s%s", tag, comment, ofComment(opts, elem)), true, true
+	}
+	return "", false, false
+}
 
-		if len(body) == 0 {
-			// If the body is empty, skip the newlines.
-			w.WriteLine(fmt.Sprintf("%s {}", tagToString(tag)))
-			continue
+// ofComment returns a comment naming the type of elem's children, if
+// opts.InferOf is set and elem is a SEQUENCE or SET with at least two children,
+// all with the same tag. Otherwise it returns the empty string.
+func ofComment(opts *Options, elem *element) string {
+	if !opts.InferOf || len(elem.children) < 2 {
+		return ""
+	}
+	var name string
+	switch elem.tag {
+	case lib.Tag{Class: lib.ClassUniversal, Number: 16, Constructed: true}:
+		name = "SEQUENCE"
+	case lib.Tag{Class: lib.ClassUniversal, Number: 17, Constructed: true}:
+		name = "SET"
+	default:
+		return ""
+	}
+	first := elem.children[0]
+	for _, child := range elem.children {
+		if child.raw != nil || child.tag != first.tag {
+			return ""
 		}
+	}
+	return fmt.Sprintf(" # %s OF %s", name, opts.tagString(first.tag))
+}
 
-		if tag.Constructed {
-			// If the element is constructed, recurse.
-			w.WriteLine(fmt.Sprintf("%s {", tagToString(tag)))
-			w.AddIndent(1)
-			derToASCIIImpl(w, body, false)
-			w.AddIndent(-1)
-			w.WriteLine("}")
+// writePrimitive writes elem, a primitive element with a non-empty body, to w,
+// on the same line as curly braces. The tag is written as tagStr, which may
+// include a length modifier.
+func writePrimitive(w *writer, opts *Options, elem *element, tagStr string) {
+	tag, body := elem.tag, elem.body
+	// If ok is false, name will be empty. There is also no need to check
+	// toggleConstructed as we already know the tag is primitive.
+	name, _, _ := tag.GetAlias()
+	switch name {
+	case "BOOLEAN":
+		str, comment := booleanToString(body)
+		w.WriteLine(fmt.Sprintf("%s { %s }%s", tagStr, str, comment))
+	case "INTEGER", "ENUMERATED":
+		if _, ok := decodeSmallInteger(body); ok {
+			w.WriteLine(fmt.Sprintf("%s { %s }", tagStr, integerToString(body)))
 		} else {
-			// The element is primitive. By default, emit the body
-			// on the same line as curly braces. However, in some
-			// cases, we heuristically decode the body as DER too.
-			// In this case, the newlines are inserted as in the
-			// constructed case.
-
-			// If ok is false, name will be empty. There is also no
-			// need to check toggleConstructed as we already know
-			// the tag is primitive.
-			name, _, _ := tag.GetAlias()
-			switch name {
-			case "INTEGER":
-				w.WriteLine(fmt.Sprintf("%s { %s }", tagToString(tag), integerToString(body)))
-			case "OBJECT_IDENTIFIER":
-				w.WriteLine(fmt.Sprintf("%s { %s }", tagToString(tag), objectIdentifierToString(body)))
-			case "BIT_STRING":
-				// X.509 encodes signatures and SPKIs in BIT
-				// STRINGs, so there is a 0 phase byte followed
-				// by the potentially DER-encoded structure.
-				if len(body) > 1 && body[0] == 0 && isMadeOfElements(body[1:]) {
-					w.WriteLine(fmt.Sprintf("%s {", tagToString(tag)))
-					w.AddIndent(1)
-					// Emit the phase byte.
-					w.WriteLine(bytesToString(body[:1]))
-					// Emit the remaining as a DER element.
-					derToASCIIImpl(w, body[1:], false) // Adds a trailing newline.
-					w.AddIndent(-1)
-					w.WriteLine("}")
-				} else {
-					w.WriteLine(fmt.Sprintf("%s { %s }", tagToString(tag), bytesToString(body)))
-				}
-			default:
-				// Keep parsing if the body looks like ASN.1.
-				//
-				// TODO(davidben): This is O(N^2) for deeply-
-				// nested indefinite-length encodings inside
-				// primitive elements.
-				if isMadeOfElements(body) {
-					w.WriteLine(fmt.Sprintf("%s {", tagToString(tag)))
-					w.AddIndent(1)
-					derToASCIIImpl(w, body, false)
-					w.AddIndent(-1)
-					w.WriteLine("}")
-				} else {
-					w.WriteLine(fmt.Sprintf("%s { %s }", tagToString(tag), bytesToString(body)))
-				}
-			}
+			writeBytesElement(w, opts, tagStr, body, false)
+		}
+	case "OBJECT_IDENTIFIER":
+		var comment string
+		if _, ok := decodeObjectIdentifier(body); !ok {
+			// objectIdentifierToString falls back to a hex literal.
+			comment = " # malformed OID"
+		} else if opts.OIDNames {
+			comment = objectIdentifierComment(body)
+		}
+		w.WriteLine(fmt.Sprintf("%s { %s }%s", tagStr, objectIdentifierToString(body), comment))
+	case "BIT_STRING":
+		var comment string
+		if opts.OIDNames && elem.keyUsage {
+			comment = keyUsageComment(body)
+		}
+		if comment != "" {
+			w.WriteLine(fmt.Sprintf("%s { %s }%s", tagStr, bytesToHexString(body), comment))
+		} else {
+			writeBytesElement(w, opts, tagStr, body, isMostlyPrintable(body))
+		}
+	case "NumericString", "PrintableString":
+		// ascii2der rejects characters these types do not permit in a
+		// quoted string, so those must be written as a hex literal.
+		writeBytesElement(w, opts, tagStr, body, isValidString(name, body))
+	default:
+		var comment string
+		if opts.TimeComments {
+			comment = timeComment(name, body)
+		}
+		if comment != "" {
+			w.WriteLine(fmt.Sprintf("%s { %s }%s", tagStr, bytesToQuotedString(body), comment))
+		} else {
+			writeBytesElement(w, opts, tagStr, body, isMostlyPrintable(body))
 		}
 	}
-	return nil
+}
+
+// Convert disassembles der, which is typically BER or DER, to DER ASCII with the
+// options in opts. Input which cannot be parsed is disassembled to raw byte
+// strings, so it only returns an error if opts.Strict is set and der is not a
+// single complete element.
+func Convert(der []byte, opts Options) (string, error) {
+	if opts.Strict {
+		if err := checkSingleElement(der); err != nil {
+			return "", err
+		}
+	}
+	return opts.derToASCII(der), nil
+}
+
+// ConvertJSON behaves like Convert, but returns a JSON representation of the
+// parsed elements instead of DER ASCII.
+func ConvertJSON(der []byte, opts Options) (string, error) {
+	if opts.Strict {
+		if err := checkSingleElement(der); err != nil {
+			return "", err
+		}
+	}
+	return opts.derToJSON(der), nil
 }
 
 func derToASCII(bytes []byte) string {
-	var w writer
-	derToASCIIImpl(&w, bytes, false)
+	var opts Options
+	return opts.derToASCII(bytes)
+}
+
+func (opts *Options) derToASCII(bytes []byte) string {
+	elems, _, _ := parseElements(opts, bytes, false, 0)
+	w := writer{indentUnit: opts.Indent}
+	writeElements(&w, opts, elems)
 	return w.String()
 }
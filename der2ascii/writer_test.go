@@ -12,11 +12,15 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package der2ascii
 
 import (
+	"bytes"
+	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/google/der-ascii/ascii2der"
 	"github.com/google/der-ascii/lib"
 )
 
@@ -79,13 +83,27 @@ var tagToStringTests = []struct {
 	{lib.Tag{lib.ClassApplication, 0, false}, "[APPLICATION 0 PRIMITIVE]"},
 	{lib.Tag{lib.ClassPrivate, 0, true}, "[PRIVATE 0]"},
 	{lib.Tag{lib.ClassPrivate, 0, false}, "[PRIVATE 0 PRIMITIVE]"},
+	{lib.Tag{lib.ClassContextSpecific, 31, true}, "[31]"},
+	{lib.Tag{lib.ClassApplication, 1000, false}, "[APPLICATION 1000 PRIMITIVE]"},
+	{lib.Tag{lib.ClassPrivate, 4294967295, true}, "[PRIVATE 4294967295]"},
 }
 
 func TestTagToString(t *testing.T) {
 	for i, tt := range tagToStringTests {
-		if out := tagToString(tt.in); out != tt.out {
+		if out := tagToString(tt.in, nil); out != tt.out {
 			t.Errorf("%d. tagToString(%v) = %v, want %v.", i, tt.in, out, tt.out)
 		}
+
+		// The assembler must accept the output and produce the same tag.
+		der, err := ascii2der.Convert(tt.out + " {}")
+		if err != nil {
+			t.Errorf("%d. Could not assemble %q: %s.", i, tt.out, err)
+			continue
+		}
+		tag, rest, ok := parseTag(der)
+		if !ok || tag != tt.in || !bytes.Equal(rest, []byte{0}) {
+			t.Errorf("%d. %q assembled to %x, wanted tag %v.", i, tt.out, der, tt.in)
+		}
 	}
 
 }
@@ -108,14 +126,42 @@ var bytesToStringTests = []convertFuncTest{
 	{nil, ""},
 	// Mostly-ASCII strings are encoded in ASCII.
 	{[]byte("hello\nworld\n\xff\"\\"), `"hello\nworld\n\xff\"\\"`},
+	// Whitespace uses short escapes, and other control characters and high
+	// bytes are hex escaped.
+	{[]byte("a\tb\r\nc d e f g"), `"a\tb\r\nc d e f g"`},
+	{[]byte("hello world\x00"), `"hello world\x00"`},
+	{[]byte("caf\xc3\xa9 au lait chaud"), `"caf\xc3\xa9 au lait chaud"`},
+	{[]byte("\x1b[31mred alert!"), `"\x1b[31mred alert!"`},
 	// Otherwise, encoded in hex.
 	{[]byte{0x01, 0x02, 0x03, 0x04, 0x05}, "`0102030405`"},
+	{[]byte("\x00\x00\x00ab"), "`0000006162`"},
+	{[]byte("\xff\xfe\xfdab"), "`fffefd6162`"},
 }
 
 func TestBytesToString(t *testing.T) {
 	testConvertFunc(t, "bytesToString", bytesToString, bytesToStringTests)
 }
 
+var booleanToStringTests = []struct {
+	in      []byte
+	str     string
+	comment string
+}{
+	{[]byte{0x00}, "FALSE", ""},
+	{[]byte{0xff}, "TRUE", ""},
+	// Non-canonical and invalid BOOLEANs are encoded in hex.
+	{[]byte{0x01}, "`01`", " # non-canonical BOOLEAN"},
+	{[]byte{0xff, 0xff}, "`ffff`", " # invalid BOOLEAN"},
+}
+
+func TestBooleanToString(t *testing.T) {
+	for i, tt := range booleanToStringTests {
+		if str, comment := booleanToString(tt.in); str != tt.str || comment != tt.comment {
+			t.Errorf("%d. booleanToString(%v) = %q, %q, want %q, %q.", i, tt.in, str, comment, tt.str, tt.comment)
+		}
+	}
+}
+
 var integerToStringTests = []convertFuncTest{
 	// Valid and reasonably-sized integers are encoded as integers.
 	{[]byte{42}, "42"},
@@ -149,11 +195,11 @@ var derToASCIITests = []convertFuncTest{
 		`SEQUENCE {
   "garbage"
 }
-OCTET_STRING {
-  [0] ` + "`80`" + `
+OCTET_STRING { # guessed nesting
+  [0] indefinite {
     INTEGER { 1 }
     INTEGER { -1 }
-  ` + "`0000`" + `
+  }
 }
 OCTET_STRING { "hello world" }
 SEQUENCE {
@@ -163,15 +209,60 @@ SEQUENCE {
     SEQUENCE ` + "`80`" + `
       NULL {}
       OBJECT_IDENTIFIER { 1.2.3.4 }
-      OBJECT_IDENTIFIER { ` + "`8000`" + ` }
+      OBJECT_IDENTIFIER { ` + "`8000`" + ` } # malformed OID
 }
-BIT_STRING {
+BIT_STRING { # guessed nesting
   ` + "`00`" + `
   SEQUENCE {}
 }
 BIT_STRING { ` + "`000000`" + ` }
 BIT_STRING { ` + "`0130800000`" + ` }
 ` + "`ffffffff`" + `
+`,
+	},
+	// Unknown tags keep their class and constructed bit.
+	{
+		[]byte{0xa5, 0x03, 0x02, 0x01, 0x01, 0x85, 0x02, 0xab, 0xcd, 0x5f, 0x82, 0x00, 0x02, 0xab, 0xcd},
+		"[5] {\n  INTEGER { 1 }\n}\n[5 PRIMITIVE] { `abcd` }\n[APPLICATION 256 PRIMITIVE] { `abcd` }\n",
+	},
+	// Non-minimal lengths are preserved.
+	{
+		[]byte{0x04, 0x82, 0x00, 0x03, 0x61, 0x62, 0x63, 0x30, 0x81, 0x03, 0x02, 0x01, 0x01, 0x05, 0x81, 0x00},
+		`OCTET_STRING long-form(2) { "abc" }
+SEQUENCE long-form(1) {
+  INTEGER { 1 }
+}
+NULL long-form(1) {}
+`,
+	},
+	// Malformed OIDs, such as one truncated in the middle of an arc, are
+	// written as hex.
+	{
+		[]byte{0x30, 0x06, 0x06, 0x02, 0x2a, 0x86, 0x05, 0x00},
+		"SEQUENCE {\n  OBJECT_IDENTIFIER { `2a86` } # malformed OID\n  NULL {}\n}\n",
+	},
+	// ENUMERATED is decoded like INTEGER.
+	{
+		[]byte{0x0a, 0x01, 0x05, 0x0a, 0x02, 0xff, 0x7f, 0x0a, 0x02, 0x00, 0x05, 0x0a, 0x00},
+		"ENUMERATED { 5 }\nENUMERATED { -129 }\nENUMERATED { `0005` }\nENUMERATED {}\n",
+	},
+	// NumericStrings and PrintableStrings with other characters use a hex literal, so they
+	// reassemble.
+	{
+		[]byte{0x12, 0x05, 0x31, 0x32, 0x20, 0x33, 0x34, 0x12, 0x02, 0x31, 0x61},
+		"NumericString { \"12 34\" }\nNumericString { `3161` }\n",
+	},
+	{
+		[]byte{0x13, 0x03, 0x41, 0x2d, 0x3f, 0x13, 0x03, 0x61, 0x40, 0x62},
+		"PrintableString { \"A-?\" }\nPrintableString { `614062` }\n",
+	},
+	// A BER constructed, indefinite-length OCTET STRING.
+	{
+		[]byte{0x24, 0x80, 0x04, 0x03, 0x61, 0x62, 0x63, 0x04, 0x03, 0x64, 0x65, 0x66, 0x00, 0x00},
+		`[OCTET_STRING CONSTRUCTED] indefinite {
+  OCTET_STRING { "abc" }
+  OCTET_STRING { "def" }
+}
 `,
 	},
 }
@@ -179,3 +270,580 @@ BIT_STRING { ` + "`0130800000`" + ` }
 func TestDERToASCII(t *testing.T) {
 	testConvertFunc(t, "derToASCII", derToASCII, derToASCIITests)
 }
+
+var roundTripTests = [][]byte{
+	// A BER constructed, indefinite-length OCTET STRING.
+	{0x24, 0x80, 0x04, 0x03, 0x61, 0x62, 0x63, 0x04, 0x03, 0x64, 0x65, 0x66, 0x00, 0x00},
+	// Nested indefinite-length elements.
+	{0x30, 0x80, 0xa0, 0x80, 0x02, 0x01, 0x01, 0x00, 0x00, 0x30, 0x80, 0x00, 0x00, 0x00, 0x00},
+	// An indefinite-length element missing its EOC.
+	{0x30, 0x80, 0x02, 0x01, 0x01},
+	// BOOLEANs, including non-canonical ones.
+	{0x01, 0x01, 0xff, 0x01, 0x01, 0x00, 0x01, 0x01, 0x01, 0x01, 0x02, 0xff, 0xff},
+	// A two-byte long-form length on a three-byte value.
+	{0x04, 0x82, 0x00, 0x03, 0x61, 0x62, 0x63},
+	// NULLs with non-minimal lengths.
+	{0x05, 0x81, 0x00},
+	{0x05, 0x82, 0x00, 0x00},
+	// Non-minimal lengths inside a guessed nesting.
+	{0x04, 0x04, 0x02, 0x81, 0x01, 0x01},
+	// High tag numbers.
+	{0x9e, 0x00, 0x9f, 0x1f, 0x00, 0x9f, 0x7f, 0x00, 0x9f, 0x81, 0x00, 0x00, 0x9f, 0xff, 0x7f, 0x00},
+	{0xff, 0x83, 0x74, 0x00, 0x1f, 0x8f, 0xff, 0xff, 0xff, 0x7f, 0x00},
+	// ENUMERATEDs, including a non-minimal one.
+	{0x0a, 0x01, 0x05, 0x0a, 0x02, 0xff, 0x7f, 0x0a, 0x02, 0x00, 0x05},
+	// Strings with control characters and high bytes.
+	append([]byte{0x04, 0x10}, "a\tb\r\n\x00\x1b\xc3\xa9\xff\"\\cdef"...),
+	append([]byte{0x0c, 0x0c}, "hello world\x00"...),
+	// NumericStrings and PrintableStrings with invalid characters.
+	{0x12, 0x03, 0x31, 0x32, 0x61},
+	{0x13, 0x03, 0x61, 0x40, 0x62},
+	// The sample input from derToASCIITests.
+	derToASCIITests[0].in,
+}
+
+func TestRoundTrip(t *testing.T) {
+	for i, in := range roundTripTests {
+		ascii := derToASCII(in)
+		out, err := ascii2der.Convert(ascii)
+		if err != nil {
+			t.Errorf("%d. Could not assemble %q: %s.", i, ascii, err)
+		} else if !bytes.Equal(out, in) {
+			t.Errorf("%d. %q assembled to %x, wanted %x.", i, ascii, out, in)
+		}
+	}
+}
+
+func TestASCIIOutput(t *testing.T) {
+	// A UTF8String with mostly-printable UTF-8, written as a quoted string,
+	// and an OCTET STRING with every byte value.
+	var all []byte
+	for i := 0; i < 256; i++ {
+		all = append(all, byte(i))
+	}
+	for _, in := range [][]byte{
+		append([]byte{0x0c, 0x2c}, "na\xc3\xafve caf\xc3\xa9 au lait chaud, s'il vous pla\xc3\xaet"...),
+		append([]byte{0x04, 0x82, 0x01, 0x00}, all...),
+	} {
+		ascii := derToASCII(in)
+		for i := 0; i < len(ascii); i++ {
+			if ascii[i] >= 0x80 {
+				t.Errorf("derToASCII(%x) = %q, which is not 7-bit ASCII.", in, ascii)
+				break
+			}
+		}
+		out, err := ascii2der.Convert(ascii)
+		if err != nil {
+			t.Errorf("Could not assemble %q: %s.", ascii, err)
+		} else if !bytes.Equal(out, in) {
+			t.Errorf("%q assembled to %x, wanted %x.", ascii, out, in)
+		}
+	}
+}
+
+func TestStringTagRoundTrip(t *testing.T) {
+	names := []string{
+		"UTF8String", "NumericString", "PrintableString", "T61String",
+		"VideotexString", "IA5String", "GraphicString", "VisibleString",
+		"GeneralString", "UniversalString", "CHARACTER_STRING", "BMPString",
+	}
+	for _, name := range names {
+		in := name + " { \"123\" }\n"
+		der, err := ascii2der.Convert(in)
+		if err != nil {
+			t.Errorf("Could not assemble %q: %s.", in, err)
+			continue
+		}
+		if out := derToASCII(der); out != in {
+			t.Errorf("%q disassembled to %q, wanted %q.", der, out, in)
+		}
+	}
+
+	// Alternate names disassemble to the canonical name.
+	der, err := ascii2der.Convert(`TeletexString { "abc" }`)
+	if err != nil {
+		t.Fatalf("Could not assemble TeletexString: %s.", err)
+	}
+	if out, want := derToASCII(der), "T61String { \"abc\" }\n"; out != want {
+		t.Errorf("%q disassembled to %q, wanted %q.", der, out, want)
+	}
+}
+
+func TestOIDNames(t *testing.T) {
+	// SEQUENCE { rsaEncryption, 1.2.3, NULL }
+	in := []byte{0x30, 0x11, 0x06, 0x09, 0x2a, 0x86, 0x48, 0x86, 0xf7, 0x0d, 0x01, 0x01, 0x01, 0x06, 0x02, 0x2a, 0x03, 0x05, 0x00}
+	want := `SEQUENCE {
+  OBJECT_IDENTIFIER { 1.2.840.113549.1.1.1 } # rsaEncryption
+  OBJECT_IDENTIFIER { 1.2.3 }
+  NULL {}
+}
+`
+	opts := Options{OIDNames: true}
+	ascii := opts.derToASCII(in)
+	if ascii != want {
+		t.Errorf("derToASCII(%x) with OID names = %q, wanted %q.", in, ascii, want)
+	}
+
+	// The comments must not break assembling the output.
+	out, err := ascii2der.Convert(ascii)
+	if err != nil {
+		t.Errorf("Could not assemble %q: %s.", ascii, err)
+	} else if !bytes.Equal(out, in) {
+		t.Errorf("%q assembled to %x, wanted %x.", ascii, out, in)
+	}
+}
+
+func TestKeyUsageComments(t *testing.T) {
+	in, err := ascii2der.Convert(`SEQUENCE {
+  OBJECT_IDENTIFIER { keyUsage }
+  BOOLEAN { TRUE }
+  OCTET_STRING { BIT_STRING { key-usage(digitalSignature, keyCertSign) } }
+}
+SEQUENCE {
+  OBJECT_IDENTIFIER { keyUsage }
+  OCTET_STRING { BIT_STRING { bits("0000000011") } }
+}
+SEQUENCE {
+  OBJECT_IDENTIFIER { 1.2.3 }
+  OCTET_STRING { BIT_STRING { key-usage(digitalSignature) } }
+}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `SEQUENCE {
+  OBJECT_IDENTIFIER { 2.5.29.15 } # keyUsage
+  BOOLEAN { TRUE }
+  OCTET_STRING { # guessed nesting
+    BIT_STRING { ` + "`0284`" + ` } # digitalSignature, keyCertSign
+  }
+}
+SEQUENCE {
+  OBJECT_IDENTIFIER { 2.5.29.15 } # keyUsage
+  OCTET_STRING { # guessed nesting
+    BIT_STRING { ` + "`0600c0`" + ` } # decipherOnly, bit 9
+  }
+}
+SEQUENCE {
+  OBJECT_IDENTIFIER { 1.2.3 }
+  OCTET_STRING { # guessed nesting
+    BIT_STRING { ` + "`0780`" + ` }
+  }
+}
+`
+	opts := Options{OIDNames: true}
+	ascii := opts.derToASCII(in)
+	if ascii != want {
+		t.Errorf("derToASCII(%x) with OID names = %q, wanted %q.", in, ascii, want)
+	}
+
+	out, err := ascii2der.Convert(ascii)
+	if err != nil {
+		t.Errorf("Could not assemble %q: %s.", ascii, err)
+	} else if !bytes.Equal(out, in) {
+		t.Errorf("%q assembled to %x, wanted %x.", ascii, out, in)
+	}
+}
+
+func TestNoRecurse(t *testing.T) {
+	// OCTET_STRING { SEQUENCE {} } BIT_STRING { `00` SEQUENCE {} }
+	in := []byte{0x04, 0x02, 0x30, 0x00, 0x03, 0x03, 0x00, 0x30, 0x00}
+	want := "OCTET_STRING { `3000` }\nBIT_STRING { `003000` }\n"
+	opts := Options{NoRecurse: true}
+	if ascii := opts.derToASCII(in); ascii != want {
+		t.Errorf("derToASCII(%x) without recursion = %q, wanted %q.", in, ascii, want)
+	}
+
+	// Both forms must assemble back to the input.
+	for _, opts := range []Options{{}, {NoRecurse: true}} {
+		ascii := opts.derToASCII(in)
+		out, err := ascii2der.Convert(ascii)
+		if err != nil {
+			t.Errorf("Could not assemble %q: %s.", ascii, err)
+		} else if !bytes.Equal(out, in) {
+			t.Errorf("%q assembled to %x, wanted %x.", ascii, out, in)
+		}
+	}
+}
+
+// nestedSequences returns n nested SEQUENCEs, with definite lengths if
+// indefinite is false.
+func nestedSequences(n int, indefinite bool) []byte {
+	if indefinite {
+		return append(bytes.Repeat([]byte{0x30, 0x80}, n), make([]byte, 2*n)...)
+	}
+	var der []byte
+	for i := 0; i < n; i++ {
+		der = append(lib.AppendLength([]byte{0x30}, len(der)), der...)
+	}
+	return der
+}
+
+var maxDepthTests = []struct {
+	in       []byte
+	maxDepth int
+	out      string
+}{
+	{[]byte{0x30, 0x06, 0x30, 0x04, 0x30, 0x02, 0x05, 0x00}, 2, "SEQUENCE {\n  SEQUENCE {\n    `30020500` # max depth reached\n  }\n}\n"},
+	{[]byte{0x30, 0x06, 0x30, 0x04, 0x30, 0x02, 0x05, 0x00}, 3, "SEQUENCE {\n  SEQUENCE {\n    SEQUENCE {\n      `0500` # max depth reached\n    }\n  }\n}\n"},
+	{nestedSequences(3, true), 2, "SEQUENCE indefinite {\n  SEQUENCE indefinite {\n    `30800000` # max depth reached\n  }\n}\n"},
+	{[]byte{0x30, 0x80, 0x05, 0x00}, 1, "SEQUENCE `80`\n  `0500` # max depth reached\n"},
+	// Empty elements need no comment.
+	{[]byte{0x30, 0x02, 0x30, 0x00, 0x30, 0x80, 0x00, 0x00}, 1, "SEQUENCE {\n  `3000` # max depth reached\n}\nSEQUENCE indefinite {\n}\n"},
+	// Primitive elements at the limit are not parsed as nested DER.
+	{[]byte{0x04, 0x02, 0x05, 0x00}, 1, "OCTET_STRING { `0500` }\n"},
+}
+
+func TestMaxDepth(t *testing.T) {
+	for i, tt := range maxDepthTests {
+		opts := Options{MaxDepth: tt.maxDepth}
+		if ascii := opts.derToASCII(tt.in); ascii != tt.out {
+			t.Errorf("%d. derToASCII(%x) with MaxDepth %d = %q, wanted %q.", i, tt.in, tt.maxDepth, ascii, tt.out)
+		}
+	}
+
+	// Nesting beyond the default limit is not parsed, and the output can
+	// still be reassembled.
+	for _, indefinite := range []bool{false, true} {
+		in := nestedSequences(DefaultMaxDepth+10, indefinite)
+		ascii := derToASCII(in)
+		if strings.Count(ascii, maxDepthComment) != 1 {
+			t.Errorf("Output for %d nested SEQUENCEs did not note the depth limit once.", DefaultMaxDepth+10)
+		}
+		out, err := ascii2der.Convert(ascii)
+		if err != nil {
+			t.Errorf("Could not assemble output for %d nested SEQUENCEs: %s.", DefaultMaxDepth+10, err)
+		} else if !bytes.Equal(out, in) {
+			t.Errorf("Output for %d nested SEQUENCEs did not round-trip.", DefaultMaxDepth+10)
+		}
+	}
+}
+
+func TestDeepOutputSize(t *testing.T) {
+	// Deep indentation past the wrap column must not put each byte on its
+	// own line.
+	octets := lib.AppendLength([]byte{0x04}, 1024)
+	octets = append(octets, bytes.Repeat([]byte{0xff}, 1024)...)
+	in := octets
+	for i := 0; i < 60; i++ {
+		in = append(lib.AppendLength([]byte{0x30}, len(in)), in...)
+	}
+	if ascii := derToASCII(in); len(ascii) > 32<<10 {
+		t.Errorf("Output for a deeply nested OCTET STRING was %d bytes.", len(ascii))
+	}
+
+	// Contents past the depth limit are not indented to the full depth.
+	in = nestedSequences(20000, true)
+	ascii := derToASCII(in)
+	if len(ascii) > 4<<20 {
+		t.Errorf("Output for %d nested SEQUENCEs was %d bytes.", 20000, len(ascii))
+	}
+	if out, err := ascii2der.Convert(ascii); err != nil || !bytes.Equal(out, in) {
+		t.Errorf("Output for %d nested SEQUENCEs did not round-trip: %v", 20000, err)
+	}
+}
+
+func TestTagTable(t *testing.T) {
+	var table lib.TagTable
+	for name, tag := range map[string]lib.Tag{
+		"GetRequest": {lib.ClassContextSpecific, 0, true},
+		"Trap":       {lib.ClassApplication, 4, false},
+		// [1] has two names, so neither is used.
+		"Realm": {lib.ClassContextSpecific, 1, true},
+		"Till":  {lib.ClassContextSpecific, 1, true},
+	} {
+		if err := table.Add(name, tag); err != nil {
+			t.Fatalf("Add(%q) failed: %s", name, err)
+		}
+	}
+	in := []byte{0xa0, 0x09, 0x44, 0x01, 0x01, 0x80, 0x00, 0xa1, 0x00, 0x30, 0x00}
+	want := "GetRequest {\n  Trap { `01` }\n  [GetRequest PRIMITIVE] {}\n  [1] {}\n  SEQUENCE {}\n}\n"
+	opts := Options{TagTable: &table}
+	ascii := opts.derToASCII(in)
+	if ascii != want {
+		t.Errorf("derToASCII(%x) with a tag table = %q, wanted %q.", in, ascii, want)
+	}
+
+	out, err := ascii2der.Options{TagTable: &table}.Convert(ascii)
+	if err != nil {
+		t.Errorf("Could not assemble %q: %s.", ascii, err)
+	} else if !bytes.Equal(out, in) {
+		t.Errorf("%q assembled to %x, wanted %x.", ascii, out, in)
+	}
+}
+
+func TestShowHeader(t *testing.T) {
+	// A non-minimal length, a guessed nesting, and indefinite and empty
+	// elements.
+	in := []byte{0x30, 0x82, 0x00, 0x0d, 0x02, 0x01, 0x05, 0x04, 0x02, 0x30, 0x00, 0x30, 0x80, 0x05, 0x00, 0x00, 0x00}
+	want := `SEQUENCE long-form(2) { # 30 82 00 0d
+  INTEGER { 5 } # 02 01
+  OCTET_STRING { # guessed nesting # 04 02
+    SEQUENCE {} # 30 00
+  }
+  SEQUENCE indefinite { # 30 80
+    NULL {} # 05 00
+  }
+}
+`
+	opts := Options{ShowHeader: true}
+	ascii := opts.derToASCII(in)
+	if ascii != want {
+		t.Errorf("derToASCII(%x) with headers = %q, wanted %q.", in, ascii, want)
+	}
+
+	out, err := ascii2der.Convert(ascii)
+	if err != nil {
+		t.Errorf("Could not assemble %q: %s.", ascii, err)
+	} else if !bytes.Equal(out, in) {
+		t.Errorf("%q assembled to %x, wanted %x.", ascii, out, in)
+	}
+}
+
+var inferOfTests = []struct {
+	in  []byte
+	out string
+}{
+	// SEQUENCE { INTEGER { 1 } INTEGER { 2 } }
+	{[]byte{0x30, 0x06, 0x02, 0x01, 0x01, 0x02, 0x01, 0x02}, "SEQUENCE { # SEQUENCE OF INTEGER\n  INTEGER { 1 }\n  INTEGER { 2 }\n}\n"},
+	// SET indefinite { SEQUENCE {} SEQUENCE {} }
+	{[]byte{0x31, 0x80, 0x30, 0x00, 0x30, 0x00, 0x00, 0x00}, "SET indefinite { # SET OF SEQUENCE\n  SEQUENCE {}\n  SEQUENCE {}\n}\n"},
+	// SEQUENCE { [0] {} [0] {} }
+	{[]byte{0x30, 0x04, 0xa0, 0x00, 0xa0, 0x00}, "SEQUENCE { # SEQUENCE OF [0]\n  [0] {}\n  [0] {}\n}\n"},
+	// Children with different tags, a single child, trailing data,
+	// and other constructed types are not annotated.
+	{[]byte{0x30, 0x05, 0x02, 0x01, 0x01, 0x05, 0x00}, "SEQUENCE {\n  INTEGER { 1 }\n  NULL {}\n}\n"},
+	{[]byte{0x30, 0x04, 0xa0, 0x00, 0x80, 0x00}, "SEQUENCE {\n  [0] {}\n  [0 PRIMITIVE] {}\n}\n"},
+	{[]byte{0x30, 0x03, 0x02, 0x01, 0x01}, "SEQUENCE {\n  INTEGER { 1 }\n}\n"},
+	{[]byte{0x30, 0x05, 0x02, 0x01, 0x01, 0x02, 0x05}, "SEQUENCE {\n  INTEGER { 1 }\n  `0205`\n}\n"},
+	{[]byte{0xa0, 0x04, 0x05, 0x00, 0x05, 0x00}, "[0] {\n  NULL {}\n  NULL {}\n}\n"},
+}
+
+func TestInferOf(t *testing.T) {
+	for i, tt := range inferOfTests {
+		opts := Options{InferOf: true}
+		ascii := opts.derToASCII(tt.in)
+		if ascii != tt.out {
+			t.Errorf("%d. derToASCII(%x) with InferOf = %q, wanted %q.", i, tt.in, ascii, tt.out)
+		}
+		out, err := ascii2der.Convert(ascii)
+		if err != nil {
+			t.Errorf("%d. Could not assemble %q: %s.", i, ascii, err)
+		} else if !bytes.Equal(out, tt.in) {
+			t.Errorf("%d. %q assembled to %x, wanted %x.", i, ascii, out, tt.in)
+		}
+	}
+}
+
+var numericTagsTests = []struct {
+	in  []byte
+	out string
+}{
+	// SEQUENCE { INTEGER { 1 } UTF8String { "a" } }
+	{[]byte{0x30, 0x06, 0x02, 0x01, 0x01, 0x0c, 0x01, 0x61}, "[UNIVERSAL 16] {\n  [UNIVERSAL 2 PRIMITIVE] { 1 }\n  [UNIVERSAL 12 PRIMITIVE] { \"a\" }\n}\n"},
+	// [0] { NULL {} } [APPLICATION 1 PRIMITIVE] {}
+	{[]byte{0xa0, 0x02, 0x05, 0x00, 0x41, 0x00}, "[0] {\n  [UNIVERSAL 5 PRIMITIVE] {}\n}\n[APPLICATION 1 PRIMITIVE] {}\n"},
+	// OCTET_STRING { SEQUENCE {} }, which is still decoded as nested DER.
+	{[]byte{0x04, 0x02, 0x30, 0x00}, "[UNIVERSAL 4 PRIMITIVE] { # guessed nesting\n  [UNIVERSAL 16] {}\n}\n"},
+	// SEQUENCE indefinite { [SEQUENCE PRIMITIVE] {} }
+	{[]byte{0x30, 0x80, 0x10, 0x00, 0x00, 0x00}, "[UNIVERSAL 16] indefinite {\n  [UNIVERSAL 16 PRIMITIVE] {}\n}\n"},
+}
+
+func TestNumericTags(t *testing.T) {
+	for i, tt := range numericTagsTests {
+		opts := Options{NumericTags: true}
+		ascii := opts.derToASCII(tt.in)
+		if ascii != tt.out {
+			t.Errorf("%d. derToASCII(%x) with NumericTags = %q, wanted %q.", i, tt.in, ascii, tt.out)
+		}
+		out, err := ascii2der.Convert(ascii)
+		if err != nil {
+			t.Errorf("%d. Could not assemble %q: %s.", i, ascii, err)
+		} else if !bytes.Equal(out, tt.in) {
+			t.Errorf("%d. %q assembled to %x, wanted %x.", i, ascii, out, tt.in)
+		}
+	}
+}
+
+var indentTests = []struct {
+	indent string
+	out    string
+}{
+	{"", "SEQUENCE {\n  SEQUENCE {\n    INTEGER { 1 }\n  }\n  [0] indefinite {\n    NULL {}\n  }\n}\n"},
+	{"    ", "SEQUENCE {\n    SEQUENCE {\n        INTEGER { 1 }\n    }\n    [0] indefinite {\n        NULL {}\n    }\n}\n"},
+	{"\t", "SEQUENCE {\n\tSEQUENCE {\n\t\tINTEGER { 1 }\n\t}\n\t[0] indefinite {\n\t\tNULL {}\n\t}\n}\n"},
+}
+
+func TestIndent(t *testing.T) {
+	// SEQUENCE { SEQUENCE { INTEGER { 1 } } [0] indefinite { NULL {} } }
+	in := []byte{0x30, 0x0b, 0x30, 0x03, 0x02, 0x01, 0x01, 0xa0, 0x80, 0x05, 0x00, 0x00, 0x00}
+	for i, tt := range indentTests {
+		opts := Options{Indent: tt.indent}
+		if out := opts.derToASCII(in); out != tt.out {
+			t.Errorf("%d. derToASCII(%x) with indent %q = %q, wanted %q.", i, in, tt.indent, out, tt.out)
+		}
+	}
+}
+
+var splitBytesTests = []struct {
+	in     []byte
+	width  int
+	quoted bool
+	out    []string
+}{
+	{[]byte{}, 10, false, []string{"``"}},
+	{[]byte{1, 2, 3, 4}, 10, false, []string{"`01020304`"}},
+	{[]byte{1, 2, 3, 4, 5}, 10, false, []string{"`01020304`", "`05`"}},
+	{[]byte{1, 2, 3}, 1, false, []string{"`01`", "`02`", "`03`"}},
+	{[]byte("abcdefgh"), 6, true, []string{`"abcd"`, `"efgh"`}},
+	{[]byte("ab\ncd"), 6, true, []string{`"ab\n"`, `"cd"`}},
+	{[]byte("\x80\x81"), 4, true, []string{`"\x80"`, `"\x81"`}},
+}
+
+func TestSplitBytes(t *testing.T) {
+	for i, tt := range splitBytesTests {
+		if out := splitBytes(tt.in, tt.width, tt.quoted); !reflect.DeepEqual(out, tt.out) {
+			t.Errorf("%d. splitBytes(%x, %d, %v) = %q, wanted %q.", i, tt.in, tt.width, tt.quoted, out, tt.out)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	long := bytes.Repeat([]byte{0xaa}, 40)
+	text := []byte(strings.Repeat("hello world ", 8))
+	// SEQUENCE { OCTET_STRING { long } INTEGER { 00 long } UTF8String { text } } long
+	var in []byte
+	in = append(in, 0x30, 0x81, 2+40+2+41+2+byte(len(text)))
+	in = append(in, 0x04, 40)
+	in = append(in, long...)
+	in = append(in, 0x02, 41, 0x00)
+	in = append(in, long...)
+	in = append(in, 0x0c, byte(len(text)))
+	in = append(in, text...)
+	in = append(in, long...)
+
+	want := "SEQUENCE {\n" +
+		"  OCTET_STRING {\n" +
+		"    `" + strings.Repeat("aa", 37) + "`\n" +
+		"    `aaaaaa`\n" +
+		"  }\n" +
+		"  INTEGER {\n" +
+		"    `00" + strings.Repeat("aa", 36) + "`\n" +
+		"    `aaaaaaaa`\n" +
+		"  }\n" +
+		"  UTF8String {\n" +
+		"    \"hello world hello world hello world hello world hello world hello world he\"\n" +
+		"    \"llo world hello world \"\n" +
+		"  }\n" +
+		"}\n" +
+		"`" + strings.Repeat("aa", 39) + "`\n" +
+		"`aa`\n"
+
+	for _, opts := range []Options{{}, {Wrap: 80}, {Wrap: -1}, {Wrap: 40, Indent: "\t"}} {
+		ascii := opts.derToASCII(in)
+		if opts.Wrap >= 0 && opts.Indent == "" && ascii != want {
+			t.Errorf("derToASCII(%x) with wrap %d = %q, wanted %q.", in, opts.Wrap, ascii, want)
+		}
+		wrapColumn := opts.wrapColumn()
+		for _, line := range strings.Split(strings.TrimSuffix(ascii, "\n"), "\n") {
+			if width := len(strings.Replace(line, "\t", "        ", -1)); wrapColumn > 0 && width > wrapColumn {
+				t.Errorf("Line %q with wrap %d has width %d.", line, wrapColumn, width)
+			}
+		}
+
+		// The wrapped output must assemble back to the input.
+		out, err := ascii2der.Convert(ascii)
+		if err != nil {
+			t.Errorf("Could not assemble %q: %s.", ascii, err)
+		} else if !bytes.Equal(out, in) {
+			t.Errorf("%q assembled to %x, wanted %x.", ascii, out, in)
+		}
+	}
+}
+
+func TestTimeComments(t *testing.T) {
+	var in []byte
+	for _, elem := range []struct {
+		tag   byte
+		value string
+	}{
+		{0x17, "170101000000Z"},
+		{0x18, "20170101000000.25Z"},
+		// Invalid times are not annotated.
+		{0x17, "171301000000Z"},
+		{0x18, "2017"},
+	} {
+		in = append(in, elem.tag, byte(len(elem.value)))
+		in = append(in, elem.value...)
+	}
+	want := `UTCTime { "170101000000Z" } # 2017-01-01 00:00:00 UTC
+GeneralizedTime { "20170101000000.25Z" } # 2017-01-01 00:00:00.25 UTC
+UTCTime { "171301000000Z" }
+GeneralizedTime { "2017" }
+`
+	opts := Options{TimeComments: true}
+	ascii := opts.derToASCII(in)
+	if ascii != want {
+		t.Errorf("derToASCII(%x) with time comments = %q, wanted %q.", in, ascii, want)
+	}
+
+	out, err := ascii2der.Convert(ascii)
+	if err != nil {
+		t.Errorf("Could not assemble %q: %s.", ascii, err)
+	} else if !bytes.Equal(out, in) {
+		t.Errorf("%q assembled to %x, wanted %x.", ascii, out, in)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	in := []byte{0x02, 0x01, 0x01}
+	out, err := Convert(in, Options{})
+	if err != nil || out != "INTEGER { 1 }\n" {
+		t.Errorf("Convert(%x) = %q, %v, wanted INTEGER { 1 }.", in, out, err)
+	}
+
+	// Strict mode rejects trailing data.
+	in = []byte{0x02, 0x01, 0x01, 0x00}
+	if _, err := Convert(in, Options{Strict: true}); err == nil {
+		t.Errorf("Convert(%x) in strict mode unexpectedly succeeded.", in)
+	}
+	if _, err := ConvertJSON(in, Options{Strict: true}); err == nil {
+		t.Errorf("ConvertJSON(%x) in strict mode unexpectedly succeeded.", in)
+	}
+	if _, err := Convert(in, Options{}); err != nil {
+		t.Errorf("Convert(%x) unexpectedly failed: %s.", in, err)
+	}
+}
+
+func TestUnknownTagRoundTrip(t *testing.T) {
+	classes := []lib.Class{lib.ClassUniversal, lib.ClassApplication, lib.ClassContextSpecific, lib.ClassPrivate}
+	for _, class := range classes {
+		for _, number := range []uint32{0, 5, 30, 31, 127, 128, 1000} {
+			for _, constructed := range []bool{false, true} {
+				// Encode the tag in high-tag-number form if needed.
+				b := byte(class) | byte(number)
+				if number >= 31 {
+					b = byte(class) | 0x1f
+				}
+				if constructed {
+					b |= 0x20
+				}
+				in := []byte{b}
+				switch {
+				case number >= 128:
+					in = append(in, 0x80|byte(number>>7), byte(number&0x7f))
+				case number >= 31:
+					in = append(in, byte(number))
+				}
+				in = append(in, 0x02, 0xab, 0xcd)
+
+				ascii := derToASCII(in)
+				out, err := ascii2der.Convert(ascii)
+				if err != nil {
+					t.Errorf("Could not assemble %q: %s.", ascii, err)
+				} else if !bytes.Equal(out, in) {
+					t.Errorf("%q assembled to %x, wanted %x.", ascii, out, in)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package roundtrip checks that DER disassembles and reassembles to the same
+// bytes. It is shared by the ascii2der and der2ascii tools.
+package roundtrip
+
+import (
+	"fmt"
+
+	"github.com/google/der-ascii/ascii2der"
+	"github.com/google/der-ascii/der2ascii"
+)
+
+// Check disassembles der with opts, assembles the result, and returns an error
+// describing the first divergence if the output does not match der. The result
+// is assembled with the same tag table as opts.
+func Check(der []byte, opts der2ascii.Options) error {
+	ascii, err := der2ascii.Convert(der, opts)
+	if err != nil {
+		return err
+	}
+	out, err := ascii2der.Options{TagTable: opts.TagTable}.Convert(ascii)
+	if err != nil {
+		return fmt.Errorf("could not assemble the disassembled output: %s", err)
+	}
+	return compare(der, out)
+}
+
+// compare returns an error describing the first byte where got differs from
+// want, or nil if they are equal.
+func compare(want, got []byte) error {
+	for i := 0; i < len(want) && i < len(got); i++ {
+		if want[i] != got[i] {
+			return fmt.Errorf("output differs at offset %d: got %#02x, wanted %#02x", i, got[i], want[i])
+		}
+	}
+	switch {
+	case len(got) < len(want):
+		return fmt.Errorf("output is truncated at offset %d, wanted %d bytes", len(got), len(want))
+	case len(got) > len(want):
+		return fmt.Errorf("output has %d extra bytes at offset %d", len(got)-len(want), len(want))
+	}
+	return nil
+}
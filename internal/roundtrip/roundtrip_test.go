@@ -0,0 +1,63 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package roundtrip
+
+import (
+	"testing"
+
+	"github.com/google/der-ascii/der2ascii"
+)
+
+var compareTests = []struct {
+	want, got []byte
+	err       string
+}{
+	{[]byte{1, 2, 3}, []byte{1, 2, 3}, ""},
+	{nil, nil, ""},
+	{[]byte{1, 2, 3}, []byte{1, 4, 3}, "output differs at offset 1: got 0x04, wanted 0x02"},
+	{[]byte{1, 2, 3}, []byte{1, 2}, "output is truncated at offset 2, wanted 3 bytes"},
+	{[]byte{1, 2}, []byte{1, 2, 3, 4}, "output has 2 extra bytes at offset 2"},
+}
+
+func TestCompare(t *testing.T) {
+	for i, tt := range compareTests {
+		err := compare(tt.want, tt.got)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%d. compare(%x, %x) failed: %s", i, tt.want, tt.got, err)
+			}
+		} else if err == nil || err.Error() != tt.err {
+			t.Errorf("%d. compare(%x, %x) failed with %v, wanted %q.", i, tt.want, tt.got, err, tt.err)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	// der2ascii preserves BER, including non-minimal lengths and
+	// indefinite-length elements.
+	for _, der := range [][]byte{
+		{0x30, 0x03, 0x02, 0x01, 0x01},
+		{0x30, 0x81, 0x03, 0x02, 0x01, 0x01},
+		{0x30, 0x80, 0x00, 0x00},
+		{0xff, 0xff},
+	} {
+		if err := Check(der, der2ascii.Options{}); err != nil {
+			t.Errorf("Check(%x) failed: %s", der, err)
+		}
+	}
+	if err := Check([]byte{0x30, 0x00, 0x01}, der2ascii.Options{Strict: true}); err == nil {
+		t.Errorf("Check with trailing data and Strict unexpectedly succeeded.")
+	}
+}
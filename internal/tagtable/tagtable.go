@@ -0,0 +1,43 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tagtable loads the tag tables given to the ascii2der and der2ascii
+// tools with -tag-table.
+package tagtable
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/google/der-ascii/ascii2der"
+	"github.com/google/der-ascii/lib"
+)
+
+// Load reads the tag table at path and writes any warnings to w, one per line.
+func Load(path string, w io.Writer) (*lib.TagTable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	table, warnings, err := ascii2der.ParseTagTable(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(w, "Warning: %s: %s\n", path, warning)
+	}
+	return table, nil
+}
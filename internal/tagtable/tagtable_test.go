@@ -0,0 +1,66 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tagtable
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/der-ascii/lib"
+)
+
+var loadTests = []struct {
+	in       string
+	warnings string
+	err      bool
+}{
+	{"GetRequest = [0]\n", "", false},
+	{"GetRequest = [0]\nINTEGER = [1]\n", "Warning: PATH: line 2: tag name 'INTEGER' is built in, so this entry is ignored\n", false},
+	{"GetRequest [0]\n", "", true},
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tags.txt")
+	for i, tt := range loadTests {
+		if err := ioutil.WriteFile(path, []byte(tt.in), 0666); err != nil {
+			t.Fatal(err)
+		}
+		var w bytes.Buffer
+		table, err := Load(path, &w)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%d. Load(%q) unexpectedly succeeded.", i, tt.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d. Load(%q) failed: %s", i, tt.in, err)
+			continue
+		}
+		if tag, ok := table.TagByName("GetRequest"); !ok || tag != (lib.Tag{Class: lib.ClassContextSpecific, Number: 0, Constructed: true}) {
+			t.Errorf("%d. Load(%q) gave GetRequest = %v, %v.", i, tt.in, tag, ok)
+		}
+		if want := bytes.Replace([]byte(tt.warnings), []byte("PATH"), []byte(path), -1); !bytes.Equal(w.Bytes(), want) {
+			t.Errorf("%d. Load(%q) wrote warnings %q, wanted %q.", i, tt.in, w.Bytes(), want)
+		}
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.txt"), ioutil.Discard); !os.IsNotExist(err) {
+		t.Errorf("Load of a missing file gave %v, wanted a not-exist error.", err)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lib
+
+// AppendBitString marshals the given bits as the contents of a DER BIT STRING
+// and appends the result to dst, returning the updated slice. The bits are
+// packed most significant bit first, and the final byte is padded with zeros.
+func AppendBitString(dst []byte, bits []bool) []byte {
+	unused := (8 - len(bits)%8) % 8
+	dst = append(dst, byte(unused))
+	var b byte
+	for i, bit := range bits {
+		if bit {
+			b |= 0x80 >> uint(i%8)
+		}
+		if i%8 == 7 {
+			dst = append(dst, b)
+			b = 0
+		}
+	}
+	if unused != 0 {
+		dst = append(dst, b)
+	}
+	return dst
+}
+
+// keyUsageBits contains the names of the bits in an X.509 KeyUsage, from RFC
+// 5280, section 4.2.1.3, in order.
+var keyUsageBits = []string{
+	"digitalSignature",
+	"nonRepudiation",
+	"keyEncipherment",
+	"dataEncipherment",
+	"keyAgreement",
+	"keyCertSign",
+	"cRLSign",
+	"encipherOnly",
+	"decipherOnly",
+}
+
+// KeyUsageBitByName returns the index of the KeyUsage bit with the given name or
+// false if no bit matches.
+func KeyUsageBitByName(name string) (int, bool) {
+	for i, n := range keyUsageBits {
+		if n == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// KeyUsageBitName returns the name of the KeyUsage bit at index i or false if
+// the bit is not known.
+func KeyUsageBitName(i int) (string, bool) {
+	if i < 0 || i >= len(keyUsageBits) {
+		return "", false
+	}
+	return keyUsageBits[i], true
+}
@@ -0,0 +1,75 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lib
+
+import (
+	"bytes"
+	"testing"
+)
+
+var appendBitStringTests = []struct {
+	value   string
+	encoded []byte
+}{
+	{"", []byte{0x00}},
+	{"1", []byte{0x07, 0x80}},
+	{"101101", []byte{0x02, 0xb4}},
+	{"1111111", []byte{0x01, 0xfe}},
+	{"10000001", []byte{0x00, 0x81}},
+	{"100000011", []byte{0x07, 0x81, 0x80}},
+}
+
+func TestAppendBitString(t *testing.T) {
+	for i, tt := range appendBitStringTests {
+		bits := make([]bool, len(tt.value))
+		for j := range tt.value {
+			bits[j] = tt.value[j] == '1'
+		}
+
+		dst := AppendBitString(nil, bits)
+		if !bytes.Equal(dst, tt.encoded) {
+			t.Errorf("%d. AppendBitString(nil, %v) = %v, wanted %v.", i, tt.value, dst, tt.encoded)
+		}
+
+		dst = AppendBitString(dst, bits)
+		if l := len(tt.encoded); len(dst) != l*2 || !bytes.Equal(dst[:l], tt.encoded) || !bytes.Equal(dst[l:], tt.encoded) {
+			t.Errorf("%d. AppendBitString did not preserve existing contents.", i)
+		}
+	}
+}
+
+func TestKeyUsageBits(t *testing.T) {
+	for _, name := range []string{"digitalSignature", "keyCertSign", "decipherOnly"} {
+		i, ok := KeyUsageBitByName(name)
+		if !ok {
+			t.Errorf("KeyUsageBitByName(%q) unexpectedly failed.", name)
+			continue
+		}
+		if name2, ok := KeyUsageBitName(i); !ok || name2 != name {
+			t.Errorf("KeyUsageBitName(%d) = %q, %v, wanted %q.", i, name2, ok, name)
+		}
+	}
+	if i, ok := KeyUsageBitByName("keyCertSign"); !ok || i != 5 {
+		t.Errorf("KeyUsageBitByName(\"keyCertSign\") = %d, %v, wanted 5.", i, ok)
+	}
+	if _, ok := KeyUsageBitByName("bogus"); ok {
+		t.Errorf("KeyUsageBitByName(\"bogus\") unexpectedly succeeded.")
+	}
+	for _, i := range []int{-1, 9} {
+		if _, ok := KeyUsageBitName(i); ok {
+			t.Errorf("KeyUsageBitName(%d) unexpectedly succeeded.", i)
+		}
+	}
+}
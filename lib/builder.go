@@ -0,0 +1,239 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lib
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// A Builder assembles DER from Go values. Its methods mirror the constructs of
+// the DER ASCII language: each appends one element, and constructed elements
+// take a function which appends their children to the same Builder.
+//
+// Errors are sticky. Once a method fails, later calls do nothing and Bytes
+// returns the first error.
+type Builder struct {
+	out []byte
+	err error
+}
+
+// NewBuilder returns an empty Builder.
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// Bytes returns the DER assembled so far, or the first error encountered.
+func (b *Builder) Bytes() ([]byte, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.out, nil
+}
+
+// Raw appends der without checking that it is a valid element.
+func (b *Builder) Raw(der []byte) {
+	if b.err != nil {
+		return
+	}
+	b.out = append(b.out, der...)
+}
+
+// Primitive appends an element with the given tag and contents. The tag is
+// written as given, so it may be used for implicitly-tagged values.
+func (b *Builder) Primitive(tag Tag, contents []byte) {
+	if b.err != nil {
+		return
+	}
+	b.out = AppendTag(b.out, tag)
+	b.out = AppendLength(b.out, len(contents))
+	b.out = append(b.out, contents...)
+}
+
+// Element appends an element with the given tag, whose contents are appended
+// by f. The tag is written as given, so Element may be used to nest elements
+// inside a primitive type, such as an OCTET STRING.
+func (b *Builder) Element(tag Tag, f func(b *Builder)) {
+	b.element(tag, f)
+}
+
+// element implements Element. It returns false if b is in an error state
+// afterwards.
+func (b *Builder) element(tag Tag, f func(b *Builder)) bool {
+	if b.err != nil {
+		return false
+	}
+	b.out = AppendTag(b.out, tag)
+	// Reserve a short-form length, which InsertLength widens if needed.
+	offset := len(b.out)
+	b.out = append(b.out, 0)
+	f(b)
+	if b.err != nil {
+		return false
+	}
+	b.out = InsertLength(b.out, offset)
+	return true
+}
+
+// Sequence appends a SEQUENCE whose children are appended by f.
+func (b *Builder) Sequence(f func(b *Builder)) {
+	b.Element(Tag{ClassUniversal, 16, true}, f)
+}
+
+// Set appends a SET whose children are appended by f. The children are written
+// in the order given. Use SetOf to sort them.
+func (b *Builder) Set(f func(b *Builder)) {
+	b.Element(Tag{ClassUniversal, 17, true}, f)
+}
+
+// SetOf appends a SET whose children are appended by f. As in the set-of
+// function of the DER ASCII language, the children are sorted as required by
+// DER.
+func (b *Builder) SetOf(f func(b *Builder)) {
+	start := len(b.out)
+	if !b.element(Tag{ClassUniversal, 17, true}, f) {
+		return
+	}
+	_, _, headerLen, err := ParseElement(b.out[start:])
+	if err != nil {
+		b.err = err
+		return
+	}
+	contents := b.out[start+headerLen:]
+	var children [][]byte
+	for rest := contents; len(rest) > 0; {
+		_, length, childHeaderLen, err := ParseElement(rest)
+		if err == nil && length == IndefiniteLength {
+			err = errors.New("SET OF child has indefinite length")
+		}
+		if err == nil && length > len(rest)-childHeaderLen {
+			err = errors.New("truncated SET OF child")
+		}
+		if err != nil {
+			b.err = err
+			return
+		}
+		children = append(children, rest[:childHeaderLen+length])
+		rest = rest[childHeaderLen+length:]
+	}
+	sort.SliceStable(children, func(i, j int) bool {
+		return bytes.Compare(children[i], children[j]) < 0
+	})
+	sorted := make([]byte, 0, len(contents))
+	for _, child := range children {
+		sorted = append(sorted, child...)
+	}
+	copy(contents, sorted)
+}
+
+// Tagged appends an explicitly-tagged element whose child is appended by f. The
+// tag is always written as constructed.
+func (b *Builder) Tagged(tag Tag, f func(b *Builder)) {
+	tag.Constructed = true
+	b.Element(tag, f)
+}
+
+// Boolean appends a BOOLEAN.
+func (b *Builder) Boolean(value bool) {
+	var contents byte
+	if value {
+		contents = 0xff
+	}
+	b.Primitive(Tag{ClassUniversal, 1, false}, []byte{contents})
+}
+
+// Integer appends an INTEGER.
+func (b *Builder) Integer(value int64) {
+	b.Primitive(Tag{ClassUniversal, 2, false}, AppendInteger(nil, value))
+}
+
+// BigInteger appends an INTEGER.
+func (b *Builder) BigInteger(value *big.Int) {
+	b.Primitive(Tag{ClassUniversal, 2, false}, AppendBigInteger(nil, value))
+}
+
+// BitString appends a BIT STRING containing the given bits.
+func (b *Builder) BitString(bits []bool) {
+	b.Primitive(Tag{ClassUniversal, 3, false}, AppendBitString(nil, bits))
+}
+
+// OctetString appends an OCTET STRING.
+func (b *Builder) OctetString(contents []byte) {
+	b.Primitive(Tag{ClassUniversal, 4, false}, contents)
+}
+
+// Null appends a NULL.
+func (b *Builder) Null() {
+	b.Primitive(Tag{ClassUniversal, 5, false}, nil)
+}
+
+// OID appends an OBJECT IDENTIFIER with the given arcs.
+func (b *Builder) OID(arcs ...uint32) {
+	if b.err != nil {
+		return
+	}
+	contents, err := AppendObjectIdentifier(nil, arcs)
+	if err != nil {
+		b.err = err
+		return
+	}
+	b.Primitive(Tag{ClassUniversal, 6, false}, contents)
+}
+
+// UTF8String appends a UTF8String.
+func (b *Builder) UTF8String(s string) {
+	b.Primitive(Tag{ClassUniversal, 12, false}, []byte(s))
+}
+
+// PrintableString appends a PrintableString. The string is not checked
+// against PrintableString's character set.
+func (b *Builder) PrintableString(s string) {
+	b.Primitive(Tag{ClassUniversal, 19, false}, []byte(s))
+}
+
+// IA5String appends an IA5String. The string is not checked against
+// IA5String's character set.
+func (b *Builder) IA5String(s string) {
+	b.Primitive(Tag{ClassUniversal, 22, false}, []byte(s))
+}
+
+// UTCTime appends a UTCTime, converting t to UTC.
+func (b *Builder) UTCTime(t time.Time) {
+	if b.err != nil {
+		return
+	}
+	s, err := FormatUTCTime(t)
+	if err != nil {
+		b.err = err
+		return
+	}
+	b.Primitive(Tag{ClassUniversal, 23, false}, []byte(s))
+}
+
+// GeneralizedTime appends a GeneralizedTime, converting t to UTC.
+func (b *Builder) GeneralizedTime(t time.Time) {
+	if b.err != nil {
+		return
+	}
+	s, err := FormatGeneralizedTime(t)
+	if err != nil {
+		b.err = err
+		return
+	}
+	b.Primitive(Tag{ClassUniversal, 24, false}, []byte(s))
+}
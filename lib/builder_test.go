@@ -0,0 +1,124 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lib
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+var builderTests = []struct {
+	name  string
+	build func(b *Builder)
+	want  string
+	ok    bool
+}{
+	{"empty", func(b *Builder) {}, "", true},
+	{"scalars", func(b *Builder) {
+		b.Boolean(true)
+		b.Boolean(false)
+		b.Integer(-129)
+		b.BigInteger(big.NewInt(128))
+		b.Null()
+		b.OID(1, 2, 840, 113549)
+		b.OctetString([]byte{1, 2})
+		b.BitString([]bool{true, false, true})
+	}, "0101ff010100" + "0202ff7f" + "02020080" + "0500" + "06062a864886f70d" + "04020102" + "030205a0", true},
+	{"strings", func(b *Builder) {
+		b.UTF8String("café")
+		b.PrintableString("abc")
+		b.IA5String("")
+	}, "0c05636166c3a9" + "1303616263" + "1600", true},
+	{"times", func(b *Builder) {
+		b.UTCTime(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+		b.GeneralizedTime(time.Date(2024, 1, 2, 3, 4, 5, 500000000, time.FixedZone("", 3600)))
+	}, "170d3234303130323033303430355a" + "181132303234303130323032303430352e355a", true},
+	{"sequence", func(b *Builder) {
+		b.Sequence(func(b *Builder) {
+			b.Integer(5)
+			b.Sequence(func(b *Builder) {})
+		})
+	}, "3005" + "020105" + "3000", true},
+	{"tagged", func(b *Builder) {
+		b.Tagged(Tag{ClassContextSpecific, 0, false}, func(b *Builder) {
+			b.Integer(2)
+		})
+		b.Primitive(Tag{ClassContextSpecific, 1, false}, []byte("a"))
+		b.Element(Tag{ClassUniversal, 4, false}, func(b *Builder) {
+			b.Null()
+		})
+		b.Raw([]byte{0xff})
+	}, "a003020102" + "810161" + "04020500" + "ff", true},
+	{"set", func(b *Builder) {
+		b.Set(func(b *Builder) {
+			b.Integer(2)
+			b.Integer(1)
+		})
+		b.SetOf(func(b *Builder) {
+			b.Integer(2)
+			b.OctetString(nil)
+			b.Integer(1)
+		})
+	}, "3106020102020101" + "31080201010201020400", true},
+	{"long length", func(b *Builder) {
+		b.Sequence(func(b *Builder) {
+			b.OctetString(make([]byte, 0x100))
+		})
+	}, "3082010404820100" + strings.Repeat("00", 0x100), true},
+	// Errors.
+	{"bad OID", func(b *Builder) { b.OID(3, 1) }, "", false},
+	{"UTCTime out of range", func(b *Builder) {
+		b.UTCTime(time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC))
+	}, "", false},
+	{"bad SET OF child", func(b *Builder) {
+		b.SetOf(func(b *Builder) { b.Raw([]byte{0x02, 0x05, 0x00}) })
+	}, "", false},
+	{"error in child", func(b *Builder) {
+		b.Sequence(func(b *Builder) {
+			b.OID(1)
+			b.Integer(1)
+		})
+		b.Integer(1)
+	}, "", false},
+}
+
+func TestBuilder(t *testing.T) {
+	for _, tt := range builderTests {
+		b := NewBuilder()
+		tt.build(b)
+		out, err := b.Bytes()
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("%s: Builder unexpectedly succeeded with %x.", tt.name, out)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Builder failed: %s", tt.name, err)
+			continue
+		}
+		want, err := hex.DecodeString(tt.want)
+		if err != nil {
+			t.Fatalf("%s: invalid hex: %s", tt.name, err)
+		}
+		if !bytes.Equal(out, want) {
+			t.Errorf("%s: Builder produced %x, wanted %x.", tt.name, out, want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lib
+
+import "errors"
+
+// IndefiniteLength is the length returned by ParseElement for an
+// indefinite-length element.
+const IndefiniteLength = -1
+
+// AppendTag marshals tag and appends the result to dst, returning the updated
+// slice. Tag numbers of 31 and above use the high-tag-number form.
+func AppendTag(dst []byte, tag Tag) []byte {
+	b := byte(tag.Class)
+	if tag.Constructed {
+		b |= 0x20
+	}
+	if tag.Number < 0x1f {
+		// Low-tag-number form.
+		return append(dst, b|byte(tag.Number))
+	}
+
+	// High-tag-number form.
+	dst = append(dst, b|0x1f)
+	return appendBase128(dst, tag.Number)
+}
+
+// ParseTag parses the tag at the start of der. It returns the tag and the
+// number of bytes used to encode it. The tag number must be minimally encoded
+// and fit in 32 bits.
+func ParseTag(der []byte) (tag Tag, tagLen int, err error) {
+	if len(der) == 0 {
+		return Tag{}, 0, errors.New("truncated tag")
+	}
+	b := der[0]
+	tag = Tag{Class(b & 0xc0), uint32(b & 0x1f), b&0x20 != 0}
+	if tag.Number < 0x1f {
+		// Low-tag-number form.
+		return tag, 1, nil
+	}
+
+	// High-tag-number form.
+	if len(der) > 1 && der[1] == 0x80 {
+		return Tag{}, 0, errors.New("tag number has a leading zero byte")
+	}
+	var number uint32
+	for i := 1; ; i++ {
+		if i >= len(der) {
+			return Tag{}, 0, errors.New("truncated tag")
+		}
+		if number > 0xffffffff>>7 {
+			return Tag{}, 0, errors.New("tag number does not fit in 32 bits")
+		}
+		number = number<<7 | uint32(der[i]&0x7f)
+		if der[i]&0x80 == 0 {
+			tagLen = i + 1
+			break
+		}
+	}
+	if number < 0x1f {
+		return Tag{}, 0, errors.New("tag number should use the low-tag-number form")
+	}
+	tag.Number = number
+	return tag, tagLen, nil
+}
+
+// ParseElement parses the tag and length at the start of der, the header of a
+// BER element. It returns the tag, the length of the contents, and the number
+// of bytes in the header, so the contents begin at der[headerLen:]. For an
+// indefinite-length element, length is IndefiniteLength. Lengths must be
+// minimally encoded, as in DER, and are limited to 2^31-1 bytes. ParseElement
+// does not check that der contains the contents.
+func ParseElement(der []byte) (tag Tag, length int, headerLen int, err error) {
+	tag, headerLen, err = ParseTag(der)
+	if err != nil {
+		return Tag{}, 0, 0, err
+	}
+	if headerLen >= len(der) {
+		return Tag{}, 0, 0, errors.New("truncated length")
+	}
+	b := der[headerLen]
+	headerLen++
+	switch {
+	case b < 0x80:
+		// Short form length.
+		return tag, int(b), headerLen, nil
+	case b == 0x80:
+		if !tag.Constructed {
+			return Tag{}, 0, 0, errors.New("indefinite length on a primitive element")
+		}
+		return tag, IndefiniteLength, headerLen, nil
+	case b == 0xff:
+		return Tag{}, 0, 0, errors.New("invalid length byte 0xff")
+	}
+
+	// Long form length.
+	n := int(b & 0x7f)
+	if n > len(der)-headerLen {
+		return Tag{}, 0, 0, errors.New("truncated length")
+	}
+	if der[headerLen] == 0 {
+		return Tag{}, 0, 0, errors.New("length has a leading zero byte")
+	}
+	for _, c := range der[headerLen : headerLen+n] {
+		if length >= 1<<23 {
+			return Tag{}, 0, 0, errors.New("length too large")
+		}
+		length = length<<8 | int(c)
+	}
+	if length < 0x80 {
+		return Tag{}, 0, 0, errors.New("length should use the short form")
+	}
+	return tag, length, headerLen + n, nil
+}
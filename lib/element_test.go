@@ -0,0 +1,110 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lib
+
+import (
+	"bytes"
+	"testing"
+)
+
+var appendTagTests = []struct {
+	tag     Tag
+	encoded []byte
+}{
+	{Tag{ClassUniversal, 16, true}, []byte{0x30}},
+	{Tag{ClassUniversal, 2, false}, []byte{0x02}},
+	{Tag{ClassContextSpecific, 1, true}, []byte{0xa1}},
+	{Tag{ClassApplication, 1234, true}, []byte{0x7f, 0x89, 0x52}},
+	// Tag numbers of 31 and above use the high-tag-number form.
+	{Tag{ClassContextSpecific, 30, false}, []byte{0x9e}},
+	{Tag{ClassContextSpecific, 31, false}, []byte{0x9f, 0x1f}},
+	{Tag{ClassContextSpecific, 127, false}, []byte{0x9f, 0x7f}},
+	{Tag{ClassContextSpecific, 128, false}, []byte{0x9f, 0x81, 0x00}},
+	{Tag{ClassContextSpecific, 16383, false}, []byte{0x9f, 0xff, 0x7f}},
+	{Tag{ClassPrivate, 500, true}, []byte{0xff, 0x83, 0x74}},
+	{Tag{ClassUniversal, 1<<32 - 1, false}, []byte{0x1f, 0x8f, 0xff, 0xff, 0xff, 0x7f}},
+}
+
+func TestAppendTag(t *testing.T) {
+	for i, tt := range appendTagTests {
+		dst := AppendTag(nil, tt.tag)
+		if !bytes.Equal(dst, tt.encoded) {
+			t.Errorf("%d. AppendTag(nil, %v) = %v, wanted %v.", i, tt.tag, dst, tt.encoded)
+		}
+
+		dst = AppendTag(dst, tt.tag)
+		if l := len(tt.encoded); len(dst) != l*2 || !bytes.Equal(dst[:l], tt.encoded) || !bytes.Equal(dst[l:], tt.encoded) {
+			t.Errorf("%d. AppendTag did not preserve existing contents.", i)
+		}
+	}
+}
+
+func TestAppendTagRoundTrip(t *testing.T) {
+	for i, tt := range appendTagTests {
+		tag, tagLen, err := ParseTag(AppendTag(nil, tt.tag))
+		if err != nil || tag != tt.tag || tagLen != len(tt.encoded) {
+			t.Errorf("%d. ParseTag(AppendTag(nil, %v)) = %v, %d, %v, wanted %v, %d.", i, tt.tag, tag, tagLen, err, tt.tag, len(tt.encoded))
+		}
+	}
+}
+
+var parseElementTests = []struct {
+	in        []byte
+	tag       Tag
+	length    int
+	headerLen int
+	err       string
+}{
+	{[]byte{0x30, 0x00}, Tag{ClassUniversal, 16, true}, 0, 2, ""},
+	// The contents are not checked.
+	{[]byte{0x04, 0x05, 0x61}, Tag{ClassUniversal, 4, false}, 5, 2, ""},
+	{[]byte{0x00, 0x00}, Tag{ClassUniversal, 0, false}, 0, 2, ""},
+	{[]byte{0x30, 0x80}, Tag{ClassUniversal, 16, true}, IndefiniteLength, 2, ""},
+	{[]byte{0x04, 0x81, 0x80}, Tag{ClassUniversal, 4, false}, 128, 3, ""},
+	{[]byte{0x04, 0x82, 0x01, 0x00}, Tag{ClassUniversal, 4, false}, 256, 4, ""},
+	{[]byte{0x04, 0x84, 0x7f, 0xff, 0xff, 0xff}, Tag{ClassUniversal, 4, false}, 1<<31 - 1, 6, ""},
+	{[]byte{0x7f, 0x89, 0x52, 0x00}, Tag{ClassApplication, 1234, true}, 0, 4, ""},
+	{[]byte{0xdf, 0x8f, 0xff, 0xff, 0xff, 0x7f, 0x00}, Tag{ClassPrivate, 1<<32 - 1, false}, 0, 7, ""},
+	{[]byte{}, Tag{}, 0, 0, "truncated tag"},
+	{[]byte{0x1f}, Tag{}, 0, 0, "truncated tag"},
+	{[]byte{0x1f, 0x81}, Tag{}, 0, 0, "truncated tag"},
+	{[]byte{0x1f, 0x80, 0x20}, Tag{}, 0, 0, "tag number has a leading zero byte"},
+	{[]byte{0x1f, 0x01}, Tag{}, 0, 0, "tag number should use the low-tag-number form"},
+	{[]byte{0x1f, 0x9f, 0xff, 0xff, 0xff, 0x7f}, Tag{}, 0, 0, "tag number does not fit in 32 bits"},
+	{[]byte{0x30}, Tag{}, 0, 0, "truncated length"},
+	{[]byte{0x30, 0x82, 0x01}, Tag{}, 0, 0, "truncated length"},
+	{[]byte{0x04, 0x80}, Tag{}, 0, 0, "indefinite length on a primitive element"},
+	{[]byte{0x30, 0xff}, Tag{}, 0, 0, "invalid length byte 0xff"},
+	{[]byte{0x30, 0x82, 0x00, 0x80}, Tag{}, 0, 0, "length has a leading zero byte"},
+	{[]byte{0x30, 0x81, 0x7f}, Tag{}, 0, 0, "length should use the short form"},
+	{[]byte{0x30, 0x84, 0x80, 0x00, 0x00, 0x00}, Tag{}, 0, 0, "length too large"},
+}
+
+func TestParseElement(t *testing.T) {
+	for i, tt := range parseElementTests {
+		tag, length, headerLen, err := ParseElement(tt.in)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d. ParseElement(%x) returned %v, wanted %q.", i, tt.in, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d. ParseElement(%x) failed: %s.", i, tt.in, err)
+		} else if tag != tt.tag || length != tt.length || headerLen != tt.headerLen {
+			t.Errorf("%d. ParseElement(%x) = %v, %d, %d, wanted %v, %d, %d.", i, tt.in, tag, length, headerLen, tt.tag, tt.length, tt.headerLen)
+		}
+	}
+}
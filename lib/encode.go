@@ -0,0 +1,215 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// appendBase128 appends value to dst in base 128, most significant digit
+// first, with the high bit set on all but the last byte. This is the encoding
+// used for OID arcs and high tag numbers.
+func appendBase128(dst []byte, value uint32) []byte {
+	// Special-case: zero is encoded with one, not zero bytes.
+	if value == 0 {
+		return append(dst, 0)
+	}
+	// Count how many bytes are needed.
+	var l int
+	for n := value; n != 0; n >>= 7 {
+		l++
+	}
+	for ; l > 0; l-- {
+		b := byte(value>>uint(7*(l-1))) & 0x7f
+		if l > 1 {
+			b |= 0x80
+		}
+		dst = append(dst, b)
+	}
+	return dst
+}
+
+// AppendLength marshals the given length in DER and appends the result to dst,
+// returning the updated slice.
+func AppendLength(dst []byte, length int) []byte {
+	if length < 0x80 {
+		// Short-form length.
+		return append(dst, byte(length))
+	}
+
+	// Long-form length. Count how many bytes are needed.
+	var l byte
+	for n := length; n != 0; n >>= 8 {
+		l++
+	}
+	dst = append(dst, 0x80|l)
+	for ; l > 0; l-- {
+		dst = append(dst, byte(length>>uint(8*(l-1))))
+	}
+	return dst
+}
+
+// AppendLengthChecked behaves like AppendLength, but returns an error if length
+// is negative or its long-form encoding would need more than maxBytes bytes
+// after the initial byte. Short-form lengths, below 128, need none. On error,
+// dst is unmodified.
+func AppendLengthChecked(dst []byte, length int, maxBytes int) ([]byte, error) {
+	if length < 0 {
+		return dst, fmt.Errorf("length %d is negative", length)
+	}
+	var l int
+	if length >= 0x80 {
+		for n := length; n != 0; n >>= 8 {
+			l++
+		}
+	}
+	if l > maxBytes {
+		return dst, fmt.Errorf("length %d needs %d length bytes, more than the maximum of %d", length, l, maxBytes)
+	}
+	return AppendLength(dst, length), nil
+}
+
+// InsertLength replaces the placeholder byte at dst[offset] with the DER
+// encoding of the length of the bytes which follow it, returning the updated
+// slice. If the length does not fit in one byte, the following bytes are moved
+// to make room.
+func InsertLength(dst []byte, offset int) []byte {
+	length := len(dst) - offset - 1
+	if length < 0x80 {
+		dst[offset] = byte(length)
+		return dst
+	}
+	var buf [9]byte
+	encoded := AppendLength(buf[:0], length)
+	dst = append(dst, encoded[1:]...)
+	copy(dst[offset+len(encoded):], dst[offset+1:])
+	copy(dst[offset:], encoded)
+	return dst
+}
+
+// AppendInteger marshals the given value as the contents of a DER INTEGER and
+// appends the result to dst, returning the updated slice.
+func AppendInteger(dst []byte, value int64) []byte {
+	return appendIntegerBytes(dst, value, integerLength(value))
+}
+
+// AppendIntegerWidth marshals the given value as the contents of an INTEGER,
+// sign-extended to exactly width bytes, and appends the result to dst. This
+// violates DER's minimal encoding rule if width is larger than necessary. If
+// value does not fit in width bytes, it returns dst unmodified and false.
+func AppendIntegerWidth(dst []byte, value int64, width int) ([]byte, bool) {
+	if width < integerLength(value) {
+		return dst, false
+	}
+	return appendIntegerBytes(dst, value, width), true
+}
+
+// AppendUnsignedInteger marshals value as a minimal big-endian unsigned
+// integer and appends the result to dst, returning the updated slice. Unlike
+// AppendInteger, no leading zero is added when the high bit is set, so values
+// from 128 up to a power of 256 encode as negative INTEGERs. This violates
+// standard INTEGER semantics, but matches how some formats embed unsigned
+// fields. Zero is encoded as a single zero byte.
+func AppendUnsignedInteger(dst []byte, value uint64) []byte {
+	l := 1
+	for n := value; n > 0xff; n >>= 8 {
+		l++
+	}
+	for ; l > 0; l-- {
+		dst = append(dst, byte(value>>uint(8*(l-1))))
+	}
+	return dst
+}
+
+// integerLength returns the number of bytes in the minimal two's-complement
+// encoding of value.
+func integerLength(value int64) int {
+	l := 1
+	for n := value; n > 0x7f || n < (0x80-0x100); n >>= 8 {
+		l++
+	}
+	return l
+}
+
+// appendIntegerBytes appends the low l bytes of value's two's-complement
+// representation to dst. Shifts beyond 64 bits sign-extend the value.
+func appendIntegerBytes(dst []byte, value int64, l int) []byte {
+	for ; l > 0; l-- {
+		dst = append(dst, byte(value>>uint(8*(l-1))))
+	}
+	return dst
+}
+
+// AppendBigInteger marshals the given value as the contents of a DER INTEGER
+// and appends the result to dst, returning the updated slice.
+func AppendBigInteger(dst []byte, value *big.Int) []byte {
+	if value.Sign() >= 0 {
+		b := value.Bytes()
+		if len(b) == 0 || b[0]&0x80 != 0 {
+			dst = append(dst, 0)
+		}
+		return append(dst, b...)
+	}
+
+	// The two's complement of a negative value, -n, is the bitwise complement
+	// of n-1.
+	n := new(big.Int).Neg(value)
+	n.Sub(n, big.NewInt(1))
+	b := n.Bytes()
+	for i := range b {
+		b[i] = ^b[i]
+	}
+	if len(b) == 0 || b[0]&0x80 == 0 {
+		dst = append(dst, 0xff)
+	}
+	return append(dst, b...)
+}
+
+// AppendObjectIdentifier marshals value as the contents of an OBJECT
+// IDENTIFIER and appends the result to dst. It returns an error describing the
+// offending arc if value cannot be encoded. In that case, dst is unmodified.
+func AppendObjectIdentifier(dst []byte, value []uint32) ([]byte, error) {
+	// Validate the input before anything is written.
+	if len(value) < 2 {
+		return dst, errors.New("OID must have at least two arcs")
+	}
+	if value[0] > 2 {
+		return dst, fmt.Errorf("first OID arc must be 0, 1, or 2, got %d", value[0])
+	}
+	if value[0] < 2 && value[1] > 39 {
+		return dst, fmt.Errorf("second OID arc must be less than 40 when the first arc is %d, got %d", value[0], value[1])
+	}
+	if value[0]*40+value[1] < value[1] {
+		return dst, fmt.Errorf("second OID arc is too large, got %d", value[1])
+	}
+
+	dst = appendBase128(dst, value[0]*40+value[1])
+	for _, v := range value[2:] {
+		dst = appendBase128(dst, v)
+	}
+	return dst, nil
+}
+
+// AppendRelativeOID marshals value as the contents of a RELATIVE-OID and
+// appends the result to dst. Unlike AppendObjectIdentifier, the first two arcs
+// are not combined, so any sequence of arcs is valid.
+func AppendRelativeOID(dst []byte, value []uint32) []byte {
+	for _, v := range value {
+		dst = appendBase128(dst, v)
+	}
+	return dst
+}
@@ -0,0 +1,324 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lib
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"testing"
+)
+
+var appendLengthTests = []struct {
+	length  int
+	encoded []byte
+}{
+	{0, []byte{0}},
+	{5, []byte{0x05}},
+	{0x1f, []byte{0x1f}},
+	{0x80, []byte{0x81, 0x80}},
+	{0xff, []byte{0x81, 0xff}},
+	{0x100, []byte{0x82, 0x01, 0x00}},
+	{0xffffff, []byte{0x83, 0xff, 0xff, 0xff}},
+}
+
+func TestAppendLength(t *testing.T) {
+	for i, tt := range appendLengthTests {
+		dst := AppendLength(nil, tt.length)
+		if !bytes.Equal(dst, tt.encoded) {
+			t.Errorf("%d. AppendLength(nil, %v) = %v, wanted %v.", i, tt.length, dst, tt.encoded)
+		}
+
+		dst = AppendLength(dst, tt.length)
+		if l := len(tt.encoded); len(dst) != l*2 || !bytes.Equal(dst[:l], tt.encoded) || !bytes.Equal(dst[l:], tt.encoded) {
+			t.Errorf("%d. AppendLength did not preserve existing contents.", i)
+		}
+	}
+}
+
+var appendLengthCheckedTests = []struct {
+	length   int
+	maxBytes int
+	encoded  []byte
+	ok       bool
+}{
+	{0, 0, []byte{0x00}, true},
+	{0x7f, 0, []byte{0x7f}, true},
+	{0x80, 0, nil, false},
+	{0x80, 1, []byte{0x81, 0x80}, true},
+	{0xff, 1, []byte{0x81, 0xff}, true},
+	{0x100, 1, nil, false},
+	{0x100, 2, []byte{0x82, 0x01, 0x00}, true},
+	{0xffff, 2, []byte{0x82, 0xff, 0xff}, true},
+	{0x10000, 2, nil, false},
+	{0x10000, 3, []byte{0x83, 0x01, 0x00, 0x00}, true},
+	{0x10000, 8, []byte{0x83, 0x01, 0x00, 0x00}, true},
+	{-1, 8, nil, false},
+	{0, -1, nil, false},
+}
+
+func TestAppendLengthChecked(t *testing.T) {
+	for i, tt := range appendLengthCheckedTests {
+		dst, err := AppendLengthChecked([]byte{0}, tt.length, tt.maxBytes)
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("%d. AppendLengthChecked(_, %v, %v) unexpectedly succeeded.", i, tt.length, tt.maxBytes)
+			} else if !bytes.Equal(dst, []byte{0}) {
+				t.Errorf("%d. AppendLengthChecked did not preserve input.", i)
+			}
+		} else if err != nil {
+			t.Errorf("%d. AppendLengthChecked(_, %v, %v) failed: %s", i, tt.length, tt.maxBytes, err)
+		} else if !bytes.Equal(dst[1:], tt.encoded) || dst[0] != 0 {
+			t.Errorf("%d. AppendLengthChecked(_, %v, %v) = %v, wanted %v.", i, tt.length, tt.maxBytes, dst[1:], tt.encoded)
+		}
+	}
+}
+
+var insertLengthTests = []struct {
+	length  int
+	encoded []byte
+}{
+	{0, []byte{0}},
+	{5, []byte{0x05}},
+	{0x7f, []byte{0x7f}},
+	{0x80, []byte{0x81, 0x80}},
+	{0xff, []byte{0x81, 0xff}},
+	{0x100, []byte{0x82, 0x01, 0x00}},
+}
+
+func TestInsertLength(t *testing.T) {
+	for i, tt := range insertLengthTests {
+		if tt.length > 0x10000 {
+			continue
+		}
+		contents := bytes.Repeat([]byte{0xaa}, tt.length)
+		dst := append([]byte{0x30, 0x00}, contents...)
+		dst = InsertLength(dst, 1)
+		want := append(append([]byte{0x30}, tt.encoded...), contents...)
+		if !bytes.Equal(dst, want) {
+			t.Errorf("%d. InsertLength for length %d gave the wrong result.", i, tt.length)
+		}
+	}
+}
+
+var appendIntegerTests = []struct {
+	value   int64
+	encoded []byte
+}{
+	{0, []byte{0}},
+	{1, []byte{1}},
+	{-1, []byte{0xff}},
+	{127, []byte{0x7f}},
+	{128, []byte{0x00, 0x80}},
+	{0x12345678, []byte{0x12, 0x34, 0x56, 0x78}},
+	{-127, []byte{0x81}},
+	{-128, []byte{0x80}},
+	{-129, []byte{0xff, 0x7f}},
+}
+
+func TestAppendInteger(t *testing.T) {
+	for i, tt := range appendIntegerTests {
+		dst := AppendInteger(nil, tt.value)
+		if !bytes.Equal(dst, tt.encoded) {
+			t.Errorf("%d. AppendInteger(nil, %v) = %v, wanted %v.", i, tt.value, dst, tt.encoded)
+		}
+
+		dst = AppendInteger(dst, tt.value)
+		if l := len(tt.encoded); len(dst) != l*2 || !bytes.Equal(dst[:l], tt.encoded) || !bytes.Equal(dst[l:], tt.encoded) {
+			t.Errorf("%d. AppendInteger did not preserve existing contents.", i)
+		}
+	}
+}
+
+var appendIntegerWidthTests = []struct {
+	value   int64
+	width   int
+	encoded []byte
+	ok      bool
+}{
+	{0, 1, []byte{0x00}, true},
+	{1, 4, []byte{0x00, 0x00, 0x00, 0x01}, true},
+	{-1, 3, []byte{0xff, 0xff, 0xff}, true},
+	{128, 2, []byte{0x00, 0x80}, true},
+	{-128, 2, []byte{0xff, 0x80}, true},
+	{-2, 10, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe}, true},
+	{0x12345678, 10, []byte{0, 0, 0, 0, 0, 0, 0x12, 0x34, 0x56, 0x78}, true},
+	// The value does not fit.
+	{128, 1, nil, false},
+	{-129, 1, nil, false},
+	{0x12345678, 3, nil, false},
+	{0, 0, nil, false},
+}
+
+func TestAppendIntegerWidth(t *testing.T) {
+	for i, tt := range appendIntegerWidthTests {
+		dst, ok := AppendIntegerWidth(nil, tt.value, tt.width)
+		if ok != tt.ok {
+			t.Errorf("%d. AppendIntegerWidth(nil, %v, %v) returned ok = %v, wanted %v.", i, tt.value, tt.width, ok, tt.ok)
+		}
+		if !bytes.Equal(dst, tt.encoded) {
+			t.Errorf("%d. AppendIntegerWidth(nil, %v, %v) = %v, wanted %v.", i, tt.value, tt.width, dst, tt.encoded)
+		}
+	}
+}
+
+var appendUnsignedIntegerTests = []struct {
+	value   uint64
+	encoded []byte
+}{
+	{0, []byte{0x00}},
+	{1, []byte{0x01}},
+	{127, []byte{0x7f}},
+	{128, []byte{0x80}},
+	{200, []byte{0xc8}},
+	{255, []byte{0xff}},
+	{256, []byte{0x01, 0x00}},
+	{0x8000, []byte{0x80, 0x00}},
+	{0xffffffffffffffff, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+}
+
+func TestAppendUnsignedInteger(t *testing.T) {
+	for i, tt := range appendUnsignedIntegerTests {
+		dst := AppendUnsignedInteger(nil, tt.value)
+		if !bytes.Equal(dst, tt.encoded) {
+			t.Errorf("%d. AppendUnsignedInteger(nil, %v) = %v, wanted %v.", i, tt.value, dst, tt.encoded)
+		}
+		// Below the 0x80 boundary, the unsigned encoding matches the
+		// signed one.
+		if tt.value < 0x80 {
+			if signed := AppendInteger(nil, int64(tt.value)); !bytes.Equal(dst, signed) {
+				t.Errorf("%d. AppendUnsignedInteger(nil, %v) = %v, but AppendInteger gave %v.", i, tt.value, dst, signed)
+			}
+		}
+	}
+}
+
+var appendBigIntegerTests = []struct {
+	value   string
+	encoded []byte
+}{
+	{"0", []byte{0}},
+	{"1", []byte{1}},
+	{"-1", []byte{0xff}},
+	{"127", []byte{0x7f}},
+	{"128", []byte{0x00, 0x80}},
+	{"-128", []byte{0x80}},
+	{"-129", []byte{0xff, 0x7f}},
+	{"-256", []byte{0xff, 0x00}},
+	{"-257", []byte{0xfe, 0xff}},
+	{"9223372036854775807", []byte{0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+	{"9223372036854775808", []byte{0x00, 0x80, 0, 0, 0, 0, 0, 0, 0}},
+	{"-9223372036854775808", []byte{0x80, 0, 0, 0, 0, 0, 0, 0}},
+	{"-9223372036854775809", []byte{0xff, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+	{"18446744073709551616", []byte{0x01, 0, 0, 0, 0, 0, 0, 0, 0}},
+	{"-18446744073709551616", []byte{0xff, 0, 0, 0, 0, 0, 0, 0, 0}},
+}
+
+func TestAppendBigInteger(t *testing.T) {
+	for i, tt := range appendBigIntegerTests {
+		value, ok := new(big.Int).SetString(tt.value, 10)
+		if !ok {
+			t.Fatalf("%d. Could not parse %q.", i, tt.value)
+		}
+		dst := AppendBigInteger(nil, value)
+		if !bytes.Equal(dst, tt.encoded) {
+			t.Errorf("%d. AppendBigInteger(nil, %v) = %v, wanted %v.", i, tt.value, dst, tt.encoded)
+		}
+
+		dst = AppendBigInteger(dst, value)
+		if l := len(tt.encoded); len(dst) != l*2 || !bytes.Equal(dst[:l], tt.encoded) || !bytes.Equal(dst[l:], tt.encoded) {
+			t.Errorf("%d. AppendBigInteger did not preserve existing contents.", i)
+		}
+	}
+
+	// AppendBigInteger should agree with AppendInteger on int64 values.
+	for i, tt := range appendIntegerTests {
+		dst := AppendBigInteger(nil, big.NewInt(tt.value))
+		if !bytes.Equal(dst, tt.encoded) {
+			t.Errorf("%d. AppendBigInteger(nil, %v) = %v, wanted %v.", i, tt.value, dst, tt.encoded)
+		}
+	}
+}
+
+var appendObjectIdentifierTests = []struct {
+	value   []uint32
+	encoded []byte
+	ok      bool
+}{
+	{[]uint32{0, 1}, []byte{1}, true},
+	{[]uint32{1, 2, 3, 4, 0, 127, 128, 129}, []byte{42, 3, 4, 0, 0x7f, 0x81, 0x00, 0x81, 0x01}, true},
+	{[]uint32{2, 1}, []byte{81}, true},
+	{[]uint32{2, math.MaxUint32 - 80}, []byte{0x8f, 0xff, 0xff, 0xff, 0x7f}, true},
+	// Invalid OIDs.
+	{[]uint32{}, nil, false},
+	{[]uint32{1}, nil, false},
+	{[]uint32{1, 40}, nil, false},
+	{[]uint32{0, 40}, nil, false},
+	{[]uint32{3, 1}, nil, false},
+	{[]uint32{2, math.MaxUint32 - 79}, nil, false},
+}
+
+func TestAppendObjectIdentifier(t *testing.T) {
+	for i, tt := range appendObjectIdentifierTests {
+		dst, err := AppendObjectIdentifier(nil, tt.value)
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("%d. AppendObjectIdentifier(nil, %v) unexpectedly suceeded.", i, tt.value)
+			} else if len(dst) != 0 {
+				t.Errorf("%d. AppendObjectIdentifier did not preserve input.", i)
+			}
+		} else if !bytes.Equal(dst, tt.encoded) {
+			t.Errorf("%d. AppendObjectIdentifier(nil, %v) = %v, wanted %v.", i, tt.value, dst, tt.encoded)
+		}
+
+		dst = []byte{0}
+		dst, err = AppendObjectIdentifier(dst, tt.value)
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("%d. AppendObjectIdentifier(nil, %v) unexpectedly suceeded.", i, tt.value)
+			} else if !bytes.Equal(dst, []byte{0}) {
+				t.Errorf("%d. AppendObjectIdentifier did not preserve input.", i)
+			}
+		} else if l := len(tt.encoded); len(dst) != l+1 || dst[0] != 0 || !bytes.Equal(dst[1:], tt.encoded) {
+			t.Errorf("%d. AppendObjectIdentifier did not preserve existing contents.", i)
+		}
+	}
+}
+
+var appendRelativeOIDTests = []struct {
+	value   []uint32
+	encoded []byte
+}{
+	{[]uint32{}, []byte{}},
+	{[]uint32{3, 14, 25}, []byte{3, 14, 25}},
+	{[]uint32{0, 40, 127}, []byte{0, 40, 0x7f}},
+	{[]uint32{128, 129, 16384}, []byte{0x81, 0x00, 0x81, 0x01, 0x81, 0x80, 0x00}},
+	{[]uint32{math.MaxUint32}, []byte{0x8f, 0xff, 0xff, 0xff, 0x7f}},
+}
+
+func TestAppendRelativeOID(t *testing.T) {
+	for i, tt := range appendRelativeOIDTests {
+		dst := AppendRelativeOID(nil, tt.value)
+		if !bytes.Equal(dst, tt.encoded) {
+			t.Errorf("%d. AppendRelativeOID(nil, %v) = %v, wanted %v.", i, tt.value, dst, tt.encoded)
+		}
+
+		dst = []byte{0}
+		dst = AppendRelativeOID(dst, tt.value)
+		if l := len(tt.encoded); len(dst) != l+1 || dst[0] != 0 || !bytes.Equal(dst[1:], tt.encoded) {
+			t.Errorf("%d. AppendRelativeOID did not preserve existing contents.", i)
+		}
+	}
+}
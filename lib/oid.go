@@ -0,0 +1,153 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lib
+
+// objectIdentifiers is a table of well-known OIDs. Names are taken from the
+// ASN.1 modules which define them.
+var objectIdentifiers = []struct {
+	name string
+	oid  []uint32
+}{
+	// PKCS #1 (RFC 8017).
+	{"rsaEncryption", []uint32{1, 2, 840, 113549, 1, 1, 1}},
+	{"md5WithRSAEncryption", []uint32{1, 2, 840, 113549, 1, 1, 4}},
+	{"sha1WithRSAEncryption", []uint32{1, 2, 840, 113549, 1, 1, 5}},
+	{"id-RSAES-OAEP", []uint32{1, 2, 840, 113549, 1, 1, 7}},
+	{"id-mgf1", []uint32{1, 2, 840, 113549, 1, 1, 8}},
+	{"id-RSASSA-PSS", []uint32{1, 2, 840, 113549, 1, 1, 10}},
+	{"sha256WithRSAEncryption", []uint32{1, 2, 840, 113549, 1, 1, 11}},
+	{"sha384WithRSAEncryption", []uint32{1, 2, 840, 113549, 1, 1, 12}},
+	{"sha512WithRSAEncryption", []uint32{1, 2, 840, 113549, 1, 1, 13}},
+	{"sha224WithRSAEncryption", []uint32{1, 2, 840, 113549, 1, 1, 14}},
+
+	// PKCS #7 and PKCS #9.
+	{"id-data", []uint32{1, 2, 840, 113549, 1, 7, 1}},
+	{"id-signedData", []uint32{1, 2, 840, 113549, 1, 7, 2}},
+	{"emailAddress", []uint32{1, 2, 840, 113549, 1, 9, 1}},
+	{"id-contentType", []uint32{1, 2, 840, 113549, 1, 9, 3}},
+	{"id-messageDigest", []uint32{1, 2, 840, 113549, 1, 9, 4}},
+	{"id-signingTime", []uint32{1, 2, 840, 113549, 1, 9, 5}},
+	{"extensionRequest", []uint32{1, 2, 840, 113549, 1, 9, 14}},
+
+	// Elliptic curves (RFC 5480, RFC 5758, RFC 8410).
+	{"id-ecPublicKey", []uint32{1, 2, 840, 10045, 2, 1}},
+	{"prime256v1", []uint32{1, 2, 840, 10045, 3, 1, 7}},
+	{"secp384r1", []uint32{1, 3, 132, 0, 34}},
+	{"secp521r1", []uint32{1, 3, 132, 0, 35}},
+	{"ecdsa-with-SHA1", []uint32{1, 2, 840, 10045, 4, 1}},
+	{"ecdsa-with-SHA256", []uint32{1, 2, 840, 10045, 4, 3, 2}},
+	{"ecdsa-with-SHA384", []uint32{1, 2, 840, 10045, 4, 3, 3}},
+	{"ecdsa-with-SHA512", []uint32{1, 2, 840, 10045, 4, 3, 4}},
+	{"id-X25519", []uint32{1, 3, 101, 110}},
+	{"id-X448", []uint32{1, 3, 101, 111}},
+	{"id-Ed25519", []uint32{1, 3, 101, 112}},
+	{"id-Ed448", []uint32{1, 3, 101, 113}},
+
+	// DSA (RFC 3279, RFC 5758).
+	{"id-dsa", []uint32{1, 2, 840, 10040, 4, 1}},
+	{"id-dsa-with-sha1", []uint32{1, 2, 840, 10040, 4, 3}},
+	{"id-dsa-with-sha256", []uint32{2, 16, 840, 1, 101, 3, 4, 3, 2}},
+
+	// Hash functions.
+	{"id-md5", []uint32{1, 2, 840, 113549, 2, 5}},
+	{"id-sha1", []uint32{1, 3, 14, 3, 2, 26}},
+	{"id-sha256", []uint32{2, 16, 840, 1, 101, 3, 4, 2, 1}},
+	{"id-sha384", []uint32{2, 16, 840, 1, 101, 3, 4, 2, 2}},
+	{"id-sha512", []uint32{2, 16, 840, 1, 101, 3, 4, 2, 3}},
+	{"id-sha224", []uint32{2, 16, 840, 1, 101, 3, 4, 2, 4}},
+
+	// X.520 attribute types.
+	{"commonName", []uint32{2, 5, 4, 3}},
+	{"surname", []uint32{2, 5, 4, 4}},
+	{"serialNumber", []uint32{2, 5, 4, 5}},
+	{"countryName", []uint32{2, 5, 4, 6}},
+	{"localityName", []uint32{2, 5, 4, 7}},
+	{"stateOrProvinceName", []uint32{2, 5, 4, 8}},
+	{"streetAddress", []uint32{2, 5, 4, 9}},
+	{"organizationName", []uint32{2, 5, 4, 10}},
+	{"organizationalUnitName", []uint32{2, 5, 4, 11}},
+	{"title", []uint32{2, 5, 4, 12}},
+	{"givenName", []uint32{2, 5, 4, 42}},
+	{"initials", []uint32{2, 5, 4, 43}},
+	{"generationQualifier", []uint32{2, 5, 4, 44}},
+	{"dnQualifier", []uint32{2, 5, 4, 46}},
+	{"pseudonym", []uint32{2, 5, 4, 65}},
+	{"organizationIdentifier", []uint32{2, 5, 4, 97}},
+	{"userId", []uint32{0, 9, 2342, 19200300, 100, 1, 1}},
+	{"domainComponent", []uint32{0, 9, 2342, 19200300, 100, 1, 25}},
+
+	// X.509 certificate extensions (RFC 5280).
+	{"subjectKeyIdentifier", []uint32{2, 5, 29, 14}},
+	{"keyUsage", []uint32{2, 5, 29, 15}},
+	{"subjectAltName", []uint32{2, 5, 29, 17}},
+	{"issuerAltName", []uint32{2, 5, 29, 18}},
+	{"basicConstraints", []uint32{2, 5, 29, 19}},
+	{"cRLNumber", []uint32{2, 5, 29, 20}},
+	{"reasonCode", []uint32{2, 5, 29, 21}},
+	{"nameConstraints", []uint32{2, 5, 29, 30}},
+	{"cRLDistributionPoints", []uint32{2, 5, 29, 31}},
+	{"certificatePolicies", []uint32{2, 5, 29, 32}},
+	{"anyPolicy", []uint32{2, 5, 29, 32, 0}},
+	{"policyMappings", []uint32{2, 5, 29, 33}},
+	{"authorityKeyIdentifier", []uint32{2, 5, 29, 35}},
+	{"policyConstraints", []uint32{2, 5, 29, 36}},
+	{"extKeyUsage", []uint32{2, 5, 29, 37}},
+	{"inhibitAnyPolicy", []uint32{2, 5, 29, 54}},
+	{"authorityInfoAccess", []uint32{1, 3, 6, 1, 5, 5, 7, 1, 1}},
+
+	// PKIX access descriptors and extended key usages (RFC 5280).
+	{"id-ad-ocsp", []uint32{1, 3, 6, 1, 5, 5, 7, 48, 1}},
+	{"id-ad-caIssuers", []uint32{1, 3, 6, 1, 5, 5, 7, 48, 2}},
+	{"id-kp-serverAuth", []uint32{1, 3, 6, 1, 5, 5, 7, 3, 1}},
+	{"id-kp-clientAuth", []uint32{1, 3, 6, 1, 5, 5, 7, 3, 2}},
+	{"id-kp-codeSigning", []uint32{1, 3, 6, 1, 5, 5, 7, 3, 3}},
+	{"id-kp-emailProtection", []uint32{1, 3, 6, 1, 5, 5, 7, 3, 4}},
+	{"id-kp-timeStamping", []uint32{1, 3, 6, 1, 5, 5, 7, 3, 8}},
+	{"id-kp-OCSPSigning", []uint32{1, 3, 6, 1, 5, 5, 7, 3, 9}},
+}
+
+// OIDByName returns the well-known OID by name or false if no OID matches. The
+// caller may modify the result.
+func OIDByName(name string) ([]uint32, bool) {
+	for _, o := range objectIdentifiers {
+		if o.name == name {
+			return append([]uint32(nil), o.oid...), true
+		}
+	}
+	return nil, false
+}
+
+// OIDName returns the name of a well-known OID or false if the OID is not
+// known.
+func OIDName(oid []uint32) (string, bool) {
+	for _, o := range objectIdentifiers {
+		if oidEqual(o.oid, oid) {
+			return o.name, true
+		}
+	}
+	return "", false
+}
+
+func oidEqual(a, b []uint32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lib
+
+import (
+	"reflect"
+	"testing"
+)
+
+var oidByNameTests = []struct {
+	name string
+	oid  []uint32
+	ok   bool
+}{
+	{"rsaEncryption", []uint32{1, 2, 840, 113549, 1, 1, 1}, true},
+	{"sha256WithRSAEncryption", []uint32{1, 2, 840, 113549, 1, 1, 11}, true},
+	{"commonName", []uint32{2, 5, 4, 3}, true},
+	{"BOGUS", nil, false},
+	{"RSAENCRYPTION", nil, false},
+}
+
+func TestOIDByName(t *testing.T) {
+	for i, tt := range oidByNameTests {
+		oid, ok := OIDByName(tt.name)
+		if !tt.ok {
+			if ok {
+				t.Errorf("%d. Unexpectedly found OID named %v.", i, tt.name)
+			}
+		} else if !ok {
+			t.Errorf("%d. Could not find OID named %v.", i, tt.name)
+		} else if !reflect.DeepEqual(oid, tt.oid) {
+			t.Errorf("%d. OIDByName(%v) = %v, wanted %v.", i, tt.name, oid, tt.oid)
+		}
+	}
+
+	// Modifying the result does not affect later lookups.
+	oid, _ := OIDByName("rsaEncryption")
+	oid[0] = 2
+	if oid, _ := OIDByName("rsaEncryption"); oid[0] != 1 {
+		t.Errorf("OIDByName(rsaEncryption) = %v after modifying an earlier result.", oid)
+	}
+}
+
+var oidNameTests = []struct {
+	oid  []uint32
+	name string
+	ok   bool
+}{
+	{[]uint32{1, 2, 840, 113549, 1, 1, 1}, "rsaEncryption", true},
+	{[]uint32{2, 5, 4, 3}, "commonName", true},
+	{[]uint32{2, 5, 4}, "", false},
+	{[]uint32{2, 5, 4, 3, 0}, "", false},
+	{[]uint32{1, 2, 3}, "", false},
+	{nil, "", false},
+}
+
+func TestOIDName(t *testing.T) {
+	for i, tt := range oidNameTests {
+		name, ok := OIDName(tt.oid)
+		if ok != tt.ok || name != tt.name {
+			t.Errorf("%d. OIDName(%v) = %q, %v, wanted %q, %v.", i, tt.oid, name, ok, tt.name, tt.ok)
+		}
+	}
+
+	// Every OID in the table should map back to its name.
+	for _, o := range objectIdentifiers {
+		if name, ok := OIDName(o.oid); !ok || name != o.name {
+			t.Errorf("OIDName(%v) = %q, %v, wanted %q, true.", o.oid, name, ok, o.name)
+		}
+	}
+}
+
+func TestOIDNamesUnique(t *testing.T) {
+	for i, a := range objectIdentifiers {
+		for _, b := range objectIdentifiers[i+1:] {
+			if a.name == b.name {
+				t.Errorf("OID name %v is used twice.", a.name)
+			}
+			if reflect.DeepEqual(a.oid, b.oid) {
+				t.Errorf("OID %v is named both %v and %v.", a.oid, a.name, b.name)
+			}
+		}
+		if _, ok := TagByName(a.name); ok {
+			t.Errorf("OID name %v is also a tag name.", a.name)
+		}
+	}
+}
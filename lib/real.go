@@ -0,0 +1,124 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lib
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// appendSpecialReal appends the contents of a DER REAL for f if f is zero,
+// infinite, or NaN. Otherwise, it returns false.
+func appendSpecialReal(dst []byte, f float64) ([]byte, bool) {
+	switch {
+	case f == 0 && !math.Signbit(f):
+		// Positive zero is encoded with empty contents.
+		return dst, true
+	case f == 0:
+		return append(dst, 0x43), true
+	case math.IsInf(f, 1):
+		return append(dst, 0x40), true
+	case math.IsInf(f, -1):
+		return append(dst, 0x41), true
+	case math.IsNaN(f):
+		return append(dst, 0x42), true
+	}
+	return dst, false
+}
+
+// AppendReal marshals f as the contents of a DER REAL and appends the result to
+// dst, returning the updated slice. Finite, non-zero values use the base 2
+// encoding, with an odd mantissa as required by DER.
+func AppendReal(dst []byte, f float64) []byte {
+	if ret, ok := appendSpecialReal(dst, f); ok {
+		return ret
+	}
+
+	// Decompose f into mantissa * 2^exponent.
+	bits := math.Float64bits(f)
+	mantissa := bits & (1<<52 - 1)
+	exponent := int((bits>>52)&0x7ff) - 1075
+	if exponent == -1075 {
+		// Subnormal numbers have no implicit leading one.
+		exponent++
+	} else {
+		mantissa |= 1 << 52
+	}
+	for mantissa&1 == 0 {
+		mantissa >>= 1
+		exponent++
+	}
+
+	// The first octet contains the sign and the exponent length. The base
+	// and scaling factor are zero.
+	b := byte(0x80)
+	if math.Signbit(f) {
+		b |= 0x40
+	}
+	if -128 <= exponent && exponent <= 127 {
+		dst = append(dst, b, byte(exponent))
+	} else {
+		// Exponents of a float64 always fit in two bytes.
+		dst = append(dst, b|0x01, byte(exponent>>8), byte(exponent))
+	}
+
+	var started bool
+	for shift := 56; shift >= 0; shift -= 8 {
+		if v := byte(mantissa >> uint(shift)); started || v != 0 {
+			dst = append(dst, v)
+			started = true
+		}
+	}
+	return dst
+}
+
+// AppendRealDecimal marshals f as the contents of a DER REAL and appends the
+// result to dst, returning the updated slice. Finite, non-zero values use the
+// ISO 6093 NR3 decimal encoding, in the canonical form required by DER.
+func AppendRealDecimal(dst []byte, f float64) []byte {
+	if ret, ok := appendSpecialReal(dst, f); ok {
+		return ret
+	}
+
+	// Format f as the shortest decimal which round-trips, then rewrite it
+	// with an integer mantissa, such as 15.E-1.
+	s := strconv.FormatFloat(math.Abs(f), 'e', -1, 64)
+	e := strings.IndexByte(s, 'e')
+	digits := strings.Replace(s[:e], ".", "", 1)
+	exponent, err := strconv.Atoi(s[e+1:])
+	if err != nil {
+		panic(err)
+	}
+	exponent -= len(digits) - 1
+	for len(digits) > 1 && digits[len(digits)-1] == '0' {
+		digits = digits[:len(digits)-1]
+		exponent++
+	}
+
+	// NR3 form.
+	dst = append(dst, 0x03)
+	if math.Signbit(f) {
+		dst = append(dst, '-')
+	}
+	dst = append(dst, digits...)
+	dst = append(dst, ".E"...)
+	if exponent == 0 {
+		dst = append(dst, "+0"...)
+	} else {
+		dst = strconv.AppendInt(dst, int64(exponent), 10)
+	}
+	return dst
+}
@@ -0,0 +1,65 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lib
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+var appendRealTests = []struct {
+	value   float64
+	binary  []byte
+	decimal []byte
+}{
+	// Special values.
+	{0, []byte{}, []byte{}},
+	{math.Copysign(0, -1), []byte{0x43}, []byte{0x43}},
+	{math.Inf(1), []byte{0x40}, []byte{0x40}},
+	{math.Inf(-1), []byte{0x41}, []byte{0x41}},
+	{math.NaN(), []byte{0x42}, []byte{0x42}},
+	// Finite values.
+	{1, []byte{0x80, 0x00, 0x01}, []byte("\x031.E+0")},
+	{-1, []byte{0xc0, 0x00, 0x01}, []byte("\x03-1.E+0")},
+	{1.5, []byte{0x80, 0xff, 0x03}, []byte("\x0315.E-1")},
+	{0.5, []byte{0x80, 0xff, 0x01}, []byte("\x035.E-1")},
+	{100, []byte{0x80, 0x02, 0x19}, []byte("\x031.E2")},
+	{-123.25, []byte{0xc0, 0xfe, 0x01, 0xed}, []byte("\x03-12325.E-2")},
+	// Very large and small exponents.
+	{math.Ldexp(1, 1000), []byte{0x81, 0x03, 0xe8, 0x01}, []byte("\x0310715086071862673.E285")},
+	{math.MaxFloat64, []byte{0x81, 0x03, 0xcb, 0x1f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, []byte("\x0317976931348623157.E292")},
+	{math.SmallestNonzeroFloat64, []byte{0x81, 0xfb, 0xce, 0x01}, []byte("\x035.E-324")},
+}
+
+func TestAppendReal(t *testing.T) {
+	for i, tt := range appendRealTests {
+		if dst := AppendReal(nil, tt.value); !bytes.Equal(dst, tt.binary) {
+			t.Errorf("%d. AppendReal(nil, %v) = %x, wanted %x.", i, tt.value, dst, tt.binary)
+		}
+		if dst := AppendRealDecimal(nil, tt.value); !bytes.Equal(dst, tt.decimal) {
+			t.Errorf("%d. AppendRealDecimal(nil, %v) = %q, wanted %q.", i, tt.value, dst, tt.decimal)
+		}
+
+		dst := AppendReal([]byte{0}, tt.value)
+		if l := len(tt.binary); len(dst) != l+1 || dst[0] != 0 || !bytes.Equal(dst[1:], tt.binary) {
+			t.Errorf("%d. AppendReal did not preserve existing contents.", i)
+		}
+		dst = AppendRealDecimal([]byte{0}, tt.value)
+		if l := len(tt.decimal); len(dst) != l+1 || dst[0] != 0 || !bytes.Equal(dst[1:], tt.decimal) {
+			t.Errorf("%d. AppendRealDecimal did not preserve existing contents.", i)
+		}
+	}
+}
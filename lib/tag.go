@@ -15,6 +15,12 @@
 // package lib contains common routines between der2ascii and ascii2der.
 package lib
 
+import (
+	"fmt"
+	"strings"
+)
+
+// A Class is the class of a tag, encoded as it appears in the first byte.
 type Class byte
 
 const (
@@ -24,6 +30,7 @@ const (
 	ClassPrivate         Class = 0xc0
 )
 
+// A Tag is a BER tag. Use AppendTag and ParseTag to encode and decode it.
 type Tag struct {
 	Class       Class
 	Number      uint32
@@ -48,11 +55,14 @@ func (t Tag) GetAlias() (name string, toggleConstructed bool, ok bool) {
 	return
 }
 
-var universalTags = []struct {
+// A universalTag is an entry in the table of named universal tags.
+type universalTag struct {
 	number      uint32
 	name        string
 	constructed bool
-}{
+}
+
+var universalTags = []universalTag{
 	// 0 is reserved.
 	{1, "BOOLEAN", false},
 	{2, "INTEGER", false},
@@ -64,8 +74,9 @@ var universalTags = []struct {
 	{8, "EXTERNAL", false},
 	{9, "REAL", false},
 	{10, "ENUMERATED", false},
+	{11, "EMBEDDED_PDV", false},
 	{12, "UTF8String", false},
-	{13, "EMBEDDED_PDV", false},
+	{13, "RELATIVE_OID", false},
 	{14, "TIME", false},
 	// 15 is reserved for future expansion.
 	{16, "SEQUENCE", true},
@@ -81,6 +92,7 @@ var universalTags = []struct {
 	{26, "VisibleString", false},
 	{27, "GeneralString", false},
 	{28, "UniversalString", false},
+	{29, "CHARACTER_STRING", false},
 	{30, "BMPString", false},
 	{31, "DATE", false},
 	{32, "TIME-OF-DAY", false},
@@ -90,6 +102,12 @@ var universalTags = []struct {
 	{36, "RELATIVE-OID-IRI", false},
 }
 
+// alternateTags are additional names for entries in universalTags. TagByName
+// accepts them, but GetAlias always returns the name in universalTags.
+var alternateTags = []universalTag{
+	{20, "TeletexString", false},
+}
+
 // TagByName returns the universal tag by name or false if no tag matches.
 func TagByName(name string) (Tag, bool) {
 	for _, u := range universalTags {
@@ -97,5 +115,182 @@ func TagByName(name string) (Tag, bool) {
 			return Tag{ClassUniversal, u.number, u.constructed}, true
 		}
 	}
+	for _, u := range alternateTags {
+		if u.name == name {
+			return Tag{ClassUniversal, u.number, u.constructed}, true
+		}
+	}
 	return Tag{}, false
 }
+
+// TagByNameIgnoreCase behaves like TagByName, but matches name
+// case-insensitively. It also returns the name's canonical spelling.
+func TagByNameIgnoreCase(name string) (tag Tag, canonical string, ok bool) {
+	for _, u := range universalTags {
+		if strings.EqualFold(u.name, name) {
+			return Tag{ClassUniversal, u.number, u.constructed}, u.name, true
+		}
+	}
+	for _, u := range alternateTags {
+		if strings.EqualFold(u.name, name) {
+			return Tag{ClassUniversal, u.number, u.constructed}, u.name, true
+		}
+	}
+	return Tag{}, "", false
+}
+
+// checkTagName returns an error if name, which must be non-empty, cannot be
+// used as a tag name.
+func checkTagName(name string) error {
+	if i := strings.IndexAny(name, " \t\r\n{}[]()`|\"#"); i >= 0 {
+		return fmt.Errorf("tag name %q contains delimiter %q", name, name[i])
+	}
+	if strings.Contains(name, "/*") {
+		return fmt.Errorf("tag name %q contains a comment", name)
+	}
+	for _, word := range reservedWords {
+		if name == word {
+			return fmt.Errorf("tag name %q is a reserved word", name)
+		}
+	}
+	return nil
+}
+
+// A TagTable names tags in addition to the built-in universal tags, such as the
+// context-specific tags of a particular protocol. Built-in names take
+// precedence. The zero value is an empty table, and a nil *TagTable behaves as
+// an empty table.
+type TagTable struct {
+	byName map[string]Tag
+	// byNumber maps a tag, with Constructed false, to its name. Tags with
+	// more than one name map to the empty string, since none can be chosen.
+	byNumber map[Tag]string
+}
+
+// Add names tag. It returns an error if name is not a valid tag name, is a
+// built-in name or the name of a well-known OID, or was already added. A tag
+// may be given several names, as protocols often reuse context-specific tags,
+// but such tags are then never named by GetAlias.
+func (t *TagTable) Add(name string, tag Tag) error {
+	if len(name) == 0 {
+		return fmt.Errorf("tag %d has an empty name", tag.Number)
+	}
+	// Names beginning with a digit or hyphen would scan as numbers.
+	if c := name[0]; !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') {
+		return fmt.Errorf("tag name %q must begin with a letter", name)
+	}
+	if err := checkTagName(name); err != nil {
+		return err
+	}
+	if _, ok := TagByName(name); ok {
+		return fmt.Errorf("tag name %q is built in", name)
+	}
+	// Tag names are looked up first, so the OID could not be named.
+	if _, ok := OIDByName(name); ok {
+		return fmt.Errorf("tag name %q is the name of an OID", name)
+	}
+	if _, ok := t.byName[name]; ok {
+		return fmt.Errorf("tag name %q is already defined", name)
+	}
+	if t.byName == nil {
+		t.byName = make(map[string]Tag)
+		t.byNumber = make(map[Tag]string)
+	}
+	t.byName[name] = tag
+	key := tag
+	key.Constructed = false
+	if _, ok := t.byNumber[key]; ok {
+		t.byNumber[key] = ""
+	} else {
+		t.byNumber[key] = name
+	}
+	return nil
+}
+
+// TagByName returns the tag named name in t, or false if there is none. It
+// does not consider built-in names.
+func (t *TagTable) TagByName(name string) (Tag, bool) {
+	if t == nil {
+		return Tag{}, false
+	}
+	tag, ok := t.byName[name]
+	return tag, ok
+}
+
+// TagByNameIgnoreCase matches name case-insensitively against both the
+// built-in names and the names in t, and returns the tag and the canonical
+// spelling of the name it matched. If more than one name matches, such as a
+// name in t which differs from a built-in name only in case, the match is
+// ambiguous and none is returned.
+func (t *TagTable) TagByNameIgnoreCase(name string) (tag Tag, canonical string, ok bool) {
+	tag, canonical, ok = TagByNameIgnoreCase(name)
+	if t == nil {
+		return tag, canonical, ok
+	}
+	for n, nTag := range t.byName {
+		if strings.EqualFold(n, name) {
+			if ok {
+				return Tag{}, "", false
+			}
+			tag, canonical, ok = nTag, n, true
+		}
+	}
+	return tag, canonical, ok
+}
+
+// GetAlias behaves like Tag.GetAlias, but looks up tag in t. Tags given more
+// than one name have no alias.
+func (t *TagTable) GetAlias(tag Tag) (name string, toggleConstructed bool, ok bool) {
+	if t == nil {
+		return
+	}
+	key := tag
+	key.Constructed = false
+	name = t.byNumber[key]
+	if name == "" {
+		return
+	}
+	return name, t.byName[name].Constructed != tag.Constructed, true
+}
+
+// reservedWords are the bare words which the DER ASCII scanner interprets before
+// tag names, so a tag with one of these names could never be used.
+var reservedWords = []string{"TRUE", "FALSE", "indefinite", "set-of", "define", "use", "include", "implicit", "explicit", "raw", "eoc"}
+
+// ValidateTagTable checks the table of universal tag names for consistency. It
+// returns an error if a name is empty, contains a character which the DER ASCII
+// scanner treats as a delimiter, is a reserved word, or is used twice, if a
+// tag number is named twice, or if an alternate name does not match a tag in
+// the table. Forks which add tags may call it from a test.
+func ValidateTagTable() error {
+	return validateTagTable(universalTags, alternateTags)
+}
+
+func validateTagTable(tags, alternates []universalTag) error {
+	names := make(map[string]uint32, len(tags)+len(alternates))
+	numbers := make(map[uint32]universalTag, len(tags))
+	for i, u := range append(tags[:len(tags):len(tags)], alternates...) {
+		isAlternate := i >= len(tags)
+		if len(u.name) == 0 {
+			return fmt.Errorf("tag %d has an empty name", u.number)
+		}
+		if err := checkTagName(u.name); err != nil {
+			return err
+		}
+		if number, ok := names[u.name]; ok {
+			return fmt.Errorf("tag name %q is used by both tag %d and tag %d", u.name, number, u.number)
+		}
+		names[u.name] = u.number
+		if isAlternate {
+			if primary, ok := numbers[u.number]; !ok || primary.constructed != u.constructed {
+				return fmt.Errorf("alternate tag name %q does not match a tag in the table", u.name)
+			}
+			continue
+		}
+		if primary, ok := numbers[u.number]; ok {
+			return fmt.Errorf("tag %d is named both %q and %q", u.number, primary.name, u.name)
+		}
+		numbers[u.number] = u
+	}
+	return nil
+}
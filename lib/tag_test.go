@@ -26,6 +26,10 @@ var tagGetAliasTests = []struct {
 	{Tag{ClassUniversal, 16, false}, "SEQUENCE", true, true},
 	{Tag{ClassUniversal, 2, true}, "INTEGER", true, true},
 	{Tag{ClassUniversal, 2, false}, "INTEGER", false, true},
+	{Tag{ClassUniversal, 11, false}, "EMBEDDED_PDV", false, true},
+	{Tag{ClassUniversal, 13, false}, "RELATIVE_OID", false, true},
+	// Alternate names are never returned.
+	{Tag{ClassUniversal, 20, false}, "T61String", false, true},
 	{Tag{ClassApplication, 2, false}, "", false, false},
 	{Tag{ClassUniversal, 0, false}, "", false, false},
 }
@@ -55,6 +59,23 @@ var tagByNameTests = []struct {
 	{"INTEGER", Tag{ClassUniversal, 2, false}, true},
 	{"OCTET STRING", Tag{}, false},
 	{"OCTET_STRING", Tag{ClassUniversal, 4, false}, true},
+	// TRUE and FALSE are reserved for BOOLEAN values.
+	{"TRUE", Tag{}, false},
+	{"FALSE", Tag{}, false},
+	// Every universal string type has a name.
+	{"UTF8String", Tag{ClassUniversal, 12, false}, true},
+	{"NumericString", Tag{ClassUniversal, 18, false}, true},
+	{"PrintableString", Tag{ClassUniversal, 19, false}, true},
+	{"T61String", Tag{ClassUniversal, 20, false}, true},
+	{"TeletexString", Tag{ClassUniversal, 20, false}, true},
+	{"VideotexString", Tag{ClassUniversal, 21, false}, true},
+	{"IA5String", Tag{ClassUniversal, 22, false}, true},
+	{"GraphicString", Tag{ClassUniversal, 25, false}, true},
+	{"VisibleString", Tag{ClassUniversal, 26, false}, true},
+	{"GeneralString", Tag{ClassUniversal, 27, false}, true},
+	{"UniversalString", Tag{ClassUniversal, 28, false}, true},
+	{"CHARACTER_STRING", Tag{ClassUniversal, 29, false}, true},
+	{"BMPString", Tag{ClassUniversal, 30, false}, true},
 }
 
 func TestTagByName(t *testing.T) {
@@ -71,3 +92,157 @@ func TestTagByName(t *testing.T) {
 		}
 	}
 }
+
+var tagByNameIgnoreCaseTests = []struct {
+	name      string
+	tag       Tag
+	canonical string
+	ok        bool
+}{
+	{"SEQUENCE", Tag{ClassUniversal, 16, true}, "SEQUENCE", true},
+	{"sequence", Tag{ClassUniversal, 16, true}, "SEQUENCE", true},
+	{"Integer", Tag{ClassUniversal, 2, false}, "INTEGER", true},
+	{"octet_string", Tag{ClassUniversal, 4, false}, "OCTET_STRING", true},
+	{"utf8string", Tag{ClassUniversal, 12, false}, "UTF8String", true},
+	{"teletexstring", Tag{ClassUniversal, 20, false}, "TeletexString", true},
+	{"octet string", Tag{}, "", false},
+	{"true", Tag{}, "", false},
+}
+
+func TestTagByNameIgnoreCase(t *testing.T) {
+	for i, tt := range tagByNameIgnoreCaseTests {
+		tag, canonical, ok := TagByNameIgnoreCase(tt.name)
+		if ok != tt.ok || tag != tt.tag || canonical != tt.canonical {
+			t.Errorf("%d. TagByNameIgnoreCase(%v) = %v, %q, %v, wanted %v, %q, %v.", i, tt.name, tag, canonical, ok, tt.tag, tt.canonical, tt.ok)
+		}
+	}
+}
+
+var validateTagTableTests = []struct {
+	tags       []universalTag
+	alternates []universalTag
+	err        string
+}{
+	{[]universalTag{{1, "A", false}, {2, "B-C_d", true}}, nil, ""},
+	{[]universalTag{{1, "", false}}, nil, "tag 1 has an empty name"},
+	{[]universalTag{{1, "A{", false}}, nil, `tag name "A{" contains delimiter '{'`},
+	{[]universalTag{{1, "A B", false}}, nil, `tag name "A B" contains delimiter ' '`},
+	{[]universalTag{{1, "A/*B", false}}, nil, `tag name "A/*B" contains a comment`},
+	{[]universalTag{{1, "TRUE", false}}, nil, `tag name "TRUE" is a reserved word`},
+	{[]universalTag{{1, "A", false}, {2, "A", false}}, nil, `tag name "A" is used by both tag 1 and tag 2`},
+	{[]universalTag{{1, "A", false}, {1, "B", false}}, nil, `tag 1 is named both "A" and "B"`},
+	{[]universalTag{{1, "A", false}}, []universalTag{{1, "B", false}}, ""},
+	{[]universalTag{{1, "A", false}}, []universalTag{{1, "A", false}}, `tag name "A" is used by both tag 1 and tag 1`},
+	{[]universalTag{{1, "A", false}}, []universalTag{{2, "B", false}}, `alternate tag name "B" does not match a tag in the table`},
+	{[]universalTag{{1, "A", false}}, []universalTag{{1, "B", true}}, `alternate tag name "B" does not match a tag in the table`},
+	{[]universalTag{{1, "A", false}}, []universalTag{{1, "B{", false}}, `tag name "B{" contains delimiter '{'`},
+}
+
+func TestValidateTagTable(t *testing.T) {
+	if err := ValidateTagTable(); err != nil {
+		t.Errorf("ValidateTagTable failed: %s.", err)
+	}
+	for i, tt := range validateTagTableTests {
+		err := validateTagTable(tt.tags, tt.alternates)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%d. validateTagTable failed: %s.", i, err)
+			}
+		} else if err == nil || err.Error() != tt.err {
+			t.Errorf("%d. validateTagTable returned %v, wanted %q.", i, err, tt.err)
+		}
+	}
+}
+
+var tagTableAddTests = []struct {
+	name string
+	err  string
+}{
+	{"SEQUENCE", `tag name "SEQUENCE" is built in`},
+	{"GetRequest", `tag name "GetRequest" is already defined`},
+	{"1x", `tag name "1x" must begin with a letter`},
+	{"A{", `tag name "A{" contains delimiter '{'`},
+	{"implicit", `tag name "implicit" is a reserved word`},
+	{"rsaEncryption", `tag name "rsaEncryption" is the name of an OID`},
+	{"", "tag 5 has an empty name"},
+}
+
+func TestTagTable(t *testing.T) {
+	var table TagTable
+	getRequest := Tag{ClassContextSpecific, 0, true}
+	response := Tag{ClassContextSpecific, 2, true}
+	if err := table.Add("GetRequest", getRequest); err != nil {
+		t.Fatalf("Add failed: %s.", err)
+	}
+	if err := table.Add("Response", response); err != nil {
+		t.Fatalf("Add failed: %s.", err)
+	}
+	// Another name for the same tag is allowed, but the tag then has no
+	// alias.
+	if err := table.Add("Realm", Tag{ClassContextSpecific, 2, false}); err != nil {
+		t.Fatalf("Add failed: %s.", err)
+	}
+
+	for _, tt := range tagTableAddTests {
+		if err := table.Add(tt.name, Tag{ClassContextSpecific, 5, true}); err == nil || err.Error() != tt.err {
+			t.Errorf("Add(%q) returned %v, wanted %q.", tt.name, err, tt.err)
+		}
+	}
+
+	if tag, ok := table.TagByName("GetRequest"); !ok || tag != getRequest {
+		t.Errorf("TagByName(GetRequest) = %v, %v, wanted %v.", tag, ok, getRequest)
+	}
+	if _, ok := table.TagByName("SEQUENCE"); ok {
+		t.Errorf("TagByName(SEQUENCE) unexpectedly succeeded.")
+	}
+	if name, toggle, ok := table.GetAlias(getRequest); !ok || name != "GetRequest" || toggle {
+		t.Errorf("GetAlias(%v) = %q, %v, %v, wanted GetRequest.", getRequest, name, toggle, ok)
+	}
+	primitive := Tag{ClassContextSpecific, 0, false}
+	if name, toggle, ok := table.GetAlias(primitive); !ok || name != "GetRequest" || !toggle {
+		t.Errorf("GetAlias(%v) = %q, %v, %v, wanted GetRequest, toggled.", primitive, name, toggle, ok)
+	}
+	if name, _, ok := table.GetAlias(response); ok {
+		t.Errorf("GetAlias(%v) = %q, wanted no alias.", response, name)
+	}
+
+	if tag, name, ok := table.TagByNameIgnoreCase("getrequest"); !ok || tag != getRequest || name != "GetRequest" {
+		t.Errorf("TagByNameIgnoreCase(getrequest) = %v, %q, %v, wanted %v, GetRequest.", tag, name, ok, getRequest)
+	}
+	// Names which differ only in case are ambiguous.
+	if err := table.Add("REALM", Tag{ClassContextSpecific, 3, true}); err != nil {
+		t.Fatalf("Add failed: %s.", err)
+	}
+	if _, name, ok := table.TagByNameIgnoreCase("realm"); ok {
+		t.Errorf("TagByNameIgnoreCase(realm) = %q, wanted no match.", name)
+	}
+	if tag, ok := table.TagByName("REALM"); !ok || tag.Number != 3 {
+		t.Errorf("TagByName(REALM) = %v, %v, wanted tag 3.", tag, ok)
+	}
+
+	// Built-in names also match, unless a name in the table differs from
+	// them only in case.
+	if tag, name, ok := table.TagByNameIgnoreCase("integer"); !ok || tag != (Tag{ClassUniversal, 2, false}) || name != "INTEGER" {
+		t.Errorf("TagByNameIgnoreCase(integer) = %v, %q, %v, wanted INTEGER.", tag, name, ok)
+	}
+	if err := table.Add("Sequence", Tag{ClassContextSpecific, 4, true}); err != nil {
+		t.Fatalf("Add failed: %s.", err)
+	}
+	if _, name, ok := table.TagByNameIgnoreCase("sequence"); ok {
+		t.Errorf("TagByNameIgnoreCase(sequence) = %q, wanted no match.", name)
+	}
+
+	var nilTable *TagTable
+	if _, _, ok := nilTable.TagByNameIgnoreCase("GetRequest"); ok {
+		t.Errorf("TagByNameIgnoreCase on a nil table unexpectedly succeeded.")
+	}
+	if _, name, ok := nilTable.TagByNameIgnoreCase("sequence"); !ok || name != "SEQUENCE" {
+		t.Errorf("TagByNameIgnoreCase(sequence) on a nil table = %q, %v, wanted SEQUENCE.", name, ok)
+	}
+	if _, ok := nilTable.TagByName("GetRequest"); ok {
+		t.Errorf("TagByName on a nil table unexpectedly succeeded.")
+	}
+	if _, _, ok := nilTable.GetAlias(getRequest); ok {
+		t.Errorf("GetAlias on a nil table unexpectedly succeeded.")
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lib
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// FormatUTCTime returns the contents of a DER UTCTime for t. UTCTime only
+// represents whole seconds in years 1950 through 2049, so it returns an error if
+// t is out of range or has fractional seconds.
+func FormatUTCTime(t time.Time) (string, error) {
+	t = t.UTC()
+	if year := t.Year(); year < 1950 || year >= 2050 {
+		return "", errors.New("UTCTime year must be between 1950 and 2049")
+	}
+	if t.Nanosecond() != 0 {
+		return "", errors.New("UTCTime may not have fractional seconds")
+	}
+	return t.Format("060102150405Z"), nil
+}
+
+// FormatGeneralizedTime returns the contents of a DER GeneralizedTime for t.
+// Fractional seconds are included, without trailing zeros, only if non-zero. It
+// returns an error if the year does not fit in four digits.
+func FormatGeneralizedTime(t time.Time) (string, error) {
+	t = t.UTC()
+	if year := t.Year(); year < 0 || year > 9999 {
+		return "", errors.New("GeneralizedTime year must be between 0 and 9999")
+	}
+	return t.Format("20060102150405.999999999Z"), nil
+}
+
+// FormatDate returns the contents of a DER DATE for the date of t, ignoring the
+// time of day and time zone. X.680 restricts DATE to years 1582 through 9999, so
+// it returns an error if t is out of range.
+func FormatDate(t time.Time) (string, error) {
+	if year := t.Year(); year < 1582 || year > 9999 {
+		return "", errors.New("DATE year must be between 1582 and 9999")
+	}
+	return t.Format("20060102"), nil
+}
+
+// FormatDateTime returns the contents of a DER DATE-TIME for the date and time
+// of day of t, ignoring the time zone, as DATE-TIME is a local time. It returns
+// an error if the year is not between 1582 and 9999 or t has fractional seconds.
+func FormatDateTime(t time.Time) (string, error) {
+	if year := t.Year(); year < 1582 || year > 9999 {
+		return "", errors.New("DATE-TIME year must be between 1582 and 9999")
+	}
+	if t.Nanosecond() != 0 {
+		return "", errors.New("DATE-TIME may not have fractional seconds")
+	}
+	return t.Format("20060102150405"), nil
+}
+
+// ParseUTCTime parses s as the contents of a DER UTCTime. Two-digit years from
+// 50 through 99 are in the 1900s, and the rest are in the 2000s.
+func ParseUTCTime(s string) (time.Time, error) {
+	if len(s) != len("YYMMDDHHMMSSZ") || s[len(s)-1] != 'Z' {
+		return time.Time{}, errors.New("UTCTime must be of the form YYMMDDHHMMSSZ")
+	}
+	century := "20"
+	if s[:2] >= "50" {
+		century = "19"
+	}
+	return time.Parse("20060102150405Z", century+s)
+}
+
+// ParseGeneralizedTime parses s as the contents of a DER GeneralizedTime. DER
+// requires the time be in UTC, with a decimal point rather than a comma, and
+// with no trailing zeros in the fractional seconds, so other forms are
+// rejected.
+func ParseGeneralizedTime(s string) (time.Time, error) {
+	if len(s) > len("YYYYMMDDHHMMSS") && strings.ContainsAny(s[len("YYYYMMDDHHMMSS"):], "+-") {
+		return time.Time{}, errors.New("GeneralizedTime must be in UTC, ending in Z")
+	}
+	if strings.IndexByte(s, ',') >= 0 {
+		return time.Time{}, errors.New("GeneralizedTime must use a decimal point, not a comma")
+	}
+	if len(s) < len("YYYYMMDDHHMMSSZ") || s[len(s)-1] != 'Z' || strings.HasSuffix(s, ".Z") {
+		return time.Time{}, errors.New("GeneralizedTime must be of the form YYYYMMDDHHMMSS[.fff]Z")
+	}
+	if strings.IndexByte(s, '.') >= 0 && strings.HasSuffix(s, "0Z") {
+		return time.Time{}, errors.New("GeneralizedTime fractional seconds may not have trailing zeros")
+	}
+	return time.Parse("20060102150405.999999999Z", s)
+}
@@ -0,0 +1,217 @@
+// Copyright 2015 The DER ASCII Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lib
+
+import (
+	"testing"
+	"time"
+)
+
+var formatUTCTimeTests = []struct {
+	in  time.Time
+	out string
+	ok  bool
+}{
+	{time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), "210101000000Z", true},
+	{time.Date(1950, 1, 1, 0, 0, 0, 0, time.UTC), "500101000000Z", true},
+	{time.Date(2049, 12, 31, 23, 59, 59, 0, time.UTC), "491231235959Z", true},
+	// Times are converted to UTC.
+	{time.Date(2021, 1, 1, 1, 0, 0, 0, time.FixedZone("", 3600)), "210101000000Z", true},
+	// Out of range.
+	{time.Date(1949, 12, 31, 23, 59, 59, 0, time.UTC), "", false},
+	{time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC), "", false},
+	// Fractional seconds.
+	{time.Date(2021, 1, 1, 0, 0, 0, 500000000, time.UTC), "", false},
+}
+
+func TestFormatUTCTime(t *testing.T) {
+	for i, tt := range formatUTCTimeTests {
+		out, err := FormatUTCTime(tt.in)
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("%d. FormatUTCTime(%v) unexpectedly succeeded.", i, tt.in)
+			}
+		} else if err != nil {
+			t.Errorf("%d. FormatUTCTime(%v) unexpectedly failed: %s.", i, tt.in, err)
+		} else if out != tt.out {
+			t.Errorf("%d. FormatUTCTime(%v) = %v, wanted %v.", i, tt.in, out, tt.out)
+		}
+	}
+}
+
+var formatGeneralizedTimeTests = []struct {
+	in  time.Time
+	out string
+	ok  bool
+}{
+	{time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), "20210101000000Z", true},
+	{time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC), "19000101000000Z", true},
+	{time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC), "99991231235959Z", true},
+	// Fractional seconds omit trailing zeros.
+	{time.Date(2021, 1, 1, 0, 0, 0, 500000000, time.UTC), "20210101000000.5Z", true},
+	{time.Date(2021, 1, 1, 0, 0, 0, 1, time.UTC), "20210101000000.000000001Z", true},
+	// Times are converted to UTC.
+	{time.Date(2021, 1, 1, 0, 0, 0, 0, time.FixedZone("", -3600)), "20210101010000Z", true},
+	// Out of range.
+	{time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC), "", false},
+	{time.Date(-1, 1, 1, 0, 0, 0, 0, time.UTC), "", false},
+}
+
+func TestFormatGeneralizedTime(t *testing.T) {
+	for i, tt := range formatGeneralizedTimeTests {
+		out, err := FormatGeneralizedTime(tt.in)
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("%d. FormatGeneralizedTime(%v) unexpectedly succeeded.", i, tt.in)
+			}
+		} else if err != nil {
+			t.Errorf("%d. FormatGeneralizedTime(%v) unexpectedly failed: %s.", i, tt.in, err)
+		} else if out != tt.out {
+			t.Errorf("%d. FormatGeneralizedTime(%v) = %v, wanted %v.", i, tt.in, out, tt.out)
+		}
+	}
+}
+
+var formatDateTests = []struct {
+	in       time.Time
+	date     string
+	dateTime string
+}{
+	{time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), "20210101", "20210101000000"},
+	{time.Date(1582, 1, 1, 0, 0, 0, 0, time.UTC), "15820101", "15820101000000"},
+	{time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC), "99991231", "99991231235959"},
+	// Time zones are ignored, as these are local times.
+	{time.Date(2021, 1, 1, 23, 0, 0, 0, time.FixedZone("", -3600)), "20210101", "20210101230000"},
+	// Fractional seconds are only allowed in DATE.
+	{time.Date(2021, 1, 1, 0, 0, 0, 500000000, time.UTC), "20210101", ""},
+	// Out of range.
+	{time.Date(1581, 12, 31, 0, 0, 0, 0, time.UTC), "", ""},
+	{time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC), "", ""},
+}
+
+func TestFormatDate(t *testing.T) {
+	for i, tt := range formatDateTests {
+		date, err := FormatDate(tt.in)
+		if tt.date == "" {
+			if err == nil {
+				t.Errorf("%d. FormatDate(%v) unexpectedly succeeded.", i, tt.in)
+			}
+		} else if err != nil || date != tt.date {
+			t.Errorf("%d. FormatDate(%v) = %v, %v, wanted %v.", i, tt.in, date, err, tt.date)
+		}
+
+		dateTime, err := FormatDateTime(tt.in)
+		if tt.dateTime == "" {
+			if err == nil {
+				t.Errorf("%d. FormatDateTime(%v) unexpectedly succeeded.", i, tt.in)
+			}
+		} else if err != nil || dateTime != tt.dateTime {
+			t.Errorf("%d. FormatDateTime(%v) = %v, %v, wanted %v.", i, tt.in, dateTime, err, tt.dateTime)
+		}
+	}
+}
+
+var parseUTCTimeTests = []struct {
+	in  string
+	out time.Time
+	ok  bool
+}{
+	{"210101000000Z", time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), true},
+	{"500101000000Z", time.Date(1950, 1, 1, 0, 0, 0, 0, time.UTC), true},
+	{"491231235959Z", time.Date(2049, 12, 31, 23, 59, 59, 0, time.UTC), true},
+	// Invalid times.
+	{"", time.Time{}, false},
+	{"2101010000Z", time.Time{}, false},
+	{"210101000000", time.Time{}, false},
+	{"210101000000+0000", time.Time{}, false},
+	{"211301000000Z", time.Time{}, false},
+	{"210230000000Z", time.Time{}, false},
+	{"21010100000aZ", time.Time{}, false},
+}
+
+func TestParseUTCTime(t *testing.T) {
+	for i, tt := range parseUTCTimeTests {
+		out, err := ParseUTCTime(tt.in)
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("%d. ParseUTCTime(%q) unexpectedly succeeded.", i, tt.in)
+			}
+		} else if err != nil {
+			t.Errorf("%d. ParseUTCTime(%q) unexpectedly failed: %s.", i, tt.in, err)
+		} else if !out.Equal(tt.out) {
+			t.Errorf("%d. ParseUTCTime(%q) = %v, wanted %v.", i, tt.in, out, tt.out)
+		}
+	}
+}
+
+var parseGeneralizedTimeTests = []struct {
+	in  string
+	out time.Time
+	ok  bool
+}{
+	{"20210101000000Z", time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), true},
+	{"19000101000000Z", time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC), true},
+	{"20210101000000.5Z", time.Date(2021, 1, 1, 0, 0, 0, 500000000, time.UTC), true},
+	{"20210101000000.000000001Z", time.Date(2021, 1, 1, 0, 0, 0, 1, time.UTC), true},
+	// Invalid times.
+	{"", time.Time{}, false},
+	{"202101010000Z", time.Time{}, false},
+	{"20210101000000", time.Time{}, false},
+	{"20210101000000.Z", time.Time{}, false},
+	{"20211301000000Z", time.Time{}, false},
+	{"20210101000000+0100", time.Time{}, false},
+	// Non-canonical forms are invalid in DER.
+	{"20210101000000.0Z", time.Time{}, false},
+	{"20210101000000.50Z", time.Time{}, false},
+	{"20210101000000,5Z", time.Time{}, false},
+	{"20210101000000.5-0100", time.Time{}, false},
+	{"2021010100Z", time.Time{}, false},
+}
+
+func TestParseGeneralizedTime(t *testing.T) {
+	for i, tt := range parseGeneralizedTimeTests {
+		out, err := ParseGeneralizedTime(tt.in)
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("%d. ParseGeneralizedTime(%q) unexpectedly succeeded.", i, tt.in)
+			}
+		} else if err != nil {
+			t.Errorf("%d. ParseGeneralizedTime(%q) unexpectedly failed: %s.", i, tt.in, err)
+		} else if !out.Equal(tt.out) {
+			t.Errorf("%d. ParseGeneralizedTime(%q) = %v, wanted %v.", i, tt.in, out, tt.out)
+		}
+	}
+}
+
+func TestTimeRoundTrip(t *testing.T) {
+	for i, tt := range formatUTCTimeTests {
+		if !tt.ok {
+			continue
+		}
+		s, _ := FormatUTCTime(tt.in)
+		if out, err := ParseUTCTime(s); err != nil || !out.Equal(tt.in) {
+			t.Errorf("%d. ParseUTCTime(%q) = %v, %v, wanted %v.", i, s, out, err, tt.in)
+		}
+	}
+	for i, tt := range formatGeneralizedTimeTests {
+		if !tt.ok {
+			continue
+		}
+		s, _ := FormatGeneralizedTime(tt.in)
+		if out, err := ParseGeneralizedTime(s); err != nil || !out.Equal(tt.in) {
+			t.Errorf("%d. ParseGeneralizedTime(%q) = %v, %v, wanted %v.", i, s, out, err, tt.in)
+		}
+	}
+}